@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 
@@ -8,8 +9,13 @@ import (
 
 	"github.com/MalithGihan/uigp-service/internal/chat"
 	"github.com/MalithGihan/uigp-service/internal/config"
+	archctx "github.com/MalithGihan/uigp-service/internal/context"
+	"github.com/MalithGihan/uigp-service/internal/conversation"
 	httpapi "github.com/MalithGihan/uigp-service/internal/http"
+	"github.com/MalithGihan/uigp-service/internal/jobs"
+	"github.com/MalithGihan/uigp-service/internal/llm"
 	llmfactory "github.com/MalithGihan/uigp-service/internal/llm/factory"
+	"github.com/MalithGihan/uigp-service/internal/store"
 )
 
 func main() {
@@ -23,11 +29,28 @@ func main() {
 		log.Fatalf("llm init error: %v", err)
 	}
 
+	convStore, err := newConversationStore(cfg)
+	if err != nil {
+		log.Fatalf("conversation store init error: %v", err)
+	}
+
+	// One limit for every LLM caller: chat, history summaries and jobs.
+	limiter := llm.NewLimiter(cfg.LLMConcurrency)
+
+	rules := archctx.DefaultRuleSet()
+	if cfg.RulesConfig != "" {
+		rules, err = archctx.LoadRuleSet(cfg.RulesConfig)
+		if err != nil {
+			log.Fatalf("rules init error: %v", err)
+		}
+	}
+
 	chatSvc := chat.NewService(chat.ServiceDeps{
 		LLM:             llmClient,
 		MaxHistoryItems: cfg.MaxHistoryItems,
 		MaxHistoryChars: cfg.MaxHistoryChars,
 		LLMConcurrency:  cfg.LLMConcurrency,
+		Limiter:         limiter,
 
 		DomainStrict:   cfg.DomainStrict,
 		DomainKeywords: cfg.DomainKeywords,
@@ -51,9 +74,25 @@ func main() {
 			NumCtx:      cfg.ChatThinkingNumCtx,
 			NumPredict:  cfg.ChatThinkingNumPredict,
 		},
+
+		Rules:         rules,
+		Conversations: convStore,
+
+		SummarizeHistory: cfg.HistorySummarize,
+		SummaryProfile: chat.LLMProfile{
+			Temperature: cfg.OllamaTemperature,
+			NumCtx:      cfg.HistorySummaryNumCtx,
+			NumPredict:  cfg.HistorySummaryNumPredict,
+		},
+		SummaryCacheSize: cfg.HistorySummaryCacheSize,
 	})
 
-	handler := httpapi.NewRouter(cfg, llmClient, chatSvc)
+	jobMgr, err := newJobManager(cfg, llmClient, limiter)
+	if err != nil {
+		log.Fatalf("jobs init error: %v", err)
+	}
+
+	handler := httpapi.NewRouter(cfg, llmClient, chatSvc, convStore, rules, jobMgr)
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
@@ -66,3 +105,46 @@ func main() {
 	log.Printf("uigp-service listening on :%s (provider=%s model=%s)", cfg.Port, llmClient.Provider(), llmClient.Model())
 	log.Fatal(srv.ListenAndServe())
 }
+
+func newConversationStore(cfg config.Config) (conversation.Store, error) {
+	limits := conversation.Limits{
+		MaxConversations: cfg.ConversationMax,
+		MaxTurns:         cfg.ConversationMaxTurns,
+		TTL:              cfg.ConversationTTL,
+	}
+	switch cfg.ConversationStore {
+	case "", "memory":
+		return conversation.NewMemoryStore(limits), nil
+	case "file":
+		return conversation.NewFileStore(cfg.ConversationDir, limits)
+	case "none", "off":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported CONVERSATION_STORE: %s", cfg.ConversationStore)
+	}
+}
+
+func newJobManager(cfg config.Config, llmClient llm.Client, limiter *llm.Limiter) (*jobs.Manager, error) {
+	switch cfg.JobsFusion {
+	case "none", "off":
+		return nil, nil
+	case jobs.FusionLLM, jobs.FusionMock:
+	default:
+		return nil, fmt.Errorf("unsupported JOBS_FUSION: %s", cfg.JobsFusion)
+	}
+	fs, err := store.New(cfg.JobsDir)
+	if err != nil {
+		return nil, err
+	}
+	return jobs.NewManager(jobs.Deps{
+		Store:     fs,
+		Workers:   cfg.JobsWorkers,
+		QueueSize: cfg.JobsQueue,
+		Timeout:   cfg.JobsTimeout,
+		Fusion:    cfg.JobsFusion,
+		LLM:       llmClient,
+		Limiter:   limiter,
+
+		MaxAttempts: cfg.JobsMaxAttempts,
+	}), nil
+}
@@ -5,6 +5,7 @@ go 1.24.4
 require (
 	github.com/go-chi/chi/v5 v5.2.3
 	github.com/joho/godotenv v1.5.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
@@ -0,0 +1,226 @@
+package chat
+
+import (
+	"sort"
+	"strings"
+
+	archctx "github.com/MalithGihan/uigp-service/internal/context"
+	"github.com/MalithGihan/uigp-service/internal/llm"
+)
+
+// minBlockTokens is the smallest useful slice of a context block; below it the
+// block is dropped instead of truncated.
+const minBlockTokens = 48
+
+const truncatedMarker = "... [truncated to fit the context budget]"
+
+// tokenBudget splits the selected profile's num_ctx between the prompt
+// sections. Output (num_predict) is reserved first; the system prompt and the
+// user message are always sent; architecture context, the history summary and
+// history turns share what is left, in that priority order.
+type tokenBudget struct {
+	numCtx   int
+	output   int
+	sections map[string]int
+	trims    []budgetTrim
+}
+
+type budgetTrim struct {
+	Section      string `json:"section"`
+	Name         string `json:"name,omitempty"`
+	Action       string `json:"action"`
+	TokensBefore int    `json:"tokens_before"`
+	TokensAfter  int    `json:"tokens_after"`
+}
+
+func newTokenBudget(numCtx, numPredict int) *tokenBudget {
+	return &tokenBudget{
+		numCtx:   numCtx,
+		output:   numPredict,
+		sections: map[string]int{},
+	}
+}
+
+func (b *tokenBudget) used() int {
+	n := 0
+	for _, v := range b.sections {
+		n += v
+	}
+	return n
+}
+
+func (b *tokenBudget) remaining() int {
+	r := b.numCtx - b.output - b.used()
+	if r < 0 {
+		return 0
+	}
+	return r
+}
+
+// add charges a section unconditionally (system prompt, user message).
+func (b *tokenBudget) add(section string, tokens int) {
+	b.sections[section] += tokens
+}
+
+func (b *tokenBudget) trim(section, name, action string, before, after int) {
+	b.trims = append(b.trims, budgetTrim{Section: section, Name: name, Action: action, TokensBefore: before, TokensAfter: after})
+}
+
+// fitBlocks keeps context blocks by priority. A block that does not fit is
+// truncated when at least minBlockTokens are left and dropped otherwise.
+// preamble is the fixed text wrapped around the blocks and is only charged
+// when at least one block is kept. The kept blocks are returned in their
+// original order.
+func (b *tokenBudget) fitBlocks(blocks []archctx.Block, preamble string) []archctx.Block {
+	if len(blocks) == 0 {
+		return nil
+	}
+	avail := b.remaining() - llm.EstimateMessageTokens(preamble)
+
+	idx := make([]int, len(blocks))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(x, y int) bool { return blocks[idx[x]].Priority < blocks[idx[y]].Priority })
+
+	keep := make([]bool, len(blocks))
+	out := make([]archctx.Block, len(blocks))
+	copy(out, blocks)
+	spent := 0
+	for _, i := range idx {
+		cost := blockTokens(out[i].Text)
+		left := avail - spent
+		switch {
+		case cost <= left:
+			keep[i] = true
+			spent += cost
+		case left >= minBlockTokens:
+			text := truncateToTokens(out[i].Text, left-2)
+			after := blockTokens(text)
+			out[i].Text = text
+			keep[i] = true
+			spent += after
+			b.trim("context", out[i].Name, "truncated", cost, after)
+		default:
+			b.trim("context", out[i].Name, "dropped", cost, 0)
+		}
+	}
+
+	var kept []archctx.Block
+	for i, k := range keep {
+		if k {
+			kept = append(kept, out[i])
+		}
+	}
+	if len(kept) > 0 {
+		b.add("context", spent+llm.EstimateMessageTokens(preamble))
+	}
+	return kept
+}
+
+// fitHistory keeps the newest turns that fit after holding back reserve tokens
+// and returns the older turns it dropped (oldest first).
+func (b *tokenBudget) fitHistory(h []HistoryItem, reserve int) (kept, dropped []HistoryItem) {
+	avail := b.remaining() - reserve
+	spent := 0
+	start := len(h)
+	for start > 0 {
+		cost := llm.EstimateMessageTokens(h[start-1].Content)
+		if spent+cost > avail {
+			break
+		}
+		spent += cost
+		start--
+	}
+	if start > 0 {
+		b.trim("history", "", "dropped_oldest", spent+historyTokens(h[:start]), spent)
+	}
+	b.add("history", spent)
+	return h[start:], h[:start]
+}
+
+func historyTokens(h []HistoryItem) int {
+	n := 0
+	for _, it := range h {
+		n += llm.EstimateMessageTokens(it.Content)
+	}
+	return n
+}
+
+// fitSummary charges the summary message, truncating it to the tokens left.
+func (b *tokenBudget) fitSummary(text string) string {
+	cost := llm.EstimateMessageTokens(text)
+	left := b.remaining()
+	if cost <= left {
+		b.add("summary", cost)
+		return text
+	}
+	if left < minBlockTokens {
+		b.trim("summary", "", "dropped", cost, 0)
+		return ""
+	}
+	text = truncateToTokens(text, left-llm.EstimateMessageTokens(""))
+	after := llm.EstimateMessageTokens(text)
+	b.trim("summary", "", "truncated", cost, after)
+	b.add("summary", after)
+	return text
+}
+
+func (b *tokenBudget) signal() map[string]any {
+	sections := make(map[string]int, len(b.sections))
+	for k, v := range b.sections {
+		sections[k] = v
+	}
+	out := map[string]any{
+		"estimator":       "chars_div_4",
+		"num_ctx":         b.numCtx,
+		"reserved_output": b.output,
+		"prompt_tokens":   b.used(),
+		"sections":        sections,
+		"over_budget":     b.used()+b.output > b.numCtx,
+	}
+	if len(b.trims) > 0 {
+		out["trimmed"] = b.trims
+	}
+	return out
+}
+
+// blockTokens is the cost of one context block including its "\n\n" separator.
+func blockTokens(text string) int {
+	return llm.EstimateTokens(text) + 1
+}
+
+// truncateToTokens cuts text to at most max estimated tokens, on a line
+// boundary when possible, and appends truncatedMarker.
+func truncateToTokens(text string, max int) string {
+	max -= llm.EstimateTokens("\n" + truncatedMarker)
+	if max <= 0 {
+		return truncatedMarker
+	}
+	// Per-line estimates round up, so their sum never undercounts the joined text.
+	// The first line that does not fit is cut by runes to use the remaining room.
+	var b strings.Builder
+	spent := 0
+	for _, ln := range strings.Split(text, "\n") {
+		cost := llm.EstimateTokens(ln) + 1
+		if spent+cost <= max {
+			spent += cost
+			b.WriteString(ln)
+			b.WriteString("\n")
+			continue
+		}
+		r := []rune(ln)
+		lo, hi := 0, len(r)
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			if spent+llm.EstimateTokens(string(r[:mid]))+1 <= max {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		b.WriteString(string(r[:lo]))
+		break
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n" + truncatedMarker
+}
@@ -2,9 +2,11 @@ package chat
 
 import "strings"
 
-// contextUsesDiagram is true when diagram_json or architecture YAML contributed to the compact context.
+// contextUsesDiagram is true when diagram_json, a Mermaid diagram, infra files, parsed attachments or architecture YAML contributed to the compact context.
 func contextUsesDiagram(ctxUsed string) bool {
-	return strings.Contains(ctxUsed, "diagram_json") || strings.Contains(ctxUsed, "yaml_content")
+	return strings.Contains(ctxUsed, "diagram_json") || strings.Contains(ctxUsed, "mermaid") ||
+		strings.Contains(ctxUsed, "infra_files") || strings.Contains(ctxUsed, "attachment_diagram") ||
+		strings.Contains(ctxUsed, "yaml_content")
 }
 
 func baseSystemPrompt() string {
@@ -2,10 +2,12 @@ package chat
 
 import (
 	stdctx "context"
+	"errors"
 	"strings"
 	"time"
 
 	archctx "github.com/MalithGihan/uigp-service/internal/context"
+	"github.com/MalithGihan/uigp-service/internal/conversation"
 	"github.com/MalithGihan/uigp-service/internal/llm"
 )
 
@@ -20,6 +22,9 @@ type ServiceDeps struct {
 	MaxHistoryItems int
 	MaxHistoryChars int
 	LLMConcurrency  int
+	// Limiter, when set, is shared with other LLM callers and replaces the
+	// service's own LLMConcurrency limit.
+	Limiter *llm.Limiter
 
 	DomainStrict   bool
 	DomainKeywords []string
@@ -29,13 +34,26 @@ type ServiceDeps struct {
 	ModeDefault     string
 	InstantProfile  LLMProfile
 	ThinkingProfile LLMProfile
+
+	// Rules are the structural checks behind the diagram risk hints; nil runs
+	// every built-in rule with its defaults.
+	Rules *archctx.RuleSet
+
+	// Conversations enables conversation_id requests; nil keeps the service stateless.
+	Conversations conversation.Store
+
+	// SummarizeHistory compacts turns dropped by the history budget into one
+	// summary message, generated with SummaryProfile.
+	SummarizeHistory bool
+	SummaryProfile   LLMProfile
+	SummaryCacheSize int
 }
 
 type Service struct {
 	llm             llm.Client
 	maxHistoryItems int
 	maxHistoryChars int
-	sem             chan struct{}
+	limiter         *llm.Limiter
 
 	domainStrict   bool
 	domainKeywords []string
@@ -44,12 +62,16 @@ type Service struct {
 	baseProfile     LLMProfile
 	instantProfile  LLMProfile
 	thinkingProfile LLMProfile
+
+	rules         *archctx.RuleSet
+	conversations conversation.Store
+	summarizer    *historySummarizer
 }
 
 func NewService(d ServiceDeps) *Service {
-	c := d.LLMConcurrency
-	if c <= 0 {
-		c = 1
+	limiter := d.Limiter
+	if limiter == nil {
+		limiter = llm.NewLimiter(d.LLMConcurrency)
 	}
 
 	base := d.BaseProfile
@@ -90,11 +112,11 @@ func NewService(d ServiceDeps) *Service {
 		md = "auto"
 	}
 
-	return &Service{
+	svc := &Service{
 		llm:             d.LLM,
 		maxHistoryItems: d.MaxHistoryItems,
 		maxHistoryChars: d.MaxHistoryChars,
-		sem:             make(chan struct{}, c),
+		limiter:         limiter,
 
 		domainStrict:   d.DomainStrict,
 		domainKeywords: d.DomainKeywords,
@@ -103,7 +125,18 @@ func NewService(d ServiceDeps) *Service {
 		baseProfile:     base,
 		instantProfile:  instant,
 		thinkingProfile: thinking,
+
+		rules:         d.Rules,
+		conversations: d.Conversations,
+	}
+	if d.SummarizeHistory {
+		sp := d.SummaryProfile
+		if sp.Temperature == 0 {
+			sp.Temperature = base.Temperature
+		}
+		svc.summarizer = newHistorySummarizer(d.LLM, sp, d.SummaryCacheSize)
 	}
+	return svc
 }
 
 func (s *Service) pickProfile(req ChatRequest, ctxUsed string, historyUsed int) (LLMProfile, string, bool) {
@@ -129,22 +162,52 @@ func (s *Service) pickProfile(req ChatRequest, ctxUsed string, historyUsed int)
 }
 
 func (s *Service) Handle(ctx stdctx.Context, req ChatRequest) ChatResponse {
+	return s.handle(ctx, req, nil)
+}
+
+// HandleStream behaves like Handle but forwards answer deltas to onDelta as the
+// provider produces them. The returned response carries the full answer and the
+// same signals/meta envelope as Handle.
+func (s *Service) HandleStream(ctx stdctx.Context, req ChatRequest, onDelta llm.StreamFunc) ChatResponse {
+	return s.handle(ctx, req, onDelta)
+}
+
+func (s *Service) handle(ctx stdctx.Context, req ChatRequest, onDelta llm.StreamFunc) ChatResponse {
 	start := time.Now()
 
 	msg := strings.TrimSpace(req.Message)
 	if msg == "" {
-		return ChatResponse{
-			OK:     false,
-			Source: SourceInfo{Provider: s.llm.Provider(), Model: s.llm.Model()},
-			Refs:   []any{}, Signals: map[string]any{},
-			Error: &struct {
-				Code    string `json:"code"`
-				Message string `json:"message"`
-			}{Code: "bad_request", Message: "message is required"},
+		return s.failure("bad_request", "message is required")
+	}
+
+	convID := strings.TrimSpace(req.ConversationID)
+	if convID != "" {
+		if s.conversations == nil {
+			return s.failure("bad_request", "conversations are disabled on this server")
 		}
+		conv, err := s.conversations.Get(ctx, convID)
+		switch {
+		case errors.Is(err, conversation.ErrNotFound), errors.Is(err, conversation.ErrInvalidID):
+			return s.failure("conversation_not_found", "conversation not found")
+		case err != nil:
+			return s.failure("conversation_store_failed", "could not load conversation")
+		}
+		req.History = historyFromTurns(conv.Turns)
 	}
 
-	ctxText, ctxUsed, ctxSignals := archctx.BuildCompactContext(req.SpecSummary, req.DiagramJSON, strings.TrimSpace(req.YamlContent), req.Attachments)
+	in := archctx.Input{
+		SpecSummary: req.SpecSummary,
+		DiagramJSON: req.DiagramJSON,
+		YAMLContent: strings.TrimSpace(req.YamlContent),
+		Mermaid:     req.Mermaid,
+		Infra:       req.InfraFiles,
+		Attachments: req.Attachments,
+		Rules:       s.rules,
+	}
+	if len(req.PreviousDiagramJSON) > 0 || strings.TrimSpace(req.PreviousYamlContent) != "" {
+		in.Previous = &archctx.Input{DiagramJSON: req.PreviousDiagramJSON, YAMLContent: req.PreviousYamlContent}
+	}
+	ctxBlocks, ctxUsed, ctxSignals := archctx.BuildContextBlocks(in)
 
 	ctxSignals = mergeSignals(ctxSignals, map[string]any{
 		"domain_strict": s.domainStrict,
@@ -154,6 +217,15 @@ func (s *Service) Handle(ctx stdctx.Context, req ChatRequest) ChatResponse {
 		out, reason := isOutOfScope(req, msg, s.domainKeywords)
 		if out {
 			refusal := "I can only help with microservices architecture and performance. Ask about services, dependencies, APIs, data stores, scaling, latency, throughput, deployments, or share a diagram/spec."
+			if onDelta != nil {
+				_ = onDelta(refusal)
+			}
+			meta := map[string]any{
+				"blocked":      true,
+				"latency_ms":   time.Since(start).Milliseconds(),
+				"context_used": ctxUsed,
+			}
+			s.saveTurns(ctx, convID, msg, refusal, meta)
 			return ChatResponse{
 				OK:     true,
 				Answer: refusal,
@@ -163,29 +235,70 @@ func (s *Service) Handle(ctx stdctx.Context, req ChatRequest) ChatResponse {
 					"out_of_scope":        true,
 					"out_of_scope_reason": reason,
 				}),
-				Meta: map[string]any{
-					"blocked":      true,
-					"latency_ms":   time.Since(start).Milliseconds(),
-					"context_used": ctxUsed,
-				},
+				Meta: meta,
 			}
 		}
 	}
 
 	h := normalizeHistory(req.History)
-	h = budgetHistory(h, s.maxHistoryItems, s.maxHistoryChars)
+	h, dropped := budgetHistory(h, s.maxHistoryItems, s.maxHistoryChars)
+
+	profile, modeUsed, modeInvalid := s.pickProfile(req, ctxUsed, len(h))
 
+	numPredict := profile.NumPredict
+	if contextUsesDiagram(ctxUsed) && numPredict < 320 {
+		numPredict = 320
+	}
+
+	if err := s.limiter.Acquire(ctx); err != nil {
+		return s.failure("timeout", "request cancelled")
+	}
+	defer s.limiter.Release()
+
+	// Fit the prompt into num_ctx: system prompts and the user message always go,
+	// then architecture context by block priority, then the summary reserve, then
+	// the newest history turns.
+	budget := newTokenBudget(profile.NumCtx, numPredict)
 	system := baseSystemPrompt()
+	budget.add("system", llm.EstimateMessageTokens(system))
+	if contextUsesDiagram(ctxUsed) {
+		budget.add("system", llm.EstimateMessageTokens(diagramArchitectureSystemPrompt()))
+	}
+	budget.add("message", llm.EstimateMessageTokens(msg))
+	ctxBlocks = budget.fitBlocks(ctxBlocks, architectureContextPreamble)
+
+	summaryReserve := 0
+	if s.summarizer != nil && (len(dropped) > 0 || historyTokens(h) > budget.remaining()) {
+		summaryReserve = s.summarizer.reserveTokens(budget.remaining())
+	}
+	h, overflow := budget.fitHistory(h, summaryReserve)
+	dropped = append(dropped, overflow...)
+
+	historyMeta := map[string]any{}
+	summary := ""
+	if s.summarizer != nil && len(dropped) > 0 {
+		sum, cached, err := s.summarizer.summarize(ctx, dropped)
+		switch {
+		case err != nil:
+			historyMeta["history_summary_error"] = err.Error()
+		case sum != "":
+			summary = budget.fitSummary(summaryHeader + sum)
+			historyMeta["history_summarized"] = true
+			historyMeta["history_summarized_turns"] = len(dropped)
+			historyMeta["history_summary_cached"] = cached
+		}
+	}
+	ctxSignals = mergeSignals(ctxSignals, map[string]any{
+		"token_budget": budget.signal(),
+	})
 
 	llmMsgs := []llm.Message{
 		{Role: "system", Content: system},
 	}
-	if ctxText != "" {
+	if len(ctxBlocks) > 0 {
 		llmMsgs = append(llmMsgs, llm.Message{
-			Role: "system",
-			Content: "Architecture context for this request only (from the current API payload: diagram_json, spec_summary, etc.). " +
-				"It was not necessarily sent in previous chat turns—do not tell the user the diagram or spec was 'provided earlier' in the conversation unless they literally pasted it in a message. " +
-				"Treat the following as factual input:\n" + ctxText,
+			Role:    "system",
+			Content: architectureContextPreamble + archctx.JoinBlocks(ctxBlocks),
 		})
 	}
 	if contextUsesDiagram(ctxUsed) {
@@ -197,78 +310,175 @@ func (s *Service) Handle(ctx stdctx.Context, req ChatRequest) ChatResponse {
 			"diagram_analysis_prompt": true,
 		})
 	}
+	if summary != "" {
+		llmMsgs = append(llmMsgs, llm.Message{
+			Role:    "system",
+			Content: summary,
+		})
+	}
 	for _, it := range h {
 		llmMsgs = append(llmMsgs, llm.Message{Role: it.Role, Content: it.Content})
 	}
 	llmMsgs = append(llmMsgs, llm.Message{Role: "user", Content: msg})
 
-	select {
-	case s.sem <- struct{}{}:
-		defer func() { <-s.sem }()
-	case <-ctx.Done():
-		return ChatResponse{
-			OK:     false,
-			Source: SourceInfo{Provider: s.llm.Provider(), Model: s.llm.Model()},
-			Refs:   []any{}, Signals: map[string]any{},
-			Error: &struct {
-				Code    string `json:"code"`
-				Message string `json:"message"`
-			}{Code: "timeout", Message: "request cancelled"},
-		}
-	}
-
-	profile, modeUsed, modeInvalid := s.pickProfile(req, ctxUsed, len(h))
-
-	numPredict := profile.NumPredict
-	if contextUsesDiagram(ctxUsed) && numPredict < 320 {
-		numPredict = 320
-	}
-
 	opts := map[string]any{
 		"temperature": profile.Temperature,
 		"num_ctx":     profile.NumCtx,
 		"num_predict": numPredict,
 	}
 
-	answer, err := s.llm.Chat(ctx, llm.ChatRequest{
+	llmReq := llm.ChatRequest{
 		Model:    s.llm.Model(),
 		Messages: llmMsgs,
-		Stream:   false,
+		Stream:   onDelta != nil,
 		Options:  opts,
-	})
+	}
+	ctx, served := llm.WithServedBy(ctx)
+	var answer string
+	var err error
+	if onDelta != nil {
+		answer, err = s.llm.ChatStream(ctx, llmReq, onDelta)
+	} else {
+		answer, err = s.llm.Chat(ctx, llmReq)
+	}
 	if err != nil {
+		meta := map[string]any{
+			"latency_ms":   time.Since(start).Milliseconds(),
+			"context_used": ctxUsed,
+		}
+		addServedMeta(meta, served)
+		for k, v := range historyMeta {
+			meta[k] = v
+		}
+		kind := llm.KindOf(err)
 		return ChatResponse{
-			OK:      false,
-			Source:  SourceInfo{Provider: s.llm.Provider(), Model: s.llm.Model()},
-			Refs:    []any{},
-			Signals: mergeSignals(ctxSignals, map[string]any{"llm_error": err.Error()}),
-			Meta: map[string]any{
-				"latency_ms":   time.Since(start).Milliseconds(),
-				"context_used": ctxUsed,
-			},
-			Error: &struct {
-				Code    string `json:"code"`
-				Message string `json:"message"`
-			}{Code: "llm_failed", Message: "LLM request failed"},
+			OK:     false,
+			Source: s.source(served),
+			Refs:   []any{},
+			Signals: mergeSignals(ctxSignals, map[string]any{
+				"llm_error":      err.Error(),
+				"llm_error_kind": string(kind),
+			}),
+			Meta:  meta,
+			Error: &ErrorInfo{Code: llmErrorCode(kind), Message: "LLM request failed"},
 		}
 	}
 
+	meta := map[string]any{
+		"latency_ms":   time.Since(start).Milliseconds(),
+		"context_used": ctxUsed,
+		"history_used": len(h),
+		"mode_used":    modeUsed,
+		"mode_invalid": modeInvalid,
+	}
+	if onDelta != nil {
+		meta["streamed"] = true
+	}
+	addServedMeta(meta, served)
+	for k, v := range historyMeta {
+		meta[k] = v
+	}
+	s.saveTurns(ctx, convID, msg, answer, meta)
+
 	return ChatResponse{
 		OK:      true,
 		Answer:  answer,
-		Source:  SourceInfo{Provider: s.llm.Provider(), Model: s.llm.Model()},
+		Source:  s.source(served),
 		Refs:    []any{},
 		Signals: ctxSignals,
-		Meta: map[string]any{
-			"latency_ms":   time.Since(start).Milliseconds(),
-			"context_used": ctxUsed,
-			"history_used": len(h),
-			"mode_used":    modeUsed,
-			"mode_invalid": modeInvalid,
-		},
+		Meta:    meta,
 	}
 }
 
+const architectureContextPreamble = "Architecture context for this request only (from the current API payload: diagram_json, spec_summary, etc.). " +
+	"It was not necessarily sent in previous chat turns—do not tell the user the diagram or spec was 'provided earlier' in the conversation unless they literally pasted it in a message. " +
+	"Treat the following as factual input:\n"
+
+const summaryHeader = "Conversation summary (earlier turns no longer included verbatim):\n"
+
+func (s *Service) failure(code, message string) ChatResponse {
+	return ChatResponse{
+		OK:      false,
+		Source:  SourceInfo{Provider: s.llm.Provider(), Model: s.llm.Model()},
+		Refs:    []any{},
+		Signals: map[string]any{},
+		Error:   &ErrorInfo{Code: code, Message: message},
+	}
+}
+
+// source reports the provider that actually answered when the client is a
+// composite (fallback chain), and the configured client otherwise.
+func (s *Service) source(served *llm.ServedBy) SourceInfo {
+	if served != nil && served.Provider != "" {
+		return SourceInfo{Provider: served.Provider, Model: served.Model}
+	}
+	return SourceInfo{Provider: s.llm.Provider(), Model: s.llm.Model()}
+}
+
+func addServedMeta(meta map[string]any, served *llm.ServedBy) {
+	if served == nil {
+		return
+	}
+	if served.Retries > 0 {
+		meta["llm_retries"] = served.Retries
+	}
+	if len(served.Attempts) == 0 {
+		return
+	}
+	if served.Provider != "" {
+		meta["llm_provider"] = served.Provider
+		meta["llm_model"] = served.Model
+	}
+	meta["llm_attempts"] = served.Attempts
+	meta["llm_fallback_used"] = len(served.Attempts) > 1
+}
+
+// llmErrorCode maps a classified upstream failure onto the stable error.code
+// values returned by the HTTP API.
+func llmErrorCode(kind llm.ErrorKind) string {
+	switch kind {
+	case llm.KindTimeout:
+		return "llm_timeout"
+	case llm.KindCanceled:
+		return "timeout"
+	case llm.KindConnectionRefused, llm.KindUnavailable:
+		return "llm_unavailable"
+	case llm.KindModelNotFound:
+		return "llm_model_not_found"
+	case llm.KindContextLength:
+		return "llm_context_length_exceeded"
+	case llm.KindRateLimited:
+		return "llm_rate_limited"
+	case llm.KindBadResponse:
+		return "llm_bad_response"
+	default:
+		return "llm_failed"
+	}
+}
+
+// saveTurns appends the user message and the answer to the conversation, if any.
+// A failed write does not fail the request; it is reported in meta instead.
+func (s *Service) saveTurns(ctx stdctx.Context, convID, msg, answer string, meta map[string]any) {
+	if convID == "" || s.conversations == nil {
+		return
+	}
+	now := time.Now().UTC()
+	err := s.conversations.Append(stdctx.WithoutCancel(ctx), convID,
+		conversation.Turn{Role: "user", Content: msg, CreatedAt: now},
+		conversation.Turn{Role: "assistant", Content: answer, CreatedAt: now},
+	)
+	meta["conversation_id"] = convID
+	meta["conversation_saved"] = err == nil
+}
+
+func historyFromTurns(turns []conversation.Turn) []HistoryItem {
+	out := make([]HistoryItem, 0, len(turns))
+	for _, t := range turns {
+		out = append(out, HistoryItem{Role: t.Role, Content: t.Content})
+	}
+	return out
+}
+
 func normalizeHistory(in []HistoryItem) []HistoryItem {
 	out := make([]HistoryItem, 0, len(in))
 	for _, it := range in {
@@ -285,26 +495,28 @@ func normalizeHistory(in []HistoryItem) []HistoryItem {
 	return out
 }
 
-func budgetHistory(in []HistoryItem, maxItems, maxChars int) []HistoryItem {
+// budgetHistory keeps the newest turns within the item/char limits and returns
+// the older turns it dropped (oldest first) so they can be summarized.
+func budgetHistory(in []HistoryItem, maxItems, maxChars int) (kept, dropped []HistoryItem) {
 	if maxItems <= 0 && maxChars <= 0 {
-		return in
+		return in, nil
 	}
+	all := in
 	// take last maxItems
 	if maxItems > 0 && len(in) > maxItems {
 		in = in[len(in)-maxItems:]
 	}
-	if maxChars <= 0 {
-		return in
-	}
-	total := 0
-	for _, it := range in {
-		total += len(it.Content)
-	}
-	for total > maxChars && len(in) > 0 {
-		total -= len(in[0].Content)
-		in = in[1:]
+	if maxChars > 0 {
+		total := 0
+		for _, it := range in {
+			total += len(it.Content)
+		}
+		for total > maxChars && len(in) > 0 {
+			total -= len(in[0].Content)
+			in = in[1:]
+		}
 	}
-	return in
+	return in, all[:len(all)-len(in)]
 }
 
 func mergeSignals(a, b map[string]any) map[string]any {
@@ -319,7 +531,8 @@ func mergeSignals(a, b map[string]any) map[string]any {
 }
 
 func isOutOfScope(req ChatRequest, msg string, allowKeywords []string) (bool, string) {
-	if len(req.SpecSummary) > 0 || len(req.DiagramJSON) > 0 || strings.TrimSpace(req.YamlContent) != "" || len(req.Attachments) > 0 {
+	if len(req.SpecSummary) > 0 || len(req.DiagramJSON) > 0 || strings.TrimSpace(req.YamlContent) != "" ||
+		strings.TrimSpace(req.Mermaid) != "" || len(req.InfraFiles) > 0 || len(req.Attachments) > 0 {
 		return false, "has_arch_context"
 	}
 
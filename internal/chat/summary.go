@@ -0,0 +1,178 @@
+package chat
+
+import (
+	stdctx "context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/MalithGihan/uigp-service/internal/llm"
+)
+
+// historySummarizer compacts history turns dropped by the budget into a single
+// rolling summary. Summaries are cached by a chained hash of the dropped prefix,
+// so a growing conversation only summarizes the newly dropped turns on top of the
+// longest prefix that was already summarized.
+type historySummarizer struct {
+	llm     llm.Client
+	profile LLMProfile
+
+	mu    sync.Mutex
+	cache map[string]string
+	order []string
+	max   int
+}
+
+func newHistorySummarizer(c llm.Client, profile LLMProfile, cacheSize int) *historySummarizer {
+	if profile.NumPredict <= 0 {
+		profile.NumPredict = 200
+	}
+	if profile.NumCtx <= 0 {
+		profile.NumCtx = 2048
+	}
+	if cacheSize <= 0 {
+		cacheSize = 256
+	}
+	return &historySummarizer{
+		llm:     c,
+		profile: profile,
+		cache:   map[string]string{},
+		max:     cacheSize,
+	}
+}
+
+// summarize returns the summary of dropped and whether it came fully from cache.
+// The turns not summarized yet are folded into the rolling summary in chunks
+// that fit the summary model's context window (NumCtx less NumPredict and the
+// prompts); each chunk's result is cached under the key of the prefix it ends.
+func (z *historySummarizer) summarize(ctx stdctx.Context, dropped []HistoryItem) (string, bool, error) {
+	if len(dropped) == 0 {
+		return "", false, nil
+	}
+	keys := prefixKeys(dropped)
+
+	// Longest already-summarized prefix.
+	prev, from := "", 0
+	z.mu.Lock()
+	for i := len(keys) - 1; i >= 0; i-- {
+		if s, ok := z.cache[keys[i]]; ok {
+			prev, from = s, i+1
+			break
+		}
+	}
+	z.mu.Unlock()
+	if from == len(dropped) {
+		return prev, true, nil
+	}
+
+	for from < len(dropped) {
+		var b strings.Builder
+		if prev != "" {
+			b.WriteString("Summary of even earlier turns:\n")
+			b.WriteString(prev)
+			b.WriteString("\n\n")
+		}
+		b.WriteString("Conversation turns to summarize:\n")
+		room := z.inputTokens() - llm.EstimateMessageTokens(b.String())
+		end := from
+		for end < len(dropped) {
+			line := dropped[end].Role + ": " + dropped[end].Content + "\n"
+			cost := llm.EstimateTokens(line)
+			if cost > room {
+				if end == from {
+					// A single turn larger than the window is cut to fit.
+					b.WriteString(truncateToTokens(line, room))
+					end++
+				}
+				break
+			}
+			b.WriteString(line)
+			room -= cost
+			end++
+		}
+
+		summary, err := z.llm.Chat(ctx, llm.ChatRequest{
+			Model: z.llm.Model(),
+			Messages: []llm.Message{
+				{Role: "system", Content: summarySystemPrompt()},
+				{Role: "user", Content: b.String()},
+			},
+			Options: map[string]any{
+				"temperature": z.profile.Temperature,
+				"num_ctx":     z.profile.NumCtx,
+				"num_predict": z.profile.NumPredict,
+			},
+		})
+		if err != nil {
+			return "", false, err
+		}
+		prev, from = strings.TrimSpace(summary), end
+
+		z.mu.Lock()
+		z.put(keys[end-1], prev)
+		z.mu.Unlock()
+	}
+	return prev, false, nil
+}
+
+// minSummaryInput keeps chunking going when the profile leaves almost no
+// room for input; such a chunk holds one truncated turn.
+const minSummaryInput = 256
+
+// inputTokens is how much of the summary model's context the user message
+// may use: NumCtx less the output and the system prompt.
+func (z *historySummarizer) inputTokens() int {
+	n := z.profile.NumCtx - z.profile.NumPredict - llm.EstimateMessageTokens(summarySystemPrompt())
+	if n < minSummaryInput {
+		n = minSummaryInput
+	}
+	return n
+}
+
+// reserveTokens is how much of the remaining prompt budget history fitting
+// should leave for the summary message: the summary's num_predict, capped at
+// half of what is left.
+func (z *historySummarizer) reserveTokens(remaining int) int {
+	r := z.profile.NumPredict + llm.EstimateMessageTokens(summaryHeader)
+	if r > remaining/2 {
+		r = remaining / 2
+	}
+	return r
+}
+
+// put stores a summary, evicting the oldest entries beyond max. Callers hold mu.
+func (z *historySummarizer) put(key, summary string) {
+	if _, ok := z.cache[key]; !ok {
+		z.order = append(z.order, key)
+	}
+	z.cache[key] = summary
+	for len(z.order) > z.max {
+		delete(z.cache, z.order[0])
+		z.order = z.order[1:]
+	}
+}
+
+// prefixKeys returns keys[i] = hash(keys[i-1], turn i), identifying each prefix of in.
+func prefixKeys(in []HistoryItem) []string {
+	keys := make([]string, len(in))
+	prev := ""
+	for i, it := range in {
+		h := sha256.New()
+		h.Write([]byte(prev))
+		h.Write([]byte{0})
+		h.Write([]byte(it.Role))
+		h.Write([]byte{0})
+		h.Write([]byte(it.Content))
+		prev = hex.EncodeToString(h.Sum(nil))
+		keys[i] = prev
+	}
+	return keys
+}
+
+func summarySystemPrompt() string {
+	return `Summarize the earlier part of a microservices architecture design conversation.
+Keep: decisions made, constraints and targets (traffic, latency, SLOs), named services/datastores/topics, rejected options, and open questions.
+Drop greetings and repetition. Do not add facts that are not in the turns.
+Return at most 8 short bullet points, no preamble.`
+}
@@ -8,14 +8,27 @@ type HistoryItem struct {
 }
 
 type ChatRequest struct {
-	SpecSummary map[string]any     `json:"spec_summary"`
-	DiagramJSON map[string]any     `json:"diagram_json"`
-	YamlContent string             `json:"yaml_content,omitempty"`
+	SpecSummary map[string]any `json:"spec_summary"`
+	DiagramJSON map[string]any `json:"diagram_json"`
+	YamlContent string         `json:"yaml_content,omitempty"`
+	// Mermaid is an inline flowchart or C4 diagram (Mermaid source).
+	Mermaid string `json:"mermaid,omitempty"`
+	// InfraFiles are docker-compose files and Kubernetes manifests as text.
+	InfraFiles  []types.InfraFile  `json:"infra_files,omitempty"`
 	Attachments []types.Attachment `json:"attachments"`
 	History     []HistoryItem      `json:"history"`
 	Message     string             `json:"message"`
 	Mode        string             `json:"mode,omitempty"`
 	Detail      string             `json:"detail,omitempty"`
+
+	// PreviousDiagramJSON / PreviousYamlContent are an earlier version of the
+	// architecture; when set, the context lists what changed since it.
+	PreviousDiagramJSON map[string]any `json:"previous_diagram_json,omitempty"`
+	PreviousYamlContent string         `json:"previous_yaml_content,omitempty"`
+
+	// ConversationID makes the service load and extend server-side history;
+	// History is ignored when it is set.
+	ConversationID string `json:"conversation_id,omitempty"`
 }
 
 type SourceInfo struct {
@@ -31,8 +44,10 @@ type ChatResponse struct {
 	Signals map[string]any `json:"signals"`
 	Meta    map[string]any `json:"meta,omitempty"`
 
-	Error *struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
-	} `json:"error,omitempty"`
+	Error *ErrorInfo `json:"error,omitempty"`
+}
+
+type ErrorInfo struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
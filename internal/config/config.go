@@ -17,18 +17,67 @@ type Config struct {
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
 
+	// Optional hard caps on history; the token budget (num_ctx of the chosen
+	// profile) trims further. MAX_HISTORY_CHARS=0 disables the character cap.
 	MaxHistoryItems int
 	MaxHistoryChars int
 	LLMConcurrency  int
 
-	LLMProvider   string
+	// Opt-in LLM summary of history turns dropped by the caps or the token budget.
+	HistorySummarize         bool
+	HistorySummaryNumCtx     int
+	HistorySummaryNumPredict int
+	HistorySummaryCacheSize  int
+
+	// RulesConfig is an optional YAML/JSON file that enables, disables and tunes
+	// the structural rules (see internal/context/rules.go).
+	RulesConfig string
+
+	LLMProvider string
+	// Circuit breaker for multi-provider fallback chains (LLM_PROVIDER=ollama,openai).
+	LLMBreakerFailures int
+	LLMBreakerCooldown time.Duration
+
+	// Retry policy applied to each provider (LLM_RETRY_ON lists error kinds, e.g. rate_limited).
+	LLMRetryMaxAttempts int
+	LLMRetryBaseDelay   time.Duration
+	LLMRetryMaxDelay    time.Duration
+	LLMRetryOn          []string
+
 	OllamaURL     string
 	OllamaModel   string
 	OllamaTimeout time.Duration
 
+	OpenAIBaseURL string
+	OpenAIAPIKey  string
+	OpenAIModel   string
+	OpenAITimeout time.Duration
+
 	DomainStrict   bool
 	DomainKeywords []string
 
+	// ConversationStore selects server-side chat history: memory, file, or none.
+	ConversationStore string
+	ConversationDir   string
+	// Bounds for the memory store: conversation count (least recently used
+	// evicted first), turns kept per conversation, and idle time before a
+	// conversation expires. 0 disables each.
+	ConversationMax      int
+	ConversationMaxTurns int
+	ConversationTTL      time.Duration
+
+	// Jobs run the ingest -> fuse -> validate pipeline over uploaded diagrams.
+	// JobsFusion picks the fuser: llm (LLM_PROVIDER), mock for a deterministic spec, or
+	// none to disable the jobs API.
+	JobsDir      string
+	JobsWorkers  int
+	JobsQueue    int
+	JobsFusion   string
+	JobsTimeout  time.Duration
+	JobsMaxFiles int
+	// JobsMaxAttempts bounds the LLM validate-and-repair loop per job.
+	JobsMaxAttempts int
+
 	OllamaNumCtx      int
 	OllamaNumPredict  int
 	OllamaTemperature float64
@@ -57,20 +106,57 @@ func Load() Config {
 		IdleTimeout:  getenvDuration("IDLE_TIMEOUT", 120*time.Second),
 
 		MaxHistoryItems: getenvInt("MAX_HISTORY_ITEMS", 20),
-		MaxHistoryChars: getenvInt("MAX_HISTORY_CHARS", 12000),
+		MaxHistoryChars: getenvInt("MAX_HISTORY_CHARS", 0),
 		LLMConcurrency:  getenvInt("LLM_CONCURRENCY", 2),
 
-		LLMProvider: getenv("LLM_PROVIDER", "ollama"),
+		HistorySummarize:         getenvBool("HISTORY_SUMMARIZE", false),
+		HistorySummaryNumCtx:     getenvInt("HISTORY_SUMMARY_NUM_CTX", 2048),
+		HistorySummaryNumPredict: getenvInt("HISTORY_SUMMARY_NUM_PREDICT", 200),
+		HistorySummaryCacheSize:  getenvInt("HISTORY_SUMMARY_CACHE_SIZE", 256),
+
+		RulesConfig: strings.TrimSpace(getenv("RULES_CONFIG", "")),
+
+		LLMProvider:        getenv("LLM_PROVIDER", "ollama"),
+		LLMBreakerFailures: getenvInt("LLM_BREAKER_FAILURES", 3),
+		LLMBreakerCooldown: getenvDuration("LLM_BREAKER_COOLDOWN", 30*time.Second),
+
+		LLMRetryMaxAttempts: getenvInt("LLM_RETRY_MAX_ATTEMPTS", 2),
+		LLMRetryBaseDelay:   getenvDuration("LLM_RETRY_BASE_DELAY", 500*time.Millisecond),
+		LLMRetryMaxDelay:    getenvDuration("LLM_RETRY_MAX_DELAY", 5*time.Second),
+		LLMRetryOn:          getenvCSV("LLM_RETRY_ON"),
+
 		OllamaURL:   getenv("OLLAMA_URL", "http://localhost:11434"),
 		OllamaModel: getenv("OLLAMA_MODEL", "llama3:instruct"),
 		// Local inference (thinking mode, long prompts) often exceeds 60s.
 		OllamaTimeout: getenvDuration("OLLAMA_TIMEOUT", 180*time.Second),
 
+		// Any OpenAI-compatible server: OpenAI, vLLM, llama.cpp server, LM Studio, gateways.
+		OpenAIBaseURL: getenv("OPENAI_BASE_URL", "https://api.openai.com"),
+		OpenAIAPIKey:  os.Getenv("OPENAI_API_KEY"),
+		OpenAIModel:   getenv("OPENAI_MODEL", "gpt-4o-mini"),
+		OpenAITimeout: getenvDuration("OPENAI_TIMEOUT", 180*time.Second),
+
 		// Default to strict domain filtering so general chit-chat/out-of-scope questions
 		DomainStrict: getenvBool("DOMAIN_STRICT", true),
 
 		DomainKeywords: getenvCSV("DOMAIN_KEYWORDS"),
 
+		ConversationStore: strings.ToLower(getenv("CONVERSATION_STORE", "memory")),
+		ConversationDir:   getenv("CONVERSATION_DIR", "data/conversations"),
+
+		ConversationMax:      getenvInt("CONVERSATION_MAX", 1000),
+		ConversationMaxTurns: getenvInt("CONVERSATION_MAX_TURNS", 200),
+		ConversationTTL:      getenvDuration("CONVERSATION_TTL", 24*time.Hour),
+
+		JobsDir:      getenv("JOBS_DIR", "data/jobs"),
+		JobsWorkers:  getenvInt("JOBS_WORKERS", 2),
+		JobsQueue:    getenvInt("JOBS_QUEUE", 32),
+		JobsFusion:   strings.ToLower(getenv("JOBS_FUSION", "llm")),
+		JobsTimeout:  getenvDuration("JOBS_TIMEOUT", 300*time.Second),
+		JobsMaxFiles: getenvInt("JOBS_MAX_FILES", 10),
+
+		JobsMaxAttempts: getenvInt("JOBS_MAX_ATTEMPTS", 3),
+
 		OllamaNumCtx:      getenvInt("OLLAMA_NUM_CTX", 2048),
 		OllamaNumPredict:  getenvInt("OLLAMA_NUM_PREDICT", 512),
 		OllamaTemperature: getenvFloat("OLLAMA_TEMPERATURE", 0.2),
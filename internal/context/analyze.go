@@ -0,0 +1,192 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// Analysis is the deterministic (no LLM) result of Analyze.
+type Analysis struct {
+	// Source is the payload field the analyzed topology came from:
+	// diagram_json, mermaid, infra_files, yaml_content or spec_summary.
+	Source         string                 `json:"source"`
+	NodesCount     int                    `json:"nodes_count"`
+	EdgesCount     int                    `json:"edges_count"`
+	Findings       []Finding              `json:"findings"`
+	Summary        map[Severity]int       `json:"summary"`
+	Metrics        map[string]any         `json:"metrics,omitempty"`
+	Warnings       []types.DiagramWarning `json:"warnings,omitempty"`
+	YAMLParseError string                 `json:"yaml_parse_error,omitempty"`
+	// InfraFiles is the per-file status of infra_files, as in chat signals.
+	InfraFiles []AttachmentStatus `json:"infra_files,omitempty"`
+}
+
+// Analyze runs the structural rules over the best available topology:
+// diagram_json, else the Mermaid diagram, else infra_files, else the parsed
+// YAML, else spec_summary. When both a diagram
+// and YAML are given, dependencies present in only one of them are reported
+// as yaml_diagram_mismatch findings.
+func Analyze(in Input) Analysis {
+	var a Analysis
+	src := in.topology()
+	d := src.diagram
+	a.Source, a.Warnings, a.YAMLParseError = src.source, src.warnings, src.yamlErr
+	a.InfraFiles = src.infraFiles
+
+	a.NodesCount = len(d.Nodes)
+	a.EdgesCount = len(d.Edges)
+	a.Findings, a.Metrics = orDefault(in.Rules).Evaluate(d)
+	if a.Source == "diagram_json" && src.hasYAML {
+		a.Findings = append(a.Findings, mismatchFindings(d, src.yf)...)
+	}
+	if a.Findings == nil {
+		a.Findings = []Finding{}
+	}
+
+	a.Summary = map[Severity]int{SeverityError: 0, SeverityWarning: 0, SeverityInfo: 0}
+	for _, f := range a.Findings {
+		a.Summary[f.Severity]++
+	}
+	return a
+}
+
+// topologySource is the diagram an Input resolves to.
+type topologySource struct {
+	diagram  types.Diagram
+	source   string
+	warnings []types.DiagramWarning
+	yf       yamlFacts
+	hasYAML  bool
+	yamlErr  string
+	// infraFiles is set whenever infra_files were given, used or not.
+	infraFiles []AttachmentStatus
+}
+
+// topology picks diagram_json, else the Mermaid diagram, else infra_files,
+// else the parsed YAML, else spec_summary. Mermaid that yields no components
+// is skipped with a warning; source is "" when nothing was usable.
+func (in Input) topology() topologySource {
+	var src topologySource
+	if y := strings.TrimSpace(in.YAMLContent); y != "" {
+		src.hasYAML = true
+		var err error
+		src.yf, err = readYAMLFacts(y)
+		if err != nil {
+			src.yamlErr = err.Error()
+		}
+	}
+	var infra types.Diagram
+	if len(in.Infra) > 0 {
+		infra, _, src.infraFiles = ingestInfra(in.Infra)
+	}
+	if len(in.DiagramJSON) > 0 {
+		src.diagram, src.warnings = types.DecodeDiagram(in.DiagramJSON)
+		src.source = "diagram_json"
+		return src
+	}
+	if strings.TrimSpace(in.Mermaid) != "" {
+		d, notes := parseMermaid(in.Mermaid)
+		for _, n := range notes {
+			src.warnings = append(src.warnings, types.DiagramWarning{Path: "mermaid", Code: "mermaid_note", Message: n})
+		}
+		if !d.Empty() {
+			src.diagram, src.source = d, "mermaid"
+			return src
+		}
+		// Unparsable Mermaid (a sequence diagram, a typo) must not hide
+		// the other sources.
+		src.warnings = append(src.warnings, types.DiagramWarning{Path: "mermaid", Code: "mermaid_empty", Message: "no components could be parsed from mermaid; it was ignored"})
+	}
+	switch {
+	case !infra.Empty():
+		src.diagram = infra
+		src.source = "infra_files"
+	case src.yf.arch != nil:
+		src.diagram = src.yf.arch.Diagram()
+		src.source = "yaml_content"
+	case len(in.SpecSummary) > 0:
+		src.diagram = specDiagram(in.SpecSummary)
+		src.source = "spec_summary"
+	}
+	return src
+}
+
+// specDiagram builds a diagram from spec_summary: services (typed through
+// service_types), datastores, and "a -> b" dependency strings.
+func specDiagram(m map[string]any) types.Diagram {
+	d := types.Diagram{Shape: types.ShapeServicesDependencies}
+	serviceTypes := readStringStringMap(m["service_types"])
+	seen := map[string]bool{}
+	add := func(name, typ string) {
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		d.Nodes = append(d.Nodes, types.DiagramNode{ID: name, Label: name, Type: typ})
+	}
+	for _, s := range readStringList(m["services"]) {
+		typ := serviceTypes[s]
+		if typ == "" {
+			typ = "service"
+		}
+		add(s, typ)
+	}
+	for _, s := range readStringList(m["datastores"]) {
+		add(s, "database")
+	}
+	for _, dep := range readStringList(m["dependencies"]) {
+		from, to, ok := splitArrow(dep)
+		if !ok {
+			continue
+		}
+		proto := ""
+		if i := strings.Index(to, "("); i > 0 && strings.HasSuffix(to, ")") {
+			proto = strings.TrimSpace(to[i+1 : len(to)-1])
+			to = strings.TrimSpace(to[:i])
+		}
+		d.Edges = append(d.Edges, types.DiagramEdge{From: from, To: to, Protocol: proto, Mode: types.ProtocolMode(proto)})
+	}
+	return d
+}
+
+// mismatchFindings compares diagram edges with YAML dependency pairs.
+func mismatchFindings(d types.Diagram, yf yamlFacts) []Finding {
+	if len(yf.pairs) == 0 {
+		return nil
+	}
+	diagramDeps := parseDiagramDependencyPairs(d)
+	var out []Finding
+	add := func(pair, only string) {
+		from, to, _ := strings.Cut(pair, "->")
+		out = append(out, Finding{
+			RuleID:      RuleYAMLMismatch,
+			Severity:    SeverityWarning,
+			Title:       fmt.Sprintf("%s -> %s is only in the %s", from, to, only),
+			Edges:       []FindingEdge{{From: from, To: to, FromLabel: from, ToLabel: to}},
+			Explanation: "diagram_json and yaml_content describe different dependencies; one of them is out of date.",
+		})
+	}
+	for _, pair := range sortedPairs(yf.pairs) {
+		if !diagramDeps[pair] {
+			add(pair, "YAML")
+		}
+	}
+	for _, pair := range sortedPairs(diagramDeps) {
+		if !yf.pairs[pair] {
+			add(pair, "diagram")
+		}
+	}
+	return out
+}
+
+func sortedPairs(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
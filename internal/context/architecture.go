@@ -0,0 +1,281 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// Architecture is the typed form of yaml_content. It is parsed with a real YAML
+// decoder, so flow-style lists, anchors/aliases, quoted keys, per-service
+// dependencies and compose-style depends_on maps are all understood.
+type Architecture struct {
+	Services     []ArchComponent
+	Datastores   []ArchComponent
+	Topics       []ArchComponent
+	Dependencies []ArchDependency
+
+	// DependenciesDeclared is true when any dependencies/depends_on key is
+	// present, so an empty Dependencies list was stated rather than omitted.
+	DependenciesDeclared bool
+}
+
+type ArchComponent struct {
+	Name string
+	Type string
+}
+
+// ArchDependency is a directed call or data flow. Mode is types.ModeSync,
+// types.ModeAsync or types.ModeUnknown.
+type ArchDependency struct {
+	From     string
+	To       string
+	Protocol string
+	Mode     string
+}
+
+var errNoArchitectureKeys = errors.New("no services, datastores, topics or dependencies keys found")
+
+var dependencyKeys = []string{"dependencies", "depends_on", "dependsOn", "calls"}
+
+// ParseArchitectureYAML parses an architecture YAML (or JSON) document. It
+// reads services, datastores/databases, topics and dependencies at the top
+// level, plus dependencies declared on each service.
+func ParseArchitectureYAML(src string) (Architecture, error) {
+	var doc any
+	if err := yaml.Unmarshal([]byte(src), &doc); err != nil {
+		return Architecture{}, err
+	}
+	root, ok := doc.(map[string]any)
+	if !ok {
+		return Architecture{}, errors.New("yaml: top level is not a mapping")
+	}
+
+	p := &archParser{seen: map[string]bool{}}
+	found := false
+	if v, ok := root["services"]; ok {
+		found = true
+		p.arch.Services = p.components(v, "service", true)
+	}
+	for _, key := range []string{"datastores", "databases"} {
+		if v, ok := root[key]; ok {
+			found = true
+			p.arch.Datastores = append(p.arch.Datastores, p.components(v, "database", false)...)
+		}
+	}
+	for _, key := range []string{"topics", "queues"} {
+		if v, ok := root[key]; ok {
+			found = true
+			p.arch.Topics = append(p.arch.Topics, p.components(v, "topic", false)...)
+		}
+	}
+	if v, ok := root["dependencies"]; ok {
+		found = true
+		p.arch.DependenciesDeclared = true
+		p.topLevelDependencies(v)
+	}
+	if !found {
+		return Architecture{}, errNoArchitectureKeys
+	}
+	return p.arch, nil
+}
+
+type archParser struct {
+	arch Architecture
+	seen map[string]bool
+}
+
+// components reads a list (names or objects) or a name-keyed map.
+func (p *archParser) components(v any, defType string, withDeps bool) []ArchComponent {
+	var out []ArchComponent
+	add := func(name string, body map[string]any) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return
+		}
+		c := ArchComponent{Name: name, Type: defType}
+		if body != nil {
+			if t := firstString(body, "type", "kind", "engine"); t != "" && defType == "service" {
+				c.Type = strings.ToLower(t)
+			}
+			if withDeps {
+				for _, key := range dependencyKeys {
+					if dv, ok := body[key]; ok {
+						p.arch.DependenciesDeclared = true
+						p.serviceDependencies(name, dv)
+					}
+				}
+			}
+		}
+		out = append(out, c)
+	}
+
+	switch t := v.(type) {
+	case []any:
+		for _, it := range t {
+			switch x := it.(type) {
+			case string:
+				add(x, nil)
+			case map[string]any:
+				add(firstString(x, "name", "id", "service"), x)
+			}
+		}
+	case map[string]any:
+		for _, name := range sortedKeys(t) {
+			body, _ := t[name].(map[string]any)
+			add(name, body)
+		}
+	}
+	return out
+}
+
+// serviceDependencies reads the targets of one service: a list of names or
+// objects, or a map of target name to options (compose long syntax).
+func (p *archParser) serviceDependencies(from string, v any) {
+	switch t := v.(type) {
+	case string:
+		p.addDependency(from, t, "", nil)
+	case []any:
+		for _, it := range t {
+			switch x := it.(type) {
+			case string:
+				p.addDependency(from, x, "", nil)
+			case map[string]any:
+				p.addDependency(from, firstString(x, "to", "target", "service", "name"), firstString(x, "protocol", "kind", "via"), x)
+			}
+		}
+	case map[string]any:
+		for _, to := range sortedKeys(t) {
+			switch x := t[to].(type) {
+			case map[string]any:
+				p.addDependency(from, to, firstString(x, "protocol", "kind", "via"), x)
+			case string:
+				p.addDependency(from, to, x, nil)
+			default:
+				p.addDependency(from, to, "", nil)
+			}
+		}
+	}
+}
+
+// topLevelDependencies reads a list of {from, to, kind} objects or "a -> b"
+// strings, or a map of source name to targets.
+func (p *archParser) topLevelDependencies(v any) {
+	switch t := v.(type) {
+	case []any:
+		for _, it := range t {
+			switch x := it.(type) {
+			case string:
+				if from, to, ok := splitArrow(x); ok {
+					p.addDependency(from, to, "", nil)
+				}
+			case map[string]any:
+				p.addDependency(firstString(x, "from", "source"), firstString(x, "to", "target"), firstString(x, "protocol", "kind", "via"), x)
+			}
+		}
+	case map[string]any:
+		for _, from := range sortedKeys(t) {
+			p.serviceDependencies(from, t[from])
+		}
+	}
+}
+
+func (p *archParser) addDependency(from, to, protocol string, body map[string]any) {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" || to == "" {
+		return
+	}
+	key := strings.ToLower(from) + "->" + strings.ToLower(to)
+	if p.seen[key] {
+		return
+	}
+	p.seen[key] = true
+
+	mode := types.ProtocolMode(protocol)
+	if body != nil {
+		if b, ok := body["sync"].(bool); ok {
+			mode = types.ModeAsync
+			if b {
+				mode = types.ModeSync
+			}
+		} else if b, ok := body["async"].(bool); ok {
+			mode = types.ModeSync
+			if b {
+				mode = types.ModeAsync
+			}
+		}
+	}
+	p.arch.Dependencies = append(p.arch.Dependencies, ArchDependency{From: from, To: to, Protocol: strings.TrimSpace(protocol), Mode: mode})
+}
+
+// Pairs returns the dependency set as lower-cased "from->to" keys.
+func (a Architecture) Pairs() map[string]bool {
+	out := make(map[string]bool, len(a.Dependencies))
+	for _, d := range a.Dependencies {
+		out[strings.ToLower(d.From)+"->"+strings.ToLower(d.To)] = true
+	}
+	return out
+}
+
+// Diagram converts the architecture to the typed diagram model.
+func (a Architecture) Diagram() types.Diagram {
+	d := types.Diagram{Shape: types.ShapeServicesDependencies}
+	seen := map[string]bool{}
+	add := func(c ArchComponent) {
+		if seen[c.Name] {
+			return
+		}
+		seen[c.Name] = true
+		d.Nodes = append(d.Nodes, types.DiagramNode{ID: c.Name, Label: c.Name, Type: c.Type})
+	}
+	for _, c := range a.Services {
+		add(c)
+	}
+	for _, c := range a.Datastores {
+		add(c)
+	}
+	for _, c := range a.Topics {
+		add(c)
+	}
+	for _, dep := range a.Dependencies {
+		d.Edges = append(d.Edges, types.DiagramEdge{From: dep.From, To: dep.To, Protocol: dep.Protocol, Mode: dep.Mode})
+	}
+	return d
+}
+
+func splitArrow(s string) (string, string, bool) {
+	for _, sep := range []string{"->", "→", "=>"} {
+		if i := strings.Index(s, sep); i > 0 {
+			return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+len(sep):]), true
+		}
+	}
+	return "", "", false
+}
+
+func firstString(m map[string]any, keys ...string) string {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case string:
+			if s := strings.TrimSpace(v); s != "" {
+				return s
+			}
+		case int, float64, bool:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
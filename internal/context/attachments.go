@@ -0,0 +1,141 @@
+package context
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/internal/ingest"
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// Attachment parse statuses.
+const (
+	AttachmentParsed      = "parsed"
+	AttachmentEmpty       = "empty"
+	AttachmentUnsupported = "unsupported"
+	AttachmentNoData      = "no_data"
+	AttachmentBadBase64   = "decode_error"
+	AttachmentParseError  = "parse_error"
+)
+
+// AttachmentStatus reports what became of one attachment.
+type AttachmentStatus struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Status string   `json:"status"`
+	Nodes  int      `json:"nodes"`
+	Edges  int      `json:"edges"`
+	Notes  []string `json:"notes,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// ingestAttachments decodes attachments and parses the supported diagram
+// formats in memory. The graphs of all parsed attachments are merged.
+func ingestAttachments(atts []types.Attachment) (types.Diagram, []string, []AttachmentStatus) {
+	var parsed []ingest.ParsedFile
+	var names []string
+	statuses := make([]AttachmentStatus, 0, len(atts))
+	for _, a := range atts {
+		st := AttachmentStatus{Name: a.Name}
+		data, err := decodeAttachment(a.DataBase64)
+		switch {
+		case err != nil:
+			st.Status, st.Error = AttachmentBadBase64, err.Error()
+		case len(data) == 0:
+			st.Status = AttachmentNoData
+		default:
+			st.Type = ingest.DetectContent(a.Name, data)
+			pf, err := ingest.ParseBytes(st.Type, a.Name, data)
+			st.Nodes, st.Edges, st.Notes = len(pf.Nodes), len(pf.Edges), pf.Notes
+			switch {
+			case errors.Is(err, ingest.ErrUnsupported):
+				st.Status = AttachmentUnsupported
+			case err != nil:
+				st.Status, st.Error = AttachmentParseError, err.Error()
+			case len(pf.Nodes) == 0:
+				st.Status = AttachmentEmpty
+			default:
+				st.Status = AttachmentParsed
+				parsed = append(parsed, pf)
+				names = append(names, a.Name)
+			}
+		}
+		statuses = append(statuses, st)
+	}
+	return ingest.BuildIntermediate(parsed).Diagram(), names, statuses
+}
+
+// decodeAttachment accepts plain or URL-safe base64, with or without padding,
+// and strips a data: URL prefix.
+func decodeAttachment(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "data:") {
+		if i := strings.Index(s, ","); i >= 0 {
+			s = s[i+1:]
+		}
+	}
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' {
+			return -1
+		}
+		return r
+	}, s)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("data_base64 is not valid base64")
+}
+
+func attachmentsBlock(atts []types.Attachment, statuses []AttachmentStatus) string {
+	lines := make([]string, 0, len(atts))
+	for i, a := range atts {
+		st := statuses[i]
+		line := fmt.Sprintf("- %s (%s)", a.Name, a.ContentType)
+		switch st.Status {
+		case AttachmentParsed:
+			line += fmt.Sprintf(": parsed as %s, %d nodes, %d edges (included in DIAGRAM CONTEXT)", st.Type, st.Nodes, st.Edges)
+		case AttachmentUnsupported:
+			line += ": content not readable by this service"
+		case AttachmentEmpty:
+			line += ": no components recognized"
+		case AttachmentBadBase64, AttachmentParseError:
+			line += ": could not be read"
+		}
+		lines = append(lines, line)
+	}
+	return "ATTACHMENTS:\n" + strings.Join(lines, "\n")
+}
+
+// nodeLine renders a node as "name (type), ports 8080:80".
+func nodeLine(n types.DiagramNode) string {
+	s := n.Name()
+	if n.Type != "" {
+		s += " (" + n.Type + ")"
+	}
+	if len(n.Ports) > 0 {
+		s += ", ports " + strings.Join(n.Ports, ", ")
+	}
+	return s
+}
+
+// extraGraphText renders an attachment or Mermaid diagram that is shown
+// next to the main diagram rather than instead of it.
+func extraGraphText(from string, d types.Diagram) string {
+	var b strings.Builder
+	b.WriteString("From " + from + ":\n")
+	b.WriteString("Nodes:\n")
+	for _, n := range d.Nodes {
+		b.WriteString("- " + nodeLine(n) + "\n")
+	}
+	if len(d.Edges) > 0 {
+		b.WriteString("Edges:\n")
+		for _, e := range d.Edges {
+			b.WriteString(fmt.Sprintf("- %s -> %s (%s)\n", d.Label(e.From), d.Label(e.To), protocolOrUnknown(e.Protocol)))
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -9,9 +9,61 @@ import (
 	"github.com/MalithGihan/uigp-service/pkg/types"
 )
 
-// maxYAMLChars limits architecture YAML embedded in the LLM system context.
-// Keep this conservative; very large YAML payloads can trigger upstream model timeouts.
-const maxYAMLChars = 4000
+// Block priorities: lower values are kept first when the token budget planner
+// has to trim architecture context.
+const (
+	PriorityConnectivity = iota
+	PriorityDiagram
+	PriorityChanges
+	PrioritySpec
+	PriorityConsistency
+	PriorityYAML
+	PriorityAttachments
+)
+
+// Block is one titled section of architecture context. Blocks are rendered in
+// slice order; Priority decides which ones survive trimming.
+type Block struct {
+	Name     string
+	Text     string
+	Priority int
+}
+
+// JoinBlocks renders blocks the way they are embedded in the system context.
+func JoinBlocks(blocks []Block) string {
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		parts = append(parts, b.Text)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Input is the architecture part of a chat or analyze request.
+type Input struct {
+	SpecSummary map[string]any
+	DiagramJSON map[string]any
+	YAMLContent string
+	// Mermaid is an inline flowchart or C4 diagram, used as the diagram
+	// when DiagramJSON is absent.
+	Mermaid string
+	// Infra are docker-compose files and Kubernetes manifests, used as the
+	// diagram when neither DiagramJSON nor Mermaid is given.
+	Infra       []types.InfraFile
+	Attachments []types.Attachment
+
+	// Rules are the structural checks behind the risk hints; nil runs every
+	// registered rule with its defaults.
+	Rules *RuleSet
+
+	// Previous, when set, is an earlier version of the same architecture; the
+	// context then includes what changed since it.
+	Previous *Input
+}
+
+func (in Input) Empty() bool {
+	return len(in.DiagramJSON) == 0 && len(in.SpecSummary) == 0 && strings.TrimSpace(in.YAMLContent) == "" &&
+		strings.TrimSpace(in.Mermaid) == "" && len(in.Infra) == 0
+}
 
 func BuildCompactContext(
 	specSummary map[string]any,
@@ -19,22 +71,108 @@ func BuildCompactContext(
 	yamlContent string,
 	atts []types.Attachment,
 ) (text string, used string, signals map[string]any) {
+	blocks, used, signals := BuildContextBlocks(Input{SpecSummary: specSummary, DiagramJSON: diagramJSON, YAMLContent: yamlContent, Attachments: atts})
+	return JoinBlocks(blocks), used, signals
+}
+
+// BuildContextBlocks builds the architecture context as separate blocks so the
+// caller can fit them into a token budget. Blocks are not size-limited here.
+func BuildContextBlocks(in Input) (blocks []Block, used string, signals map[string]any) {
+	specSummary, diagramJSON, yamlContent, atts := in.SpecSummary, in.DiagramJSON, in.YAMLContent, in.Attachments
+	rules := orDefault(in.Rules)
 
 	signals = map[string]any{}
 
-	var blocks []string
 	var usedParts []string
 
 	// Prefer diagram_json first (more ground truth), but include spec_summary too if provided.
-	if diagramJSON != nil && len(diagramJSON) > 0 {
-		t, sig := compactFromDiagram(diagramJSON)
+	diagram, warnings := types.DecodeDiagram(diagramJSON)
+	if len(warnings) > 0 {
+		signals["diagram_warnings"] = warnings
+	}
+	var attDiagram types.Diagram
+	var attNames []string
+	var attStatus []AttachmentStatus
+	if len(atts) > 0 {
+		attDiagram, attNames, attStatus = ingestAttachments(atts)
+	}
+	mmd := mermaidDiagram(in.Mermaid, signals)
+	infra, infraNames := infraDiagram(in.Infra, signals)
+	infraFrom := "infra_files (" + strings.Join(infraNames, ", ") + ")"
+	switch {
+	case len(diagramJSON) > 0:
+		t, sig := compactFromDiagram(diagram, rules)
 		for k, v := range sig {
 			signals[k] = v
 		}
+		if !mmd.Empty() {
+			t += "\n" + extraGraphText("mermaid", mmd)
+		}
+		if !infra.Empty() {
+			t += "\n" + extraGraphText(infraFrom, infra)
+		}
+		if !attDiagram.Empty() {
+			t += "\n" + extraGraphText("attachments ("+strings.Join(attNames, ", ")+")", attDiagram)
+		}
 		if strings.TrimSpace(t) != "" {
-			blocks = append(blocks, "DIAGRAM CONTEXT:\n"+t)
+			blocks = append(blocks, Block{Name: "diagram", Text: "DIAGRAM CONTEXT:\n" + t, Priority: PriorityDiagram})
 			usedParts = append(usedParts, "diagram_json")
 		}
+	case !mmd.Empty():
+		// Without diagram_json, the inline Mermaid diagram is the diagram.
+		diagram = mmd
+		t, sig := compactFromDiagram(diagram, rules)
+		for k, v := range sig {
+			signals[k] = v
+		}
+		if !infra.Empty() {
+			t += "\n" + extraGraphText(infraFrom, infra)
+		}
+		if !attDiagram.Empty() {
+			t += "\n" + extraGraphText("attachments ("+strings.Join(attNames, ", ")+")", attDiagram)
+		}
+		signals["diagram_source"] = "mermaid"
+		blocks = append(blocks, Block{Name: "diagram", Text: "DIAGRAM CONTEXT (from mermaid):\n" + t, Priority: PriorityDiagram})
+	case !infra.Empty():
+		// Without diagram_json or mermaid, the compose/Kubernetes graph is
+		// the diagram.
+		diagram = infra
+		t, sig := compactFromDiagram(diagram, rules)
+		for k, v := range sig {
+			signals[k] = v
+		}
+		if !attDiagram.Empty() {
+			t += "\n" + extraGraphText("attachments ("+strings.Join(attNames, ", ")+")", attDiagram)
+		}
+		signals["diagram_source"] = "infra_files"
+		blocks = append(blocks, Block{Name: "diagram", Text: "DIAGRAM CONTEXT (from " + infraFrom + "):\n" + t, Priority: PriorityDiagram})
+	case !attDiagram.Empty():
+		// Without diagram_json, parsed attachments are the diagram.
+		diagram = attDiagram
+		t, sig := compactFromDiagram(diagram, rules)
+		for k, v := range sig {
+			signals[k] = v
+		}
+		signals["diagram_source"] = "attachments"
+		blocks = append(blocks, Block{Name: "diagram", Text: "DIAGRAM CONTEXT (from attachments: " + strings.Join(attNames, ", ") + "):\n" + t, Priority: PriorityDiagram})
+		// "attachments" alone may be unreadable files; this part marks
+		// attachments that became the diagram.
+		usedParts = append(usedParts, "attachment_diagram")
+	}
+	if !mmd.Empty() {
+		usedParts = append(usedParts, "mermaid")
+	}
+	if !infra.Empty() {
+		usedParts = append(usedParts, "infra_files")
+	}
+
+	if in.Previous != nil && !in.Previous.Empty() && !in.Empty() {
+		d := DiffArchitectures(*in.Previous, in)
+		blocks = append(blocks, Block{Name: "changes", Text: changesBlock(d), Priority: PriorityChanges})
+		for k, v := range diffSignals(d) {
+			signals[k] = v
+		}
+		usedParts = append(usedParts, "previous_version")
 	}
 
 	if specSummary != nil && len(specSummary) > 0 {
@@ -43,79 +181,100 @@ func BuildCompactContext(
 			signals[k] = v
 		}
 		if strings.TrimSpace(t) != "" {
-			blocks = append(blocks, "SPEC SUMMARY:\n"+t)
+			blocks = append(blocks, Block{Name: "spec_summary", Text: "SPEC SUMMARY:\n" + t, Priority: PrioritySpec})
 			usedParts = append(usedParts, "spec_summary")
 		}
 	}
 
 	yamlContent = strings.TrimSpace(yamlContent)
+	var yf yamlFacts
 	if yamlContent != "" {
-		yl := yamlContent
-		truncated := false
-		if len(yl) > maxYAMLChars {
-			yl = yl[:maxYAMLChars] + "\n... [yaml truncated for context size]"
-			truncated = true
-		}
-		blocks = append(blocks, compactYAMLContextBlock(yl))
+		var err error
+		yf, err = readYAMLFacts(yamlContent)
+		if err != nil {
+			signals["yaml_parse_error"] = err.Error()
+			signals["yaml_parse_fallback"] = true
+		} else {
+			signals["yaml_parsed"] = true
+			signals["yaml_services_count"] = len(yf.arch.Services)
+			signals["yaml_dependencies_count"] = len(yf.arch.Dependencies)
+		}
+		blocks = append(blocks, Block{Name: "yaml", Text: compactYAMLContextBlock(yf), Priority: PriorityYAML})
 		usedParts = append(usedParts, "yaml_content")
 		signals["yaml_chars"] = len(yamlContent)
-		if truncated {
-			signals["yaml_truncated"] = true
-		}
 	}
 
-	if depNote, depSig := dependencyConsistencyNote(diagramJSON, yamlContent); depNote != "" {
-		blocks = append(blocks, depNote)
+	if depNote, depSig := dependencyConsistencyNote(diagram, yf); depNote != "" {
+		blocks = append(blocks, Block{Name: "dependency_consistency", Text: depNote, Priority: PriorityConsistency})
 		for k, v := range depSig {
 			signals[k] = v
 		}
 	}
 
 	if len(atts) > 0 {
-		var lines []string
-		for _, a := range atts {
-			lines = append(lines, fmt.Sprintf("- %s (%s)", a.Name, a.ContentType))
-		}
 		signals["attachments_detected"] = len(atts)
-		blocks = append(blocks, "ATTACHMENTS:\n"+strings.Join(lines, "\n"))
+		signals["attachments_parsed"] = len(attNames)
+		signals["attachment_dependencies_count"] = len(attDiagram.Edges)
+		signals["attachments"] = attStatus
+		blocks = append(blocks, Block{Name: "attachments", Text: attachmentsBlock(atts, attStatus), Priority: PriorityAttachments})
 		usedParts = append(usedParts, "attachments")
 	}
 
 	if len(blocks) == 0 {
-		return "", "none", signals
+		return nil, "none", signals
 	}
 
-	if note := connectivityAuthoritativeNote(signals, yamlContent); note != "" {
-		blocks = append(blocks, note)
+	if note := connectivityAuthoritativeNote(signals, yamlContent != "", yf); note != "" {
+		blocks = append(blocks, Block{Name: "connectivity", Text: note, Priority: PriorityConnectivity})
 		signals["connectivity_all_sources_empty"] = true
 	}
 
-	return strings.Join(blocks, "\n\n"), strings.Join(usedParts, "+"), signals
+	return blocks, strings.Join(usedParts, "+"), signals
+}
+
+// yamlFacts is what the builder needs from yaml_content: the parsed
+// Architecture when the document decodes, otherwise the line heuristics below.
+type yamlFacts struct {
+	arch          *Architecture
+	pairs         map[string]bool
+	declaredEmpty bool
 }
 
-func compactYAMLContextBlock(yaml string) string {
-	depPairs := parseYAMLDependencyPairs(yaml)
-	if len(depPairs) == 0 {
-		if yamlShowsDependenciesAsEmptyList(yaml) {
+func readYAMLFacts(y string) (yamlFacts, error) {
+	arch, err := ParseArchitectureYAML(y)
+	if err != nil {
+		return yamlFacts{
+			pairs:         parseYAMLDependencyPairs(y),
+			declaredEmpty: yamlShowsDependenciesAsEmptyList(y),
+		}, err
+	}
+	return yamlFacts{
+		arch:          &arch,
+		pairs:         arch.Pairs(),
+		declaredEmpty: arch.DependenciesDeclared && len(arch.Dependencies) == 0,
+	}, nil
+}
+
+func compactYAMLContextBlock(yf yamlFacts) string {
+	if yf.arch != nil {
+		return compactArchitectureBlock(*yf.arch)
+	}
+	if len(yf.pairs) == 0 {
+		if yf.declaredEmpty {
 			return "ARCHITECTURE YAML:\nDependencies: [] (explicitly empty in YAML)."
 		}
 		return "ARCHITECTURE YAML:\nYAML is present for this version, but no explicit dependency pairs were extracted."
 	}
 
-	deps := make([]string, 0, len(depPairs))
-	for dep := range depPairs {
+	deps := make([]string, 0, len(yf.pairs))
+	for dep := range yf.pairs {
 		deps = append(deps, dep)
 	}
 	sort.Strings(deps)
 
-	const maxLines = 60
 	var b strings.Builder
 	b.WriteString("ARCHITECTURE YAML (dependency pairs extracted):\n")
-	for i, dep := range deps {
-		if i >= maxLines {
-			b.WriteString("- ... additional dependencies omitted\n")
-			break
-		}
+	for _, dep := range deps {
 		b.WriteString("- ")
 		b.WriteString(dep)
 		b.WriteString("\n")
@@ -123,6 +282,52 @@ func compactYAMLContextBlock(yaml string) string {
 	return strings.TrimSpace(b.String())
 }
 
+func compactArchitectureBlock(a Architecture) string {
+	var b strings.Builder
+	b.WriteString("ARCHITECTURE YAML:\n")
+	writeComponents := func(title string, cs []ArchComponent, showType bool) {
+		if len(cs) == 0 {
+			return
+		}
+		names := make([]string, 0, len(cs))
+		for _, c := range cs {
+			if showType && c.Type != "" && c.Type != "service" {
+				names = append(names, c.Name+" ("+c.Type+")")
+			} else {
+				names = append(names, c.Name)
+			}
+		}
+		b.WriteString(title + ": " + strings.Join(names, ", ") + "\n")
+	}
+	writeComponents("Services", a.Services, true)
+	writeComponents("Datastores", a.Datastores, false)
+	writeComponents("Topics", a.Topics, false)
+
+	switch {
+	case len(a.Dependencies) > 0:
+		b.WriteString("Dependencies:\n")
+		for _, d := range a.Dependencies {
+			b.WriteString("- " + d.From + " -> " + d.To)
+			var attrs []string
+			if d.Protocol != "" {
+				attrs = append(attrs, d.Protocol)
+			}
+			if d.Mode != "" {
+				attrs = append(attrs, d.Mode)
+			}
+			if len(attrs) > 0 {
+				b.WriteString(" (" + strings.Join(attrs, ", ") + ")")
+			}
+			b.WriteString("\n")
+		}
+	case a.DependenciesDeclared:
+		b.WriteString("Dependencies: [] (explicitly empty in YAML).\n")
+	default:
+		b.WriteString("Dependencies: none declared in YAML.\n")
+	}
+	return strings.TrimSpace(b.String())
+}
+
 // yamlShowsDependenciesAsEmptyList is true when YAML clearly has dependencies: [] (possibly indented).
 func yamlShowsDependenciesAsEmptyList(y string) bool {
 	y = strings.TrimSpace(y)
@@ -137,39 +342,11 @@ func yamlShowsDependenciesAsEmptyList(y string) bool {
 	return regexp.MustCompile(`(?m)\bdependencies:\s*\[\s*\]`).MatchString(y)
 }
 
-// yamlShowsDependencyObjects returns true when YAML lists at least one dependency object under dependencies.
-func yamlShowsDependencyObjects(y string) bool {
-	y = strings.TrimSpace(y)
-	if y == "" {
-		return false
-	}
-	i := strings.Index(y, "dependencies:")
-	if i < 0 {
-		return false
-	}
-	rest := y[i+len("dependencies:"):]
-	// next top-level key at column 0 ends the section
-	end := len(rest)
-	for j := 1; j < len(rest); j++ {
-		if rest[j] == '\n' && j+1 < len(rest) {
-			c := rest[j+1]
-			if c != ' ' && c != '\t' && c != '\r' && c != '\n' && c != '#' {
-				// start of a new top-level line (letter or quote)
-				if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
-					end = j + 1
-					break
-				}
-			}
-		}
-	}
-	section := rest[:end]
-	return regexp.MustCompile(`(?m)^\s*-\s+from:`).MatchString(section)
-}
-
-func connectivityAuthoritativeNote(signals map[string]any, yaml string) string {
+func connectivityAuthoritativeNote(signals map[string]any, hasYAML bool, yf yamlFacts) string {
 	diagDeps := intFromSignals(signals, "dependencies_count")
 	specDeps := intFromSignals(signals, "spec_dependencies_count")
-	if diagDeps > 0 || specDeps > 0 {
+	attDeps := intFromSignals(signals, "attachment_dependencies_count")
+	if diagDeps > 0 || specDeps > 0 || attDeps > 0 {
 		return ""
 	}
 	comp := intFromSignals(signals, "services_count")
@@ -180,15 +357,13 @@ func connectivityAuthoritativeNote(signals map[string]any, yaml string) string {
 	if comp < 1 {
 		return ""
 	}
-	// YAML with real dependency objects overrides "empty" heuristics
-	if yamlShowsDependencyObjects(yaml) {
+	// YAML with real dependencies overrides "empty" heuristics
+	if len(yf.pairs) > 0 {
 		return ""
 	}
-	if !yamlShowsDependenciesAsEmptyList(yaml) {
-		// YAML present but we cannot confirm an empty dependency list — skip the strong banner
-		if strings.TrimSpace(yaml) != "" {
-			return ""
-		}
+	// YAML present but we cannot confirm an empty dependency list — skip the strong banner
+	if hasYAML && !yf.declaredEmpty {
+		return ""
 	}
 
 	var b strings.Builder
@@ -273,102 +448,31 @@ func compactFromSpecSummary(m map[string]any) (string, map[string]any) {
 	return strings.TrimSpace(b.String()), sig
 }
 
-func compactFromDiagram(m map[string]any) (string, map[string]any) {
+func compactFromDiagram(d types.Diagram, rules *RuleSet) (string, map[string]any) {
 	sig := map[string]any{}
 
-	// Build id->label and id->type so edges and entry hints use human-readable names
+	// id->label so edges and entry hints use human-readable names
 	idToLabel := map[string]string{}
-	idToType := map[string]string{}
-	if nv, ok := m["nodes"].([]any); ok {
-		for _, v := range nv {
-			if nm, ok := v.(map[string]any); ok {
-				id := fmt.Sprint(nm["id"])
-				lbl := fmt.Sprint(nm["label"])
-				if id != "" && id != "<nil>" && lbl != "" && lbl != "<nil>" {
-					idToLabel[id] = lbl
-				}
-				typ := strings.ToLower(strings.TrimSpace(fmt.Sprint(nm["type"])))
-				if id != "" && id != "<nil>" && typ != "" && typ != "<nil>" {
-					idToType[id] = typ
-				}
-			}
+	for _, n := range d.Nodes {
+		if n.Label != "" {
+			idToLabel[n.ID] = n.Label
 		}
 	}
 
 	var services []string
 	var deps []string
-
-	// support both “services/dependencies” and “nodes/edges”
-	if sv, ok := m["services"].([]any); ok {
-		for _, v := range sv {
-			switch t := v.(type) {
-			case string:
-				services = append(services, t)
-			case map[string]any:
-				if n, ok := t["name"].(string); ok {
-					services = append(services, n)
-				}
-			}
-		}
-	}
-	if dv, ok := m["dependencies"].([]any); ok {
-		for _, v := range dv {
-			if dm, ok := v.(map[string]any); ok {
-				f := fmt.Sprint(dm["from"])
-				to := fmt.Sprint(dm["to"])
-				kind := fmt.Sprint(dm["kind"])
-				deps = append(deps, fmt.Sprintf("%s -> %s (%s)", f, to, kind))
-			}
-		}
-	}
-
-	if len(services) == 0 {
-		if nv, ok := m["nodes"].([]any); ok {
-			for _, v := range nv {
-				if nm, ok := v.(map[string]any); ok {
-					lbl := fmt.Sprint(nm["label"])
-					typ := fmt.Sprint(nm["type"])
-					if lbl == "" || lbl == "<nil>" {
-						continue
-					}
-					if typ != "" && typ != "<nil>" {
-						services = append(services, fmt.Sprintf("%s (%s)", lbl, typ))
-					} else {
-						services = append(services, lbl)
-					}
-				}
-			}
-		}
+	for _, n := range d.Nodes {
+		services = append(services, nodeLine(n))
 	}
-	if len(deps) == 0 {
-		if ev, ok := m["edges"].([]any); ok {
-			for _, v := range ev {
-				if em, ok := v.(map[string]any); ok {
-					from := fmt.Sprint(em["from"])
-					to := fmt.Sprint(em["to"])
-					proto := fmt.Sprint(em["protocol"])
-
-					if lbl, ok := idToLabel[from]; ok {
-						from = lbl
-					}
-					if lbl, ok := idToLabel[to]; ok {
-						to = lbl
-					}
-					deps = append(deps, fmt.Sprintf("%s -> %s (%s)", from, to, proto))
-				}
-			}
-		}
+	for _, e := range d.Edges {
+		deps = append(deps, fmt.Sprintf("%s -> %s (%s)", nodeLabel(e.From, idToLabel), nodeLabel(e.To, idToLabel), protocolOrUnknown(e.Protocol)))
 	}
 
 	sig["services_count"] = len(services)
 	sig["dependencies_count"] = len(deps)
 
-	// include diagram_version_id if present
-	if md, ok := m["metadata"].(map[string]any); ok {
-		dv := fmt.Sprint(md["diagram_version_id"])
-		if dv != "" && dv != "<nil>" {
-			sig["diagram_version_id"] = dv
-		}
+	if d.Metadata.DiagramVersionID != "" {
+		sig["diagram_version_id"] = d.Metadata.DiagramVersionID
 	}
 
 	var b strings.Builder
@@ -392,7 +496,7 @@ func compactFromDiagram(m map[string]any) (string, map[string]any) {
 	}
 
 	// Highlight outbound edges from user-facing / entry nodes (client, user, external)
-	entryOutbound := diagramEntryOutboundLines(m, idToLabel, idToType)
+	entryOutbound := diagramEntryOutboundLines(d, idToLabel)
 	if len(entryOutbound) > 0 {
 		b.WriteString("Entry / user-facing connectivity (outbound from client, user, or external nodes):\n")
 		for _, line := range entryOutbound {
@@ -402,7 +506,11 @@ func compactFromDiagram(m map[string]any) (string, map[string]any) {
 	}
 
 	// Precomputed structural hints reduce reasoning misses for smaller LLMs.
-	if hintsText, hintSignals := diagramRiskHints(m, idToLabel, idToType); hintsText != "" {
+	findings, metrics := rules.Evaluate(d)
+	for k, v := range metrics {
+		sig[k] = v
+	}
+	if hintsText, hintSignals := rules.renderRiskHints(findings); hintsText != "" {
 		b.WriteString("Structural risk hints (precomputed from topology):\n")
 		b.WriteString(hintsText)
 		if !strings.HasSuffix(hintsText, "\n") {
@@ -414,183 +522,22 @@ func compactFromDiagram(m map[string]any) (string, map[string]any) {
 	}
 
 	if b.Len() == 0 {
-		b.WriteString("Diagram JSON provided but no known keys found (expected services/dependencies or nodes/edges).")
+		b.WriteString("Diagram JSON provided but no nodes or edges could be decoded (expected services/dependencies or nodes/edges).")
 	}
 	return strings.TrimSpace(b.String()), sig
 }
 
-type topoEdge struct {
-	FromID string
-	ToID   string
-	Proto  string
-}
-
-func diagramRiskHints(m map[string]any, idToLabel map[string]string, idToType map[string]string) (string, map[string]any) {
+func dependencyConsistencyNote(d types.Diagram, yf yamlFacts) (string, map[string]any) {
 	sig := map[string]any{}
-	edges := parseTopoEdges(m)
-	if len(idToType) == 0 {
+	if d.Empty() {
 		return "", sig
 	}
 
-	hasGateway := false
-	for _, t := range idToType {
-		if t == "gateway" {
-			hasGateway = true
-			break
-		}
-	}
-
-	incident := make(map[string]int, len(idToType))
-	inbound := make(map[string][]topoEdge, len(idToType))
-	outbound := make(map[string][]topoEdge, len(idToType))
-	for _, e := range edges {
-		incident[e.FromID]++
-		incident[e.ToID]++
-		outbound[e.FromID] = append(outbound[e.FromID], e)
-		inbound[e.ToID] = append(inbound[e.ToID], e)
-	}
-
-	entryTypes := map[string]bool{"client": true, "user": true, "external": true}
-	internalTypes := map[string]bool{"service": true, "gateway": true, "db": true, "database": true, "datastore": true, "topic": true, "queue": true}
-	dbTypes := map[string]bool{"db": true, "database": true, "datastore": true}
-
-	var orphan []string
-	for id, typ := range idToType {
-		if incident[id] != 0 {
-			continue
-		}
-		_ = typ
-		orphan = append(orphan, nodeLabel(id, idToLabel))
-	}
-	if len(orphan) > 0 {
-		sig["orphan_components_count"] = len(orphan)
-	}
-
-	var gatewayBypass []string
-	if hasGateway {
-		for _, e := range edges {
-			ft := idToType[e.FromID]
-			tt := idToType[e.ToID]
-			if !entryTypes[ft] {
-				continue
-			}
-			if tt == "gateway" {
-				continue
-			}
-			if internalTypes[tt] {
-				gatewayBypass = append(gatewayBypass, fmt.Sprintf("%s -> %s", nodeLabel(e.FromID, idToLabel), nodeLabel(e.ToID, idToLabel)))
-			}
-		}
-		if len(gatewayBypass) > 0 {
-			sig["gateway_bypass_count"] = len(gatewayBypass)
-		}
-	}
-
-	// Shared DB fan-in: more than one service writing/calling the same DB.
-	dbCallers := map[string]map[string]bool{}
-	for _, e := range edges {
-		ft := idToType[e.FromID]
-		tt := idToType[e.ToID]
-		if ft != "service" || !dbTypes[tt] {
-			continue
-		}
-		if dbCallers[e.ToID] == nil {
-			dbCallers[e.ToID] = map[string]bool{}
-		}
-		dbCallers[e.ToID][e.FromID] = true
-	}
-	var sharedDB []string
-	for dbID, callers := range dbCallers {
-		if len(callers) > 1 {
-			services := make([]string, 0, len(callers))
-			for sid := range callers {
-				services = append(services, nodeLabel(sid, idToLabel))
-			}
-			sharedDB = append(sharedDB, fmt.Sprintf("%s <= %s", nodeLabel(dbID, idToLabel), strings.Join(services, ", ")))
-		}
-	}
-	if len(sharedDB) > 0 {
-		sig["shared_db_fanin_count"] = len(sharedDB)
-	}
-
-	var dbOutbound []string
-	var externalDB []string
-	for _, e := range edges {
-		ft := idToType[e.FromID]
-		tt := idToType[e.ToID]
-		if dbTypes[ft] {
-			dbOutbound = append(dbOutbound, fmt.Sprintf("%s -> %s", nodeLabel(e.FromID, idToLabel), nodeLabel(e.ToID, idToLabel)))
-			if entryTypes[tt] {
-				externalDB = append(externalDB, fmt.Sprintf("%s -> %s", nodeLabel(e.FromID, idToLabel), nodeLabel(e.ToID, idToLabel)))
-			}
-		}
-		if dbTypes[tt] && entryTypes[ft] {
-			externalDB = append(externalDB, fmt.Sprintf("%s -> %s", nodeLabel(e.FromID, idToLabel), nodeLabel(e.ToID, idToLabel)))
-		}
-	}
-	if len(dbOutbound) > 0 {
-		sig["db_outbound_edges_count"] = len(dbOutbound)
-	}
-	if len(externalDB) > 0 {
-		sig["external_db_direct_edges_count"] = len(externalDB)
-	}
-
-	cycleCount := countCycles(edges)
-	if cycleCount > 0 {
-		sig["cycle_count"] = cycleCount
-	}
-
-	var missingProtocol []string
-	for _, e := range edges {
-		if strings.TrimSpace(e.Proto) != "" {
-			continue
-		}
-		missingProtocol = append(missingProtocol, fmt.Sprintf("%s -> %s", nodeLabel(e.FromID, idToLabel), nodeLabel(e.ToID, idToLabel)))
-	}
-	if len(missingProtocol) > 0 {
-		sig["missing_protocol_edges_count"] = len(missingProtocol)
-	}
-
-	var lines []string
-	if len(orphan) > 0 {
-		lines = append(lines, "- orphan/disconnected nodes: "+strings.Join(orphan, ", "))
-	}
-	if len(gatewayBypass) > 0 {
-		lines = append(lines, "- gateway bypass edges: "+strings.Join(gatewayBypass, "; "))
-	}
-	if len(sharedDB) > 0 {
-		lines = append(lines, "- shared database fan-in: "+strings.Join(sharedDB, "; "))
-	}
-	if len(dbOutbound) > 0 {
-		lines = append(lines, "- database outbound edges (suspicious): "+strings.Join(dbOutbound, "; "))
-	}
-	if len(externalDB) > 0 {
-		lines = append(lines, "- direct external<->database access: "+strings.Join(externalDB, "; "))
-	}
-	if cycleCount > 0 {
-		lines = append(lines, fmt.Sprintf("- dependency cycles detected: %d", cycleCount))
-	}
-	if len(missingProtocol) > 0 {
-		lines = append(lines, "- edges with missing protocol values: "+strings.Join(missingProtocol, "; "))
-	}
-	if len(lines) == 0 {
-		return "", sig
-	}
-	return strings.Join(lines, "\n") + "\n", sig
-}
-
-func dependencyConsistencyNote(diagramJSON map[string]any, yamlContent string) (string, map[string]any) {
-	sig := map[string]any{}
-	yamlContent = strings.TrimSpace(yamlContent)
-	if len(diagramJSON) == 0 || yamlContent == "" {
-		return "", sig
-	}
-
-	yamlDeps := parseYAMLDependencyPairs(yamlContent)
+	yamlDeps := yf.pairs
 	if len(yamlDeps) == 0 {
 		return "", sig
 	}
-	diagramDeps := parseDiagramDependencyPairs(diagramJSON)
+	diagramDeps := parseDiagramDependencyPairs(d)
 	if len(diagramDeps) == 0 {
 		// If diagram has no edges but YAML has deps, it's a strong inconsistency.
 		sig["yaml_diagram_dependency_mismatch_count"] = len(yamlDeps)
@@ -668,74 +615,18 @@ func parseYAMLDependencyPairs(y string) map[string]bool {
 	return out
 }
 
-func parseDiagramDependencyPairs(m map[string]any) map[string]bool {
+func parseDiagramDependencyPairs(d types.Diagram) map[string]bool {
 	out := map[string]bool{}
-	idToLabel := map[string]string{}
-	if nv, ok := m["nodes"].([]any); ok {
-		for _, v := range nv {
-			nm, ok := v.(map[string]any)
-			if !ok {
-				continue
-			}
-			id := strings.TrimSpace(fmt.Sprint(nm["id"]))
-			lbl := strings.TrimSpace(fmt.Sprint(nm["label"]))
-			if id == "" || id == "<nil>" || lbl == "" || lbl == "<nil>" {
-				continue
-			}
-			idToLabel[id] = lbl
-		}
-	}
-	for _, e := range parseTopoEdges(m) {
-		from := nodeLabel(e.FromID, idToLabel)
-		to := nodeLabel(e.ToID, idToLabel)
-		from = strings.TrimSpace(strings.ToLower(from))
-		to = strings.TrimSpace(strings.ToLower(to))
-		if from == "" || to == "" || from == "<nil>" || to == "<nil>" {
-			continue
-		}
-		out[from+"->"+to] = true
-	}
-	return out
-}
-
-func parseTopoEdges(m map[string]any) []topoEdge {
-	var out []topoEdge
-	if ev, ok := m["edges"].([]any); ok {
-		for _, v := range ev {
-			em, ok := v.(map[string]any)
-			if !ok {
-				continue
-			}
-			from := strings.TrimSpace(fmt.Sprint(em["from"]))
-			to := strings.TrimSpace(fmt.Sprint(em["to"]))
-			if from == "" || from == "<nil>" || to == "" || to == "<nil>" {
-				continue
-			}
-			proto := strings.TrimSpace(fmt.Sprint(em["protocol"]))
-			if proto == "<nil>" {
-				proto = ""
-			}
-			out = append(out, topoEdge{FromID: from, ToID: to, Proto: proto})
+	idx := d.NodeIndex()
+	for _, e := range d.Edges {
+		from, to := e.From, e.To
+		if n, ok := idx[from]; ok {
+			from = n.Name()
 		}
-		return out
-	}
-	if dv, ok := m["dependencies"].([]any); ok {
-		for _, v := range dv {
-			dm, ok := v.(map[string]any)
-			if !ok {
-				continue
-			}
-			from := strings.TrimSpace(fmt.Sprint(dm["from"]))
-			to := strings.TrimSpace(fmt.Sprint(dm["to"]))
-			if from == "" || from == "<nil>" || to == "" || to == "<nil>" {
-				continue
-			}
-			proto := strings.TrimSpace(fmt.Sprint(dm["kind"]))
-			if proto == "<nil>" {
-				proto = ""
-			}
-			out = append(out, topoEdge{FromID: from, ToID: to, Proto: proto})
+		if n, ok := idx[to]; ok {
+			to = n.Name()
 		}
+		out[strings.ToLower(from)+"->"+strings.ToLower(to)] = true
 	}
 	return out
 }
@@ -747,86 +638,29 @@ func nodeLabel(id string, idToLabel map[string]string) string {
 	return id
 }
 
-func countCycles(edges []topoEdge) int {
-	adj := map[string][]string{}
-	nodes := map[string]bool{}
-	for _, e := range edges {
-		adj[e.FromID] = append(adj[e.FromID], e.ToID)
-		nodes[e.FromID] = true
-		nodes[e.ToID] = true
-	}
-	seen := map[string]bool{}
-	stack := map[string]bool{}
-	cycles := 0
-
-	var dfs func(string)
-	dfs = func(n string) {
-		seen[n] = true
-		stack[n] = true
-		for _, nxt := range adj[n] {
-			if !seen[nxt] {
-				dfs(nxt)
-				continue
-			}
-			if stack[nxt] {
-				cycles++
-			}
-		}
-		stack[n] = false
-	}
-
-	for n := range nodes {
-		if !seen[n] {
-			dfs(n)
-		}
-	}
-	return cycles
-}
-
 // diagramEntryOutboundLines lists edges whose source node type is client, user, or external.
-func diagramEntryOutboundLines(
-	m map[string]any,
-	idToLabel map[string]string,
-	idToType map[string]string,
-) []string {
-	ev, ok := m["edges"].([]any)
-	if !ok || len(ev) == 0 {
-		return nil
-	}
+func diagramEntryOutboundLines(d types.Diagram, idToLabel map[string]string) []string {
 	entryKind := map[string]bool{
-		"client": true, "user": true, "external": true,
+		"client": true, "user": true, "external": true, "actor": true, "ext": true,
 	}
+	idx := d.NodeIndex()
 	var lines []string
-	for _, v := range ev {
-		em, ok := v.(map[string]any)
-		if !ok {
-			continue
-		}
-		fromID := strings.TrimSpace(fmt.Sprint(em["from"]))
-		if fromID == "" || fromID == "<nil>" {
-			continue
-		}
-		if !entryKind[idToType[fromID]] {
+	for _, e := range d.Edges {
+		if !entryKind[idx[e.From].Type] {
 			continue
 		}
-		toID := strings.TrimSpace(fmt.Sprint(em["to"]))
-		fromLbl := fromID
-		if lb, ok := idToLabel[fromID]; ok && lb != "" {
-			fromLbl = lb
-		}
-		toLbl := toID
-		if lb, ok := idToLabel[toID]; ok && lb != "" {
-			toLbl = lb
-		}
-		proto := strings.TrimSpace(fmt.Sprint(em["protocol"]))
-		if proto == "" || proto == "<nil>" {
-			proto = "?"
-		}
-		lines = append(lines, fmt.Sprintf("%s → %s (%s)", fromLbl, toLbl, proto))
+		lines = append(lines, fmt.Sprintf("%s → %s (%s)", nodeLabel(e.From, idToLabel), nodeLabel(e.To, idToLabel), protocolOrUnknown(e.Protocol)))
 	}
 	return lines
 }
 
+func protocolOrUnknown(p string) string {
+	if p == "" {
+		return "?"
+	}
+	return p
+}
+
 func readStringList(v any) []string {
 	var out []string
 	switch t := v.(type) {
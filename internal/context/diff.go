@@ -0,0 +1,319 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// Diff is what changed between two versions of an architecture. Nodes are
+// matched by id, then by label, so an id change with the same label is a
+// rename rather than a remove plus an add.
+type Diff struct {
+	PreviousSource   string           `json:"previous_source"`
+	CurrentSource    string           `json:"current_source"`
+	PreviousVersion  string           `json:"previous_version,omitempty"`
+	CurrentVersion   string           `json:"current_version,omitempty"`
+	AddedNodes       []FindingNode    `json:"added_nodes"`
+	RemovedNodes     []FindingNode    `json:"removed_nodes"`
+	RenamedNodes     []NodeRename     `json:"renamed_nodes"`
+	AddedEdges       []FindingEdge    `json:"added_edges"`
+	RemovedEdges     []FindingEdge    `json:"removed_edges"`
+	ProtocolChanges  []ProtocolChange `json:"protocol_changes"`
+	NewFindings      []Finding        `json:"new_findings"`
+	ResolvedFindings []Finding        `json:"resolved_findings"`
+	// Per-file status of each side's infra_files, as in chat signals.
+	PreviousInfraFiles []AttachmentStatus `json:"previous_infra_files,omitempty"`
+	CurrentInfraFiles  []AttachmentStatus `json:"current_infra_files,omitempty"`
+}
+
+type NodeRename struct {
+	PreviousID    string `json:"previous_id"`
+	ID            string `json:"id"`
+	PreviousLabel string `json:"previous_label"`
+	Label         string `json:"label"`
+}
+
+type ProtocolChange struct {
+	FindingEdge
+	Previous string `json:"previous_protocol"`
+	Current  string `json:"current_protocol"`
+}
+
+// Changed reports whether anything differs between the versions.
+func (d Diff) Changed() bool {
+	return len(d.AddedNodes)+len(d.RemovedNodes)+len(d.RenamedNodes)+len(d.AddedEdges)+
+		len(d.RemovedEdges)+len(d.ProtocolChanges)+len(d.NewFindings)+len(d.ResolvedFindings) > 0
+}
+
+// DiffArchitectures compares the topologies prev and cur resolve to (see
+// Analyze) and the findings of cur's rules on each.
+func DiffArchitectures(prev, cur Input) Diff {
+	ps, cs := prev.topology(), cur.topology()
+	pd, cd := ps.diagram, cs.diagram
+	out := Diff{
+		PreviousSource:   ps.source,
+		CurrentSource:    cs.source,
+		PreviousVersion:  pd.Metadata.DiagramVersionID,
+		CurrentVersion:   cd.Metadata.DiagramVersionID,
+		AddedNodes:       []FindingNode{},
+		RemovedNodes:     []FindingNode{},
+		RenamedNodes:     []NodeRename{},
+		AddedEdges:       []FindingEdge{},
+		RemovedEdges:     []FindingEdge{},
+		ProtocolChanges:  []ProtocolChange{},
+		NewFindings:      []Finding{},
+		ResolvedFindings: []Finding{},
+
+		PreviousInfraFiles: ps.infraFiles,
+		CurrentInfraFiles:  cs.infraFiles,
+	}
+
+	// ids maps previous node ids onto current ones.
+	ids := map[string]string{}
+	curIndex := cd.NodeIndex()
+	matched := map[string]bool{}
+	var unmatched []types.DiagramNode
+	for _, n := range pd.Nodes {
+		if c, ok := curIndex[n.ID]; ok && !matched[n.ID] {
+			ids[n.ID] = n.ID
+			matched[n.ID] = true
+			if n.Name() != c.Name() {
+				out.RenamedNodes = append(out.RenamedNodes, NodeRename{PreviousID: n.ID, ID: n.ID, PreviousLabel: n.Name(), Label: c.Name()})
+			}
+			continue
+		}
+		unmatched = append(unmatched, n)
+	}
+	byLabel := map[string]string{}
+	for _, c := range cd.Nodes {
+		if !matched[c.ID] {
+			key := strings.ToLower(c.Name())
+			if _, ok := byLabel[key]; !ok {
+				byLabel[key] = c.ID
+			}
+		}
+	}
+	for _, n := range unmatched {
+		if id, ok := byLabel[strings.ToLower(n.Name())]; ok && !matched[id] {
+			ids[n.ID] = id
+			matched[id] = true
+			out.RenamedNodes = append(out.RenamedNodes, NodeRename{PreviousID: n.ID, ID: id, PreviousLabel: n.Name(), Label: cd.Label(id)})
+			continue
+		}
+		out.RemovedNodes = append(out.RemovedNodes, FindingNode{ID: n.ID, Label: n.Name()})
+	}
+	for _, c := range cd.Nodes {
+		if !matched[c.ID] {
+			out.AddedNodes = append(out.AddedNodes, FindingNode{ID: c.ID, Label: c.Name()})
+		}
+	}
+	mapID := func(id string) string {
+		if m, ok := ids[id]; ok {
+			return m
+		}
+		return id
+	}
+
+	prevEdges, prevOrder := edgeProtocols(pd, mapID)
+	curEdges, curOrder := edgeProtocols(cd, func(id string) string { return id })
+	for _, k := range curOrder {
+		e := curEdges[k]
+		p, ok := prevEdges[k]
+		switch {
+		case !ok:
+			out.AddedEdges = append(out.AddedEdges, e.ref(cd))
+		case p.protocols() != e.protocols():
+			out.ProtocolChanges = append(out.ProtocolChanges, ProtocolChange{FindingEdge: e.ref(cd), Previous: p.protocols(), Current: e.protocols()})
+		}
+	}
+	for _, k := range prevOrder {
+		if _, ok := curEdges[k]; !ok {
+			e := prevEdges[k]
+			ref := FindingEdge{From: e.prevFrom, To: e.prevTo, FromLabel: pd.Label(e.prevFrom), ToLabel: pd.Label(e.prevTo), Protocol: e.protocols()}
+			out.RemovedEdges = append(out.RemovedEdges, ref)
+		}
+	}
+
+	rules := orDefault(cur.Rules)
+	prevRun := rules.Run(pd)
+	prevFindings := map[string]bool{}
+	for _, f := range prevRun {
+		prevFindings[findingKey(f, mapID)] = true
+	}
+	curFindings := map[string]bool{}
+	for _, f := range rules.Run(cd) {
+		k := findingKey(f, func(id string) string { return id })
+		curFindings[k] = true
+		if !prevFindings[k] {
+			out.NewFindings = append(out.NewFindings, f)
+		}
+	}
+	for _, f := range prevRun {
+		if !curFindings[findingKey(f, mapID)] {
+			out.ResolvedFindings = append(out.ResolvedFindings, f)
+		}
+	}
+	return out
+}
+
+// pairEdges are the edges between one ordered pair of nodes.
+type pairEdges struct {
+	from, to         string
+	prevFrom, prevTo string
+	protos           []string
+}
+
+func (p pairEdges) protocols() string {
+	ps := append([]string(nil), p.protos...)
+	sort.Strings(ps)
+	return strings.Join(ps, ",")
+}
+
+func (p pairEdges) ref(d types.Diagram) FindingEdge {
+	return FindingEdge{From: p.from, To: p.to, FromLabel: d.Label(p.from), ToLabel: d.Label(p.to), Protocol: p.protocols()}
+}
+
+func edgeProtocols(d types.Diagram, mapID func(string) string) (map[string]*pairEdges, []string) {
+	out := map[string]*pairEdges{}
+	var order []string
+	for _, e := range d.Edges {
+		from, to := mapID(e.From), mapID(e.To)
+		k := from + "->" + to
+		p, ok := out[k]
+		if !ok {
+			p = &pairEdges{from: from, to: to, prevFrom: e.From, prevTo: e.To}
+			out[k] = p
+			order = append(order, k)
+		}
+		proto := strings.ToLower(strings.TrimSpace(e.Protocol))
+		if proto != "" {
+			p.protos = append(p.protos, proto)
+		}
+	}
+	return out, order
+}
+
+// findingKey identifies a finding across versions by rule, kind, its first
+// node and its first edge, so a finding whose details merely grow (another
+// caller of a shared database) is not reported as new. Cycles and hotspots
+// have no stable first node or edge, so they are keyed by their sorted member
+// set instead.
+func findingKey(f Finding, mapID func(string) string) string {
+	k := f.RuleID + "|" + f.Kind
+	if memberKeyedRules[f.RuleID] {
+		ids := make([]string, 0, len(f.Nodes))
+		for _, n := range f.Nodes {
+			ids = append(ids, mapID(n.ID))
+		}
+		sort.Strings(ids)
+		return k + "|" + strings.Join(ids, ",")
+	}
+	if len(f.Nodes) > 0 {
+		k += "|" + mapID(f.Nodes[0].ID)
+	}
+	if len(f.Edges) > 0 {
+		k += "|" + mapID(f.Edges[0].From) + "->" + mapID(f.Edges[0].To)
+	}
+	return k
+}
+
+// memberKeyedRules are the rules whose findings findingKey identifies by their
+// whole node set.
+var memberKeyedRules = map[string]bool{
+	RuleDependencyCycle: true,
+	RuleEventCycle:      true,
+	RuleFanOut:          true,
+	RuleFanIn:           true,
+}
+
+// maxDiffItems caps each list in the changes block.
+const maxDiffItems = 20
+
+// changesBlock renders d as the CHANGES SINCE PREVIOUS VERSION context block.
+func changesBlock(d Diff) string {
+	var b strings.Builder
+	b.WriteString("CHANGES SINCE PREVIOUS VERSION")
+	if d.PreviousVersion != "" || d.CurrentVersion != "" {
+		fmt.Fprintf(&b, " (%s -> %s)", orUnknown(d.PreviousVersion), orUnknown(d.CurrentVersion))
+	}
+	b.WriteString(":\n")
+	if !d.Changed() {
+		b.WriteString("- No structural changes and no change in detected risks between the versions.\n")
+		return strings.TrimSpace(b.String())
+	}
+	list := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		if len(items) > maxDiffItems {
+			items = append(items[:maxDiffItems:maxDiffItems], fmt.Sprintf("... and %d more", len(items)-maxDiffItems))
+		}
+		b.WriteString("- " + title + ": " + strings.Join(items, "; ") + "\n")
+	}
+	nodeNames := func(ns []FindingNode) []string {
+		out := make([]string, 0, len(ns))
+		for _, n := range ns {
+			out = append(out, n.Label)
+		}
+		return out
+	}
+	edgeNames := func(es []FindingEdge) []string {
+		out := make([]string, 0, len(es))
+		for _, e := range es {
+			out = append(out, fmt.Sprintf("%s -> %s (%s)", e.FromLabel, e.ToLabel, protocolOrUnknown(e.Protocol)))
+		}
+		return out
+	}
+	titles := func(fs []Finding) []string {
+		out := make([]string, 0, len(fs))
+		for _, f := range fs {
+			out = append(out, f.Title)
+		}
+		return out
+	}
+	renames := make([]string, 0, len(d.RenamedNodes))
+	for _, r := range d.RenamedNodes {
+		renames = append(renames, r.PreviousLabel+" -> "+r.Label)
+	}
+	protos := make([]string, 0, len(d.ProtocolChanges))
+	for _, p := range d.ProtocolChanges {
+		protos = append(protos, fmt.Sprintf("%s -> %s: %s => %s", p.FromLabel, p.ToLabel, protocolOrUnknown(p.Previous), protocolOrUnknown(p.Current)))
+	}
+
+	list("Added components", nodeNames(d.AddedNodes))
+	list("Removed components", nodeNames(d.RemovedNodes))
+	list("Renamed components", renames)
+	list("Added dependencies", edgeNames(d.AddedEdges))
+	list("Removed dependencies", edgeNames(d.RemovedEdges))
+	list("Protocol changes", protos)
+	list("New risks", titles(d.NewFindings))
+	list("Resolved risks", titles(d.ResolvedFindings))
+	return strings.TrimSpace(b.String())
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+func diffSignals(d Diff) map[string]any {
+	sig := map[string]any{
+		"diff_nodes_added":       len(d.AddedNodes),
+		"diff_nodes_removed":     len(d.RemovedNodes),
+		"diff_nodes_renamed":     len(d.RenamedNodes),
+		"diff_edges_added":       len(d.AddedEdges),
+		"diff_edges_removed":     len(d.RemovedEdges),
+		"diff_protocol_changes":  len(d.ProtocolChanges),
+		"diff_new_findings":      len(d.NewFindings),
+		"diff_resolved_findings": len(d.ResolvedFindings),
+	}
+	if d.PreviousVersion != "" {
+		sig["previous_diagram_version_id"] = d.PreviousVersion
+	}
+	return sig
+}
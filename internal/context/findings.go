@@ -0,0 +1,110 @@
+package context
+
+import "strings"
+
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+func (s Severity) valid() bool {
+	return s == SeverityInfo || s == SeverityWarning || s == SeverityError
+}
+
+// Finding is one deterministic issue found in the topology. The same findings
+// feed the LLM risk hints and the /analyze endpoint. Path is set by rules that
+// report a route through the graph (a cycle, a call chain), in order; Kind
+// sub-classifies a rule's findings, e.g. "sync" or "async" cycles.
+type Finding struct {
+	RuleID      string        `json:"rule_id"`
+	Severity    Severity      `json:"severity"`
+	Kind        string        `json:"kind,omitempty"`
+	Title       string        `json:"title"`
+	Nodes       []FindingNode `json:"nodes,omitempty"`
+	Edges       []FindingEdge `json:"edges,omitempty"`
+	Path        []FindingNode `json:"path,omitempty"`
+	Explanation string        `json:"explanation"`
+}
+
+type FindingNode struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type FindingEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	FromLabel string `json:"from_label"`
+	ToLabel   string `json:"to_label"`
+	Protocol  string `json:"protocol,omitempty"`
+}
+
+// RuleYAMLMismatch is reported by Analyze itself, not by a rule: it compares
+// two inputs rather than one topology.
+const RuleYAMLMismatch = "yaml_diagram_mismatch"
+
+// renderRiskHints formats findings as the compact hint lines embedded in the
+// LLM context, one line per rule in rule-set order, plus the per-rule count
+// signals.
+func (rs *RuleSet) renderRiskHints(findings []Finding) (string, map[string]any) {
+	sig := map[string]any{}
+	byRule := map[string][]Finding{}
+	for _, f := range findings {
+		byRule[f.RuleID] = append(byRule[f.RuleID], f)
+	}
+
+	var lines []string
+	for _, cr := range rs.rules {
+		fs := byRule[cr.rule.Meta().ID]
+		if len(fs) == 0 {
+			continue
+		}
+		if key := cr.rule.Meta().SignalKey; key != "" {
+			sig[key] = len(fs)
+		}
+		if line := cr.rule.Hint(fs); line != "" {
+			lines = append(lines, "- "+line)
+		}
+	}
+	if len(lines) == 0 {
+		return "", sig
+	}
+	return strings.Join(lines, "\n") + "\n", sig
+}
+
+// edgeItems is the common hint form "a -> b; c -> d" for single-edge findings.
+func edgeItems(fs []Finding) string {
+	items := make([]string, 0, len(fs))
+	for _, f := range fs {
+		if len(f.Edges) > 0 {
+			items = append(items, f.Edges[0].FromLabel+" -> "+f.Edges[0].ToLabel)
+		} else {
+			items = append(items, f.Title)
+		}
+	}
+	return strings.Join(items, "; ")
+}
+
+func nodeItems(fs []Finding, sep string) string {
+	items := make([]string, 0, len(fs))
+	for _, f := range fs {
+		if len(f.Nodes) > 0 {
+			items = append(items, f.Nodes[0].Label)
+		}
+	}
+	return strings.Join(items, sep)
+}
+
+func pathLabel(nodes []FindingNode) string {
+	labels := make([]string, 0, len(nodes)+1)
+	for _, n := range nodes {
+		labels = append(labels, n.Label)
+	}
+	if len(nodes) > 0 {
+		labels = append(labels, nodes[0].Label)
+	}
+	return strings.Join(labels, " -> ")
+}
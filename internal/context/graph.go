@@ -0,0 +1,125 @@
+package context
+
+import "sort"
+
+// stronglyConnected returns the strongly connected components of the graph
+// given by next that contain a cycle (more than one node, or a self-loop), in
+// the order their first node appears in starts. Members are sorted by their
+// position in starts; nodes only reached through next come last.
+func stronglyConnected(starts []string, next func(string) []string) [][]string {
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	comp := map[string]int{}
+	var comps [][]string
+
+	var visit func(string)
+	visit = func(n string) {
+		index[n] = len(index)
+		low[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, to := range next(n) {
+			if _, ok := index[to]; !ok {
+				visit(to)
+				low[n] = min(low[n], low[to])
+			} else if onStack[to] {
+				low[n] = min(low[n], index[to])
+			}
+		}
+		if low[n] != index[n] {
+			return
+		}
+		id := len(comps)
+		var members []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			comp[top] = id
+			members = append(members, top)
+			if top == n {
+				break
+			}
+		}
+		comps = append(comps, members)
+	}
+	for _, n := range starts {
+		if _, ok := index[n]; !ok {
+			visit(n)
+		}
+	}
+
+	cyclic := func(members []string) bool {
+		if len(members) > 1 {
+			return true
+		}
+		for _, to := range next(members[0]) {
+			if to == members[0] {
+				return true
+			}
+		}
+		return false
+	}
+	pos := map[string]int{}
+	for i, n := range starts {
+		if _, ok := pos[n]; !ok {
+			pos[n] = i
+		}
+	}
+	var out [][]string
+	emitted := map[int]bool{}
+	for _, n := range starts {
+		id := comp[n]
+		if emitted[id] {
+			continue
+		}
+		emitted[id] = true
+		members := comps[id]
+		if !cyclic(members) {
+			continue
+		}
+		rank := func(id string) int {
+			if i, ok := pos[id]; ok {
+				return i
+			}
+			return len(starts)
+		}
+		sort.SliceStable(members, func(i, j int) bool { return rank(members[i]) < rank(members[j]) })
+		out = append(out, members)
+	}
+	return out
+}
+
+// shortestCycle returns the shortest cycle through start that stays inside
+// members, as the ids in order without repeating start, or nil.
+func shortestCycle(start string, members map[string]bool, next func(string) []string) []string {
+	prev := map[string]string{}
+	queue := []string{start}
+	seen := map[string]bool{start: true}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, to := range next(n) {
+			if to == start {
+				path := []string{n}
+				for n != start {
+					n = prev[n]
+					path = append(path, n)
+				}
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			if !members[to] || seen[to] {
+				continue
+			}
+			seen[to] = true
+			prev[to] = n
+			queue = append(queue, to)
+		}
+	}
+	return nil
+}
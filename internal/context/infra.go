@@ -0,0 +1,64 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/internal/ingest"
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// ingestInfra parses infra_files (docker-compose files and Kubernetes
+// manifests) into one merged diagram. Each file gets an AttachmentStatus;
+// files of any other kind are unsupported.
+func ingestInfra(files []types.InfraFile) (types.Diagram, []string, []AttachmentStatus) {
+	var parsed []ingest.ParsedFile
+	var names []string
+	statuses := make([]AttachmentStatus, 0, len(files))
+	for i, f := range files {
+		name := strings.TrimSpace(f.Name)
+		if name == "" {
+			name = fmt.Sprintf("infra_files[%d]", i)
+		}
+		st := AttachmentStatus{Name: name}
+		data := []byte(f.Content)
+		if strings.TrimSpace(f.Content) == "" {
+			st.Status = AttachmentNoData
+			statuses = append(statuses, st)
+			continue
+		}
+		st.Type = ingest.DetectContent(name, data)
+		if st.Type != "compose" && st.Type != "kubernetes" {
+			st.Status, st.Error = AttachmentUnsupported, "not a docker-compose file or Kubernetes manifest"
+			statuses = append(statuses, st)
+			continue
+		}
+		pf, err := ingest.ParseBytes(st.Type, name, data)
+		st.Nodes, st.Edges, st.Notes = len(pf.Nodes), len(pf.Edges), pf.Notes
+		switch {
+		case err != nil:
+			st.Status, st.Error = AttachmentParseError, err.Error()
+		case len(pf.Nodes) == 0:
+			st.Status = AttachmentEmpty
+		default:
+			st.Status = AttachmentParsed
+			parsed = append(parsed, pf)
+			names = append(names, name)
+		}
+		statuses = append(statuses, st)
+	}
+	return ingest.BuildIntermediate(parsed).Diagram(), names, statuses
+}
+
+// infraDiagram parses infra_files and records infra_files, infra_nodes and
+// infra_edges signals.
+func infraDiagram(files []types.InfraFile, signals map[string]any) (types.Diagram, []string) {
+	if len(files) == 0 {
+		return types.Diagram{}, nil
+	}
+	d, names, statuses := ingestInfra(files)
+	signals["infra_files"] = statuses
+	signals["infra_nodes"] = len(d.Nodes)
+	signals["infra_edges"] = len(d.Edges)
+	return d, names
+}
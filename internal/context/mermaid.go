@@ -0,0 +1,34 @@
+package context
+
+import (
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/internal/ingest"
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// parseMermaid turns an inline Mermaid diagram into a Diagram; notes are the
+// parser's remarks on lines it skipped.
+func parseMermaid(src string) (types.Diagram, []string) {
+	src = strings.TrimSpace(src)
+	if src == "" {
+		return types.Diagram{}, nil
+	}
+	pf := ingest.ParseMermaidBytes("mermaid", []byte(src))
+	return ingest.BuildIntermediate([]ingest.ParsedFile{pf}).Diagram(), pf.Notes
+}
+
+// mermaidDiagram parses the mermaid field and records mermaid_nodes,
+// mermaid_edges and mermaid_notes signals.
+func mermaidDiagram(src string, signals map[string]any) types.Diagram {
+	if strings.TrimSpace(src) == "" {
+		return types.Diagram{}
+	}
+	d, notes := parseMermaid(src)
+	signals["mermaid_nodes"] = len(d.Nodes)
+	signals["mermaid_edges"] = len(d.Edges)
+	if len(notes) > 0 {
+		signals["mermaid_notes"] = notes
+	}
+	return d
+}
@@ -0,0 +1,413 @@
+package context
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// Rule is one structural check over a topology. Check returns findings
+// without RuleID/Severity set; the RuleSet fills them in from Meta and the
+// deployment's config. Hint renders the findings of one request as a single
+// prompt line (without the leading "- "), or "" to keep them out of the prompt.
+type Rule interface {
+	Meta() RuleMeta
+	Check(t *Topology, p RuleParams) []Finding
+	Hint(findings []Finding) string
+}
+
+// Measurer is implemented by rules that also report topology metrics (for
+// example the deepest synchronous chain), whether or not they found anything.
+type Measurer interface {
+	Measure(t *Topology, p RuleParams) map[string]any
+}
+
+// RuleMeta describes a rule. Params holds the defaults for its parameters;
+// SignalKey, if set, reports the finding count in chat signals.
+type RuleMeta struct {
+	ID          string
+	Description string
+	Severity    Severity
+	Params      RuleParams
+	SignalKey   string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Rule{}
+	order      []string
+)
+
+// Register adds a rule to the registry used by DefaultRuleSet and
+// LoadRuleSet. Rules run in registration order. It panics on duplicate ids.
+func Register(r Rule) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	id := r.Meta().ID
+	if _, ok := registry[id]; ok {
+		panic("context: duplicate rule id " + id)
+	}
+	registry[id] = r
+	order = append(order, id)
+}
+
+func registered() []Rule {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]Rule, 0, len(order))
+	for _, id := range order {
+		out = append(out, registry[id])
+	}
+	return out
+}
+
+// DefaultTypeClasses maps the node-type classes rules refer to onto diagram
+// node types. RULES_CONFIG can replace any class.
+func DefaultTypeClasses() map[string][]string {
+	return map[string][]string{
+		"entry":     {"client", "user", "external", "actor", "ext"},
+		"internal":  {"service", "gateway", "db", "database", "datastore", "topic", "queue"},
+		"database":  {"db", "database", "datastore"},
+		"gateway":   {"gateway"},
+		"service":   {"service"},
+		"messaging": {"topic", "queue", "stream", "broker", "eventbus", "event_bus"},
+		"queue":     {"queue"},
+	}
+}
+
+// RulesConfig is the RULES_CONFIG file (YAML or JSON).
+//
+//	types:
+//	  database: [db, database, datastore, cache]
+//	rules:
+//	  missing_protocol: {enabled: false}
+//	  shared_db_fanin: {severity: error, params: {min_callers: 3}}
+type RulesConfig struct {
+	Types map[string][]string   `yaml:"types" json:"types"`
+	Rules map[string]RuleConfig `yaml:"rules" json:"rules"`
+}
+
+type RuleConfig struct {
+	Enabled  *bool          `yaml:"enabled" json:"enabled"`
+	Severity Severity       `yaml:"severity" json:"severity"`
+	Params   map[string]any `yaml:"params" json:"params"`
+}
+
+// RuleSet is the effective rules of one deployment.
+type RuleSet struct {
+	classes map[string]map[string]bool
+	rules   []configuredRule
+	all     []RuleInfo
+}
+
+type configuredRule struct {
+	rule     Rule
+	severity Severity
+	params   RuleParams
+}
+
+// RuleInfo is a rule as configured, for listing.
+type RuleInfo struct {
+	ID          string     `json:"id"`
+	Description string     `json:"description"`
+	Severity    Severity   `json:"severity"`
+	Enabled     bool       `json:"enabled"`
+	Params      RuleParams `json:"params,omitempty"`
+}
+
+// DefaultRuleSet enables every registered rule with its defaults.
+func DefaultRuleSet() *RuleSet {
+	rs, _ := NewRuleSet(RulesConfig{})
+	return rs
+}
+
+// LoadRuleSet reads a RulesConfig file.
+func LoadRuleSet(path string) (*RuleSet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("rules config: %w", err)
+	}
+	var cfg RulesConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("rules config %s: %w", path, err)
+	}
+	rs, err := NewRuleSet(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("rules config %s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// NewRuleSet applies cfg to the registered rules. Unknown rule ids and
+// severities are errors so typos do not silently disable nothing.
+func NewRuleSet(cfg RulesConfig) (*RuleSet, error) {
+	classes := DefaultTypeClasses()
+	for name, ts := range cfg.Types {
+		classes[name] = ts
+	}
+	rs := &RuleSet{classes: map[string]map[string]bool{}}
+	for name, ts := range classes {
+		set := map[string]bool{}
+		for _, t := range ts {
+			set[strings.ToLower(strings.TrimSpace(t))] = true
+		}
+		rs.classes[name] = set
+	}
+
+	known := map[string]bool{}
+	for _, r := range registered() {
+		m := r.Meta()
+		known[m.ID] = true
+		rc := cfg.Rules[m.ID]
+
+		sev := m.Severity
+		if rc.Severity != "" {
+			if !rc.Severity.valid() {
+				return nil, fmt.Errorf("rule %s: invalid severity %q", m.ID, rc.Severity)
+			}
+			sev = rc.Severity
+		}
+		params := RuleParams{}
+		for k, v := range m.Params {
+			params[k] = v
+		}
+		for k, v := range rc.Params {
+			def, ok := m.Params[k]
+			if !ok {
+				return nil, fmt.Errorf("rule %s: unknown param %q", m.ID, k)
+			}
+			pv, err := paramValue(def, v)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: param %q: %w", m.ID, k, err)
+			}
+			params[k] = pv
+		}
+		enabled := rc.Enabled == nil || *rc.Enabled
+
+		rs.all = append(rs.all, RuleInfo{ID: m.ID, Description: m.Description, Severity: sev, Enabled: enabled, Params: params})
+		if enabled {
+			rs.rules = append(rs.rules, configuredRule{rule: r, severity: sev, params: params})
+		}
+	}
+	var unknown []string
+	for id := range cfg.Rules {
+		if !known[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown rules: %s", strings.Join(unknown, ", "))
+	}
+	return rs, nil
+}
+
+// Rules lists every registered rule with its effective configuration.
+func (rs *RuleSet) Rules() []RuleInfo {
+	return rs.all
+}
+
+// Run checks d with every enabled rule.
+func (rs *RuleSet) Run(d types.Diagram) []Finding {
+	out, _ := rs.Evaluate(d)
+	return out
+}
+
+// Evaluate is Run plus the metrics of every enabled Measurer rule.
+func (rs *RuleSet) Evaluate(d types.Diagram) ([]Finding, map[string]any) {
+	t := NewTopology(d, rs.classes)
+	var out []Finding
+	metrics := map[string]any{}
+	for _, cr := range rs.rules {
+		m := cr.rule.Meta()
+		for _, f := range cr.rule.Check(t, cr.params) {
+			f.RuleID = m.ID
+			f.Severity = cr.severity
+			out = append(out, f)
+		}
+		if ms, ok := cr.rule.(Measurer); ok {
+			for k, v := range ms.Measure(t, cr.params) {
+				metrics[k] = v
+			}
+		}
+	}
+	return out, metrics
+}
+
+func orDefault(rs *RuleSet) *RuleSet {
+	if rs == nil {
+		return DefaultRuleSet()
+	}
+	return rs
+}
+
+// RuleParams are a rule's tunables; values come from YAML/JSON so numbers may
+// arrive as int or float64.
+type RuleParams map[string]any
+
+// paramValue checks a configured param against the type of its default and
+// returns it as that type: whole numbers for ints, booleans, and string
+// lists (a single string is a one-item list).
+func paramValue(def, v any) (any, error) {
+	switch def.(type) {
+	case int:
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case int64:
+			return int(n), nil
+		case float64:
+			if n == math.Trunc(n) {
+				return int(n), nil
+			}
+		}
+		return nil, fmt.Errorf("want a whole number, got %v", v)
+	case bool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("want true or false, got %v", v)
+	case []string:
+		switch l := v.(type) {
+		case string:
+			return []string{l}, nil
+		case []string:
+			return l, nil
+		case []any:
+			out := make([]string, 0, len(l))
+			for _, x := range l {
+				s, ok := x.(string)
+				if !ok {
+					return nil, fmt.Errorf("want a list of strings, got item %v", x)
+				}
+				out = append(out, s)
+			}
+			return out, nil
+		}
+		return nil, fmt.Errorf("want a list of strings, got %v", v)
+	}
+	return v, nil
+}
+
+func (p RuleParams) Int(key string) int {
+	switch v := p[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+func (p RuleParams) Float(key string) float64 {
+	switch v := p[key].(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func (p RuleParams) Bool(key string) bool {
+	b, _ := p[key].(bool)
+	return b
+}
+
+func (p RuleParams) Strings(key string) []string {
+	switch v := p[key].(type) {
+	case []string:
+		return v
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// Topology is a Diagram with the lookups rules share.
+type Topology struct {
+	Diagram  types.Diagram
+	classes  map[string]map[string]bool
+	nodes    map[string]types.DiagramNode
+	outbound map[string][]types.DiagramEdge
+	inbound  map[string][]types.DiagramEdge
+	memo     map[string]any
+}
+
+func NewTopology(d types.Diagram, classes map[string]map[string]bool) *Topology {
+	t := &Topology{
+		Diagram:  d,
+		classes:  classes,
+		nodes:    d.NodeIndex(),
+		outbound: map[string][]types.DiagramEdge{},
+		inbound:  map[string][]types.DiagramEdge{},
+		memo:     map[string]any{},
+	}
+	for _, e := range d.Edges {
+		t.outbound[e.From] = append(t.outbound[e.From], e)
+		t.inbound[e.To] = append(t.inbound[e.To], e)
+	}
+	return t
+}
+
+// cached returns the value computed for key on this topology, computing it
+// once; rules use it to share work between Check and Measure.
+func (t *Topology) cached(key string, compute func() any) any {
+	if v, ok := t.memo[key]; ok {
+		return v
+	}
+	v := compute()
+	t.memo[key] = v
+	return v
+}
+
+func (t *Topology) Outbound(id string) []types.DiagramEdge { return t.outbound[id] }
+func (t *Topology) Inbound(id string) []types.DiagramEdge  { return t.inbound[id] }
+func (t *Topology) TypeOf(id string) string                { return t.nodes[id].Type }
+
+// Is reports whether the node's type belongs to the named type class.
+func (t *Topology) Is(id, class string) bool {
+	return t.classes[class][t.nodes[id].Type]
+}
+
+// Typed is false when no node has a type; type-based rules skip such diagrams.
+func (t *Topology) Typed() bool {
+	for _, n := range t.Diagram.Nodes {
+		if n.Type != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Topology) Label(id string) string {
+	if n, ok := t.nodes[id]; ok {
+		return n.Name()
+	}
+	return id
+}
+
+func (t *Topology) NodeRef(id string) FindingNode {
+	return FindingNode{ID: id, Label: t.Label(id)}
+}
+
+func (t *Topology) EdgeRef(e types.DiagramEdge) FindingEdge {
+	return FindingEdge{From: e.From, To: e.To, FromLabel: t.Label(e.From), ToLabel: t.Label(e.To), Protocol: e.Protocol}
+}
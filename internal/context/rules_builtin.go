@@ -0,0 +1,439 @@
+package context
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// Built-in rule ids.
+const (
+	RuleOrphanComponent  = "orphan_component"
+	RuleGatewayBypass    = "gateway_bypass"
+	RuleSharedDBFanIn    = "shared_db_fanin"
+	RuleDBOutboundEdge   = "db_outbound_edge"
+	RuleExternalDBAccess = "external_db_access"
+	RuleDependencyCycle  = "dependency_cycle"
+	RuleMissingProtocol  = "missing_protocol"
+)
+
+func init() {
+	Register(orphanRule{})
+	Register(gatewayBypassRule{})
+	Register(sharedDBRule{})
+	Register(dbOutboundRule{})
+	Register(externalDBRule{})
+	Register(cycleRule{})
+	Register(missingProtocolRule{})
+}
+
+type orphanRule struct{}
+
+func (orphanRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleOrphanComponent,
+		Description: "Typed component with no inbound or outbound edges.",
+		Severity:    SeverityWarning,
+		SignalKey:   "orphan_components_count",
+	}
+}
+
+func (orphanRule) Check(t *Topology, _ RuleParams) []Finding {
+	if !t.Typed() {
+		return nil
+	}
+	var out []Finding
+	for _, n := range t.Diagram.Nodes {
+		if n.Type == "" || len(t.Inbound(n.ID))+len(t.Outbound(n.ID)) > 0 {
+			continue
+		}
+		out = append(out, Finding{
+			Title:       "Disconnected component: " + n.Name(),
+			Nodes:       []FindingNode{t.NodeRef(n.ID)},
+			Explanation: "The component has no inbound or outbound edges, so either it is unused or its dependencies are missing from the diagram.",
+		})
+	}
+	return out
+}
+
+func (orphanRule) Hint(fs []Finding) string {
+	return "orphan/disconnected nodes: " + nodeItems(fs, ", ")
+}
+
+type gatewayBypassRule struct{}
+
+func (gatewayBypassRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleGatewayBypass,
+		Description: "Entry node (client/user/external) calls an internal component directly although the diagram has a gateway.",
+		Severity:    SeverityWarning,
+		SignalKey:   "gateway_bypass_count",
+	}
+}
+
+func (gatewayBypassRule) Check(t *Topology, _ RuleParams) []Finding {
+	hasGateway := false
+	for _, n := range t.Diagram.Nodes {
+		if t.Is(n.ID, "gateway") {
+			hasGateway = true
+			break
+		}
+	}
+	if !hasGateway {
+		return nil
+	}
+	var out []Finding
+	for _, e := range t.Diagram.Edges {
+		if !t.Is(e.From, "entry") || t.Is(e.To, "gateway") || !t.Is(e.To, "internal") {
+			continue
+		}
+		out = append(out, Finding{
+			Title:       fmt.Sprintf("%s bypasses the gateway to reach %s", t.Label(e.From), t.Label(e.To)),
+			Nodes:       []FindingNode{t.NodeRef(e.From), t.NodeRef(e.To)},
+			Edges:       []FindingEdge{t.EdgeRef(e)},
+			Explanation: "The diagram has a gateway, but this entry point calls an internal component directly, skipping the gateway's auth, rate limiting and routing.",
+		})
+	}
+	return out
+}
+
+func (gatewayBypassRule) Hint(fs []Finding) string {
+	return "gateway bypass edges: " + edgeItems(fs)
+}
+
+type sharedDBRule struct{}
+
+func (sharedDBRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleSharedDBFanIn,
+		Description: "Database used by several services.",
+		Severity:    SeverityWarning,
+		Params:      RuleParams{"min_callers": 2},
+		SignalKey:   "shared_db_fanin_count",
+	}
+}
+
+func (sharedDBRule) Check(t *Topology, p RuleParams) []Finding {
+	minCallers := p.Int("min_callers")
+	if minCallers < 2 {
+		minCallers = 2
+	}
+	var out []Finding
+	for _, n := range t.Diagram.Nodes {
+		if !t.Is(n.ID, "database") {
+			continue
+		}
+		var callers []FindingNode
+		var edges []FindingEdge
+		seen := map[string]bool{}
+		for _, e := range t.Inbound(n.ID) {
+			if !t.Is(e.From, "service") {
+				continue
+			}
+			edges = append(edges, t.EdgeRef(e))
+			if !seen[e.From] {
+				seen[e.From] = true
+				callers = append(callers, t.NodeRef(e.From))
+			}
+		}
+		if len(callers) < minCallers {
+			continue
+		}
+		names := make([]string, len(callers))
+		for i, c := range callers {
+			names[i] = c.Label
+		}
+		out = append(out, Finding{
+			Title:       fmt.Sprintf("%s is shared by %s", n.Name(), strings.Join(names, ", ")),
+			Nodes:       append([]FindingNode{t.NodeRef(n.ID)}, callers...),
+			Edges:       edges,
+			Explanation: "Several services use the same database, coupling their schemas and deployments and making the database a shared bottleneck.",
+		})
+	}
+	return out
+}
+
+func (sharedDBRule) Hint(fs []Finding) string {
+	items := make([]string, 0, len(fs))
+	for _, f := range fs {
+		names := make([]string, 0, len(f.Nodes)-1)
+		for _, n := range f.Nodes[1:] {
+			names = append(names, n.Label)
+		}
+		items = append(items, f.Nodes[0].Label+" <= "+strings.Join(names, ", "))
+	}
+	return "shared database fan-in: " + strings.Join(items, "; ")
+}
+
+type dbOutboundRule struct{}
+
+func (dbOutboundRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleDBOutboundEdge,
+		Description: "Edge that starts at a database.",
+		Severity:    SeverityError,
+		SignalKey:   "db_outbound_edges_count",
+	}
+}
+
+func (dbOutboundRule) Check(t *Topology, _ RuleParams) []Finding {
+	var out []Finding
+	for _, e := range t.Diagram.Edges {
+		if !t.Is(e.From, "database") {
+			continue
+		}
+		out = append(out, Finding{
+			Title:       fmt.Sprintf("Database %s has an outbound edge to %s", t.Label(e.From), t.Label(e.To)),
+			Nodes:       []FindingNode{t.NodeRef(e.From), t.NodeRef(e.To)},
+			Edges:       []FindingEdge{t.EdgeRef(e)},
+			Explanation: "Databases do not normally initiate calls; the edge is probably drawn in the wrong direction.",
+		})
+	}
+	return out
+}
+
+func (dbOutboundRule) Hint(fs []Finding) string {
+	return "database outbound edges (suspicious): " + edgeItems(fs)
+}
+
+type externalDBRule struct{}
+
+func (externalDBRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleExternalDBAccess,
+		Description: "Direct edge between an entry node and a database, in either direction.",
+		Severity:    SeverityError,
+		SignalKey:   "external_db_direct_edges_count",
+	}
+}
+
+func (externalDBRule) Check(t *Topology, _ RuleParams) []Finding {
+	var out []Finding
+	for _, e := range t.Diagram.Edges {
+		if !(t.Is(e.From, "database") && t.Is(e.To, "entry")) && !(t.Is(e.To, "database") && t.Is(e.From, "entry")) {
+			continue
+		}
+		out = append(out, Finding{
+			Title:       fmt.Sprintf("Direct access between %s and database %s", t.Label(e.From), t.Label(e.To)),
+			Nodes:       []FindingNode{t.NodeRef(e.From), t.NodeRef(e.To)},
+			Edges:       []FindingEdge{t.EdgeRef(e)},
+			Explanation: "A client or external system talks to a database without a service in between, exposing the data store.",
+		})
+	}
+	return out
+}
+
+func (externalDBRule) Hint(fs []Finding) string {
+	return "direct external<->database access: " + edgeItems(fs)
+}
+
+type cycleRule struct{}
+
+// Cycle kinds: a cycle made only of synchronous calls, one that avoids async
+// edges but relies on edges whose mode is unknown, and a group whose every
+// cycle is broken by at least one async edge.
+const (
+	CycleSync    = "sync"
+	CycleUnknown = "unknown_mode"
+	CycleAsync   = "async"
+)
+
+func (cycleRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleDependencyCycle,
+		Description: "Dependency cycle between components, reported per strongly connected group with a representative path.",
+		Severity:    SeverityWarning,
+		Params:      RuleParams{"include_async": true},
+		SignalKey:   "cycle_count",
+	}
+}
+
+// Check reports one finding per strongly connected group of nodes. The path
+// is the shortest cycle of the strictest kind the group contains.
+func (cycleRule) Check(t *Topology, p RuleParams) []Finding {
+	var out []Finding
+	for _, f := range cycleGroups(t) {
+		if f.Kind == CycleAsync && !p.Bool("include_async") {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+func (r cycleRule) Measure(t *Topology, p RuleParams) map[string]any {
+	fs := r.Check(t, p)
+	if len(fs) == 0 {
+		return nil
+	}
+	paths := make([]string, 0, len(fs))
+	kinds := map[string]int{}
+	for _, f := range fs {
+		paths = append(paths, pathLabel(f.Path))
+		kinds[f.Kind]++
+	}
+	return map[string]any{
+		"cycle_paths":               paths,
+		"sync_cycles_count":         kinds[CycleSync],
+		"unknown_mode_cycles_count": kinds[CycleUnknown],
+		"async_cycles_count":        kinds[CycleAsync],
+	}
+}
+
+func (cycleRule) Hint(fs []Finding) string {
+	items := make([]string, 0, len(fs))
+	for _, f := range fs {
+		item := pathLabel(f.Path) + " [" + cycleKindLabel(f.Kind) + "]"
+		if extra := len(f.Nodes) - len(f.Path); extra > 0 {
+			item += fmt.Sprintf(" (+%d more in the same group: %s)", extra, nodeLabels(f.Nodes, f.Path))
+		}
+		items = append(items, item)
+	}
+	return fmt.Sprintf("dependency cycles detected: %d (%s)", len(fs), strings.Join(items, "; "))
+}
+
+func cycleKindLabel(kind string) string {
+	switch kind {
+	case CycleSync:
+		return "sync"
+	case CycleUnknown:
+		return "protocol unknown"
+	}
+	return "broken by async edge"
+}
+
+// nodeLabels lists the labels of nodes not in skip.
+func nodeLabels(nodes, skip []FindingNode) string {
+	in := map[string]bool{}
+	for _, n := range skip {
+		in[n.ID] = true
+	}
+	var out []string
+	for _, n := range nodes {
+		if !in[n.ID] {
+			out = append(out, n.Label)
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// cycleGroups finds the strongly connected groups of the dependency graph and
+// classifies each by the strictest cycle inside it.
+func cycleGroups(t *Topology) []Finding {
+	starts := make([]string, 0, len(t.Diagram.Nodes)+len(t.Diagram.Edges))
+	for _, n := range t.Diagram.Nodes {
+		starts = append(starts, n.ID)
+	}
+	for _, e := range t.Diagram.Edges {
+		starts = append(starts, e.From)
+	}
+	nextWhere := func(keep func(types.DiagramEdge) bool, members map[string]bool) func(string) []string {
+		return func(id string) []string {
+			var out []string
+			for _, e := range t.Outbound(id) {
+				if keep(e) && (members == nil || members[e.To]) {
+					out = append(out, e.To)
+				}
+			}
+			return out
+		}
+	}
+	all := func(types.DiagramEdge) bool { return true }
+	kinds := []struct {
+		kind string
+		keep func(types.DiagramEdge) bool
+	}{
+		{CycleSync, types.DiagramEdge.Sync},
+		{CycleUnknown, func(e types.DiagramEdge) bool { return !e.Async() }},
+		{CycleAsync, all},
+	}
+
+	var out []Finding
+	for _, members := range stronglyConnected(starts, nextWhere(all, nil)) {
+		inGroup := map[string]bool{}
+		for _, id := range members {
+			inGroup[id] = true
+		}
+		for _, k := range kinds {
+			next := nextWhere(k.keep, inGroup)
+			sub := stronglyConnected(members, next)
+			if len(sub) == 0 {
+				continue
+			}
+			inSub := map[string]bool{}
+			for _, id := range sub[0] {
+				inSub[id] = true
+			}
+			f := cycleFinding(t, shortestCycle(sub[0][0], inSub, next), k.keep)
+			f.Kind = k.kind
+			f.Nodes = nil
+			for _, id := range members {
+				f.Nodes = append(f.Nodes, t.NodeRef(id))
+			}
+			f.Title = fmt.Sprintf("Dependency cycle (%s): %s", cycleKindLabel(k.kind), pathLabel(f.Path))
+			if k.kind == CycleAsync {
+				f.Explanation = "Every loop in this group crosses an asynchronous edge, so a request cannot deadlock on it, but the components still cannot evolve independently and events can feed back into their producer."
+			}
+			out = append(out, f)
+			break
+		}
+	}
+	return out
+}
+
+// cycleFinding builds a finding for the cycle through ids, in order; the edge
+// from the last id back to the first closes it. Edges are the first ones
+// between each pair that keep accepts.
+func cycleFinding(t *Topology, ids []string, keep func(types.DiagramEdge) bool) Finding {
+	nodes := make([]FindingNode, 0, len(ids))
+	var edges []FindingEdge
+	for i, id := range ids {
+		nodes = append(nodes, t.NodeRef(id))
+		next := ids[(i+1)%len(ids)]
+		for _, e := range t.Outbound(id) {
+			if e.To == next && keep(e) {
+				edges = append(edges, t.EdgeRef(e))
+				break
+			}
+		}
+	}
+	return Finding{
+		Title:       "Dependency cycle: " + pathLabel(nodes),
+		Nodes:       nodes,
+		Edges:       edges,
+		Path:        nodes,
+		Explanation: "Components on a cycle cannot be deployed, scaled or reasoned about independently, and a failure can propagate around the loop.",
+	}
+}
+
+type missingProtocolRule struct{}
+
+func (missingProtocolRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleMissingProtocol,
+		Description: "Edge without a protocol.",
+		Severity:    SeverityInfo,
+		SignalKey:   "missing_protocol_edges_count",
+	}
+}
+
+func (missingProtocolRule) Check(t *Topology, _ RuleParams) []Finding {
+	var out []Finding
+	for _, e := range t.Diagram.Edges {
+		if strings.TrimSpace(e.Protocol) != "" {
+			continue
+		}
+		out = append(out, Finding{
+			Title:       fmt.Sprintf("Edge %s -> %s has no protocol", t.Label(e.From), t.Label(e.To)),
+			Nodes:       []FindingNode{t.NodeRef(e.From), t.NodeRef(e.To)},
+			Edges:       []FindingEdge{t.EdgeRef(e)},
+			Explanation: "Without a protocol it is unclear whether the call is synchronous or asynchronous, which changes latency and failure behaviour.",
+		})
+	}
+	return out
+}
+
+func (missingProtocolRule) Hint(fs []Finding) string {
+	return "edges with missing protocol values: " + edgeItems(fs)
+}
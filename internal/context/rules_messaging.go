@@ -0,0 +1,410 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// Messaging rule ids.
+const (
+	RuleTopicNoSubscribers = "topic_without_subscribers"
+	RuleTopicNoPublishers  = "topic_without_publishers"
+	RulePublishAndCall     = "publish_and_sync_call"
+	RuleQueueWithoutDLQ    = "queue_without_dlq"
+	RuleEventCycle         = "event_cycle"
+)
+
+func init() {
+	Register(topicNoSubscribersRule{})
+	Register(topicNoPublishersRule{})
+	Register(publishAndCallRule{})
+	Register(queueDLQRule{})
+	Register(eventCycleRule{})
+}
+
+// subscribeProtocols mark an edge drawn from a consumer to the topic it reads
+// from; any other edge into a topic is a publish.
+var subscribeProtocols = map[string]bool{
+	"sub": true, "subscribe": true, "subscriber": true, "consume": true, "consumer": true, "listen": true, "poll": true,
+}
+
+// messaging is the publish/subscribe view of a topology.
+type messaging struct {
+	publishers  map[string][]types.DiagramEdge // topic id -> edges from its publishers
+	subscribers map[string][]types.DiagramEdge // topic id -> edges to/from its subscribers
+	topics      []string
+	// flows are producer -> consumer event paths, through a topic or over a
+	// direct async edge.
+	flows map[string][]eventFlow
+}
+
+type eventFlow struct {
+	to    string
+	topic string
+	edges []types.DiagramEdge
+}
+
+// subscriberOf is the consumer end of a subscription edge.
+func subscriberOf(topic string, e types.DiagramEdge) string {
+	if e.To == topic {
+		return e.From
+	}
+	return e.To
+}
+
+// messaging builds the publish/subscribe view once per topology; every
+// messaging rule reads it.
+func (t *Topology) messaging() *messaging {
+	return t.cached("messaging", func() any { return buildMessaging(t) }).(*messaging)
+}
+
+func buildMessaging(t *Topology) *messaging {
+	m := &messaging{
+		publishers:  map[string][]types.DiagramEdge{},
+		subscribers: map[string][]types.DiagramEdge{},
+		flows:       map[string][]eventFlow{},
+	}
+	for _, n := range t.Diagram.Nodes {
+		if t.Is(n.ID, "messaging") {
+			m.topics = append(m.topics, n.ID)
+		}
+	}
+	for _, e := range t.Diagram.Edges {
+		fromTopic, toTopic := t.Is(e.From, "messaging"), t.Is(e.To, "messaging")
+		switch {
+		case fromTopic && toTopic:
+			// Broker-to-broker forwarding (e.g. into a dead-letter queue).
+		case toTopic && subscribeProtocols[strings.ToLower(strings.TrimSpace(e.Protocol))]:
+			m.subscribers[e.To] = append(m.subscribers[e.To], e)
+		case toTopic:
+			m.publishers[e.To] = append(m.publishers[e.To], e)
+		case fromTopic:
+			m.subscribers[e.From] = append(m.subscribers[e.From], e)
+		case e.Async():
+			m.flows[e.From] = append(m.flows[e.From], eventFlow{to: e.To, edges: []types.DiagramEdge{e}})
+		}
+	}
+	for _, topic := range m.topics {
+		for _, pub := range m.publishers[topic] {
+			for _, sub := range m.subscribers[topic] {
+				consumer := subscriberOf(topic, sub)
+				m.flows[pub.From] = append(m.flows[pub.From], eventFlow{to: consumer, topic: topic, edges: []types.DiagramEdge{pub, sub}})
+			}
+		}
+	}
+	return m
+}
+
+type topicNoSubscribersRule struct{}
+
+func (topicNoSubscribersRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleTopicNoSubscribers,
+		Description: "Topic or queue that is published to but has no subscribers.",
+		Severity:    SeverityWarning,
+		SignalKey:   "topics_without_subscribers_count",
+	}
+}
+
+func (topicNoSubscribersRule) Check(t *Topology, _ RuleParams) []Finding {
+	m := t.messaging()
+	var out []Finding
+	for _, topic := range m.topics {
+		pubs := m.publishers[topic]
+		if len(pubs) == 0 || len(m.subscribers[topic]) > 0 || len(t.Outbound(topic)) > 0 {
+			continue
+		}
+		out = append(out, topicFinding(t, topic, pubs, true,
+			"Events published here are never consumed: either a consumer is missing from the diagram or the topic is dead weight."))
+	}
+	return out
+}
+
+func (topicNoSubscribersRule) Measure(t *Topology, _ RuleParams) map[string]any {
+	m := t.messaging()
+	if len(m.topics) == 0 {
+		return nil
+	}
+	async := 0
+	for _, e := range t.Diagram.Edges {
+		if e.Async() || t.Is(e.From, "messaging") || t.Is(e.To, "messaging") {
+			async++
+		}
+	}
+	return map[string]any{"messaging_nodes_count": len(m.topics), "async_edges_count": async}
+}
+
+func (topicNoSubscribersRule) Hint(fs []Finding) string {
+	return "topics/queues without subscribers: " + nodeItems(fs, ", ")
+}
+
+type topicNoPublishersRule struct{}
+
+func (topicNoPublishersRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleTopicNoPublishers,
+		Description: "Topic or queue that has subscribers but nothing publishes to it.",
+		Severity:    SeverityWarning,
+		SignalKey:   "topics_without_publishers_count",
+	}
+}
+
+func (topicNoPublishersRule) Check(t *Topology, _ RuleParams) []Finding {
+	m := t.messaging()
+	var out []Finding
+	for _, topic := range m.topics {
+		subs := m.subscribers[topic]
+		if len(subs) == 0 || len(m.publishers[topic]) > 0 || fedByBroker(t, topic) {
+			continue
+		}
+		out = append(out, topicFinding(t, topic, subs, false,
+			"Consumers wait on a topic nothing publishes to: the producer is missing from the diagram or the subscription is stale."))
+	}
+	return out
+}
+
+func (topicNoPublishersRule) Hint(fs []Finding) string {
+	return "topics/queues without publishers: " + nodeItems(fs, ", ")
+}
+
+// fedByBroker reports whether another topic or queue forwards into topic.
+func fedByBroker(t *Topology, topic string) bool {
+	for _, e := range t.Inbound(topic) {
+		if t.Is(e.From, "messaging") {
+			return true
+		}
+	}
+	return false
+}
+
+func topicFinding(t *Topology, topic string, edges []types.DiagramEdge, publishers bool, explanation string) Finding {
+	f := Finding{Nodes: []FindingNode{t.NodeRef(topic)}, Explanation: explanation}
+	names := make([]string, 0, len(edges))
+	for _, e := range edges {
+		other := e.From
+		if !publishers {
+			other = subscriberOf(topic, e)
+		}
+		f.Nodes = append(f.Nodes, t.NodeRef(other))
+		f.Edges = append(f.Edges, t.EdgeRef(e))
+		names = append(names, t.Label(other))
+	}
+	if publishers {
+		f.Title = fmt.Sprintf("%s has publishers (%s) but no subscribers", t.Label(topic), strings.Join(names, ", "))
+	} else {
+		f.Title = fmt.Sprintf("%s has subscribers (%s) but no publishers", t.Label(topic), strings.Join(names, ", "))
+	}
+	return f
+}
+
+type publishAndCallRule struct{}
+
+func (publishAndCallRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RulePublishAndCall,
+		Description: "Service that both publishes events to a consumer and calls the same consumer synchronously.",
+		Severity:    SeverityWarning,
+		SignalKey:   "publish_and_sync_call_count",
+	}
+}
+
+func (publishAndCallRule) Check(t *Topology, _ RuleParams) []Finding {
+	m := t.messaging()
+	var out []Finding
+	for _, n := range t.Diagram.Nodes {
+		for _, call := range t.Outbound(n.ID) {
+			if !call.Sync() {
+				continue
+			}
+			for _, fl := range m.flows[n.ID] {
+				if fl.to != call.To {
+					continue
+				}
+				via := "directly"
+				if fl.topic != "" {
+					via = "via " + t.Label(fl.topic)
+				}
+				f := Finding{
+					Title:       fmt.Sprintf("%s publishes to %s (%s) and also calls it over %s", t.Label(n.ID), t.Label(call.To), via, call.Protocol),
+					Nodes:       []FindingNode{t.NodeRef(n.ID), t.NodeRef(call.To)},
+					Edges:       []FindingEdge{t.EdgeRef(call)},
+					Explanation: "Mixing an event and a synchronous call between the same pair keeps the temporal coupling the event was meant to remove, and the two paths can deliver updates out of order.",
+				}
+				for _, e := range fl.edges {
+					f.Edges = append(f.Edges, t.EdgeRef(e))
+				}
+				out = append(out, f)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (publishAndCallRule) Hint(fs []Finding) string {
+	return "services that publish to and synchronously call the same consumer: " + edgeItems(fs)
+}
+
+type queueDLQRule struct{}
+
+func (queueDLQRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleQueueWithoutDLQ,
+		Description: "Queue with no dead-letter counterpart (an edge to, or a node named after it with, one of markers).",
+		Severity:    SeverityInfo,
+		Params:      RuleParams{"markers": []string{"dlq", "dead-letter", "dead_letter", "deadletter", "dead letter"}},
+		SignalKey:   "queues_without_dlq_count",
+	}
+}
+
+func (queueDLQRule) Check(t *Topology, p RuleParams) []Finding {
+	markers := p.Strings("markers")
+	var out []Finding
+	for _, n := range t.Diagram.Nodes {
+		if !t.Is(n.ID, "queue") || isDLQ(t, n.ID, markers) || hasDLQ(t, n, markers) {
+			continue
+		}
+		out = append(out, Finding{
+			Title:       "Queue without a dead-letter queue: " + n.Name(),
+			Nodes:       []FindingNode{t.NodeRef(n.ID)},
+			Explanation: "Messages that repeatedly fail processing either block the queue or are dropped; a dead-letter queue keeps them for inspection and replay.",
+		})
+	}
+	return out
+}
+
+func hasMarker(s string, markers []string) bool {
+	s = strings.ToLower(s)
+	for _, mk := range markers {
+		if mk != "" && strings.Contains(s, strings.ToLower(mk)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDLQ(t *Topology, id string, markers []string) bool {
+	return hasMarker(id+" "+t.Label(id)+" "+t.TypeOf(id), markers)
+}
+
+// hasDLQ looks for an edge from q to a dead-letter node (or one labelled as
+// dead-lettering), or a dead-letter node named after q: with the marker
+// removed, its id or label is q's id or label ("orders-dlq", "DLQ orders").
+func hasDLQ(t *Topology, q types.DiagramNode, markers []string) bool {
+	for _, e := range t.Outbound(q.ID) {
+		if isDLQ(t, e.To, markers) || hasMarker(e.Label, markers) {
+			return true
+		}
+	}
+	names := map[string]bool{}
+	for _, s := range []string{q.ID, q.Name()} {
+		if k := queueKey(s, nil); k != "" {
+			names[k] = true
+		}
+	}
+	for _, n := range t.Diagram.Nodes {
+		if n.ID == q.ID || !isDLQ(t, n.ID, markers) {
+			continue
+		}
+		if names[queueKey(n.ID, markers)] || names[queueKey(n.Name(), markers)] {
+			return true
+		}
+	}
+	return false
+}
+
+// queueKey reduces a queue name to its words, without the markers and
+// without the generic words "queue" and "q", so "orders-queue", "Orders" and
+// "orders.dlq" (with the dlq marker) all give "orders".
+func queueKey(s string, markers []string) string {
+	s = strings.ToLower(s)
+	sorted := append([]string(nil), markers...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, mk := range sorted {
+		if mk != "" {
+			s = strings.ReplaceAll(s, strings.ToLower(mk), " ")
+		}
+	}
+	var words []string
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if w != "queue" && w != "q" {
+			words = append(words, w)
+		}
+	}
+	return strings.Join(words, "-")
+}
+
+func (queueDLQRule) Hint(fs []Finding) string {
+	return "queues without dead-letter queues: " + nodeItems(fs, ", ")
+}
+
+type eventCycleRule struct{}
+
+func (eventCycleRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleEventCycle,
+		Description: "Cycle in the event flow: a service's events lead, through consumers, back to events it consumes.",
+		Severity:    SeverityWarning,
+		SignalKey:   "event_cycles_count",
+	}
+}
+
+func (eventCycleRule) Check(t *Topology, _ RuleParams) []Finding {
+	m := t.messaging()
+	var starts []string
+	for _, n := range t.Diagram.Nodes {
+		if len(m.flows[n.ID]) > 0 {
+			starts = append(starts, n.ID)
+		}
+	}
+	next := func(id string) []string {
+		var out []string
+		for _, fl := range m.flows[id] {
+			out = append(out, fl.to)
+		}
+		return out
+	}
+	var out []Finding
+	for _, members := range stronglyConnected(starts, next) {
+		inGroup := map[string]bool{}
+		for _, id := range members {
+			inGroup[id] = true
+		}
+		ids := shortestCycle(members[0], inGroup, next)
+		f := Finding{
+			Explanation: "Events that trigger events back to their origin can amplify into a message storm or loop forever unless every hop is idempotent and bounded.",
+		}
+		for i, id := range ids {
+			f.Path = append(f.Path, t.NodeRef(id))
+			to := ids[(i+1)%len(ids)]
+			for _, fl := range m.flows[id] {
+				if fl.to == to {
+					for _, e := range fl.edges {
+						f.Edges = append(f.Edges, t.EdgeRef(e))
+					}
+					break
+				}
+			}
+		}
+		for _, id := range members {
+			f.Nodes = append(f.Nodes, t.NodeRef(id))
+		}
+		f.Title = "Event cycle: " + pathLabel(f.Path)
+		out = append(out, f)
+	}
+	return out
+}
+
+func (eventCycleRule) Hint(fs []Finding) string {
+	items := make([]string, 0, len(fs))
+	for _, f := range fs {
+		items = append(items, pathLabel(f.Path))
+	}
+	return fmt.Sprintf("event cycles detected: %d (%s)", len(fs), strings.Join(items, "; "))
+}
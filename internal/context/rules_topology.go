@@ -0,0 +1,434 @@
+package context
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// Latency and resilience rules over the call graph.
+const (
+	RuleSyncChainDepth = "sync_chain_depth"
+	RuleFanOut         = "fan_out_hotspot"
+	RuleFanIn          = "fan_in_hotspot"
+	RuleSPOF           = "single_point_of_failure"
+)
+
+// maxPathSteps bounds the longest-simple-path search, which is exponential
+// in the worst case; diagrams are small, so it only guards pathological input.
+const maxPathSteps = 200000
+
+func init() {
+	Register(syncChainRule{})
+	Register(fanOutRule{})
+	Register(fanInRule{})
+	Register(spofRule{})
+}
+
+// entryNodes are the nodes requests start from: the entry class, else
+// gateways, else nodes without inbound edges.
+func (t *Topology) entryNodes() []string {
+	for _, class := range []string{"entry", "gateway"} {
+		var out []string
+		for _, n := range t.Diagram.Nodes {
+			if t.Is(n.ID, class) {
+				out = append(out, n.ID)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	var out []string
+	for _, n := range t.Diagram.Nodes {
+		if len(t.Inbound(n.ID)) == 0 && len(t.Outbound(n.ID)) > 0 {
+			out = append(out, n.ID)
+		}
+	}
+	return out
+}
+
+type syncChainRule struct{}
+
+func (syncChainRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleSyncChainDepth,
+		Description: "Longest chain of synchronous (REST/gRPC) calls from an entry node exceeds max_depth hops.",
+		Severity:    SeverityWarning,
+		Params:      RuleParams{"max_depth": 4, "count_unknown": false},
+		SignalKey:   "long_sync_chains_count",
+	}
+}
+
+// longestSyncChains returns, per entry node, the longest simple path over
+// synchronous edges (edges with an unknown mode count when countUnknown).
+// The search runs once per topology; Check and Measure share the result.
+func longestSyncChains(t *Topology, countUnknown bool) map[string][]types.DiagramEdge {
+	key := fmt.Sprintf("sync_chains:%t", countUnknown)
+	return t.cached(key, func() any { return searchSyncChains(t, countUnknown) }).(map[string][]types.DiagramEdge)
+}
+
+func searchSyncChains(t *Topology, countUnknown bool) map[string][]types.DiagramEdge {
+	follow := func(e types.DiagramEdge) bool {
+		return e.Sync() || (countUnknown && e.Mode == types.ModeUnknown)
+	}
+	out := map[string][]types.DiagramEdge{}
+	for _, entry := range t.entryNodes() {
+		steps := 0
+		onPath := map[string]bool{entry: true}
+		var best, cur []types.DiagramEdge
+		var dfs func(string)
+		dfs = func(n string) {
+			steps++
+			if len(cur) > len(best) {
+				best = append(best[:0:0], cur...)
+			}
+			if steps > maxPathSteps {
+				return
+			}
+			for _, e := range t.Outbound(n) {
+				if !follow(e) || onPath[e.To] {
+					continue
+				}
+				onPath[e.To] = true
+				cur = append(cur, e)
+				dfs(e.To)
+				cur = cur[:len(cur)-1]
+				delete(onPath, e.To)
+			}
+		}
+		dfs(entry)
+		out[entry] = best
+	}
+	return out
+}
+
+func (syncChainRule) Check(t *Topology, p RuleParams) []Finding {
+	maxDepth := p.Int("max_depth")
+	chains := longestSyncChains(t, p.Bool("count_unknown"))
+	var out []Finding
+	for _, entry := range t.entryNodes() {
+		chain := chains[entry]
+		if len(chain) <= maxDepth {
+			continue
+		}
+		nodes := []FindingNode{t.NodeRef(entry)}
+		edges := make([]FindingEdge, 0, len(chain))
+		for _, e := range chain {
+			nodes = append(nodes, t.NodeRef(e.To))
+			edges = append(edges, t.EdgeRef(e))
+		}
+		out = append(out, Finding{
+			Title:       fmt.Sprintf("%d-hop synchronous chain from %s: %s", len(chain), t.Label(entry), chainLabel(nodes)),
+			Nodes:       nodes,
+			Edges:       edges,
+			Path:        nodes,
+			Explanation: fmt.Sprintf("Each synchronous hop adds its latency and failure probability to the request; more than %d hops makes tail latency and availability hard to bound. Consider async messaging, caching or collapsing services.", maxDepth),
+		})
+	}
+	return out
+}
+
+func (syncChainRule) Measure(t *Topology, p RuleParams) map[string]any {
+	deepest := 0
+	for _, chain := range longestSyncChains(t, p.Bool("count_unknown")) {
+		if len(chain) > deepest {
+			deepest = len(chain)
+		}
+	}
+	if deepest == 0 {
+		return nil
+	}
+	return map[string]any{"max_sync_chain_depth": deepest}
+}
+
+func (syncChainRule) Hint(fs []Finding) string {
+	items := make([]string, 0, len(fs))
+	for _, f := range fs {
+		items = append(items, fmt.Sprintf("%s (%d hops)", chainLabel(f.Nodes), len(f.Edges)))
+	}
+	return "long synchronous call chains: " + strings.Join(items, "; ")
+}
+
+func chainLabel(nodes []FindingNode) string {
+	labels := make([]string, len(nodes))
+	for i, n := range nodes {
+		labels[i] = n.Label
+	}
+	return strings.Join(labels, " -> ")
+}
+
+// degreeHotspots returns the services whose number of distinct neighbours in
+// the given direction exceeds max, most connected first, plus the highest
+// count seen. Entry nodes and databases are not services; shared databases
+// have their own rule. The result is computed once per topology.
+func degreeHotspots(t *Topology, outbound bool, max int) ([]string, map[string][]types.DiagramEdge, int) {
+	type hotspots struct {
+		ids     []string
+		edges   map[string][]types.DiagramEdge
+		highest int
+	}
+	h := t.cached(fmt.Sprintf("hotspots:%t:%d", outbound, max), func() any {
+		ids, edges, highest := countDegrees(t, outbound, max)
+		return hotspots{ids, edges, highest}
+	}).(hotspots)
+	return h.ids, h.edges, h.highest
+}
+
+func countDegrees(t *Topology, outbound bool, max int) ([]string, map[string][]types.DiagramEdge, int) {
+	edges := map[string][]types.DiagramEdge{}
+	highest := 0
+	var ids []string
+	for _, n := range t.Diagram.Nodes {
+		if t.Is(n.ID, "entry") || t.Is(n.ID, "database") {
+			continue
+		}
+		list := t.Inbound(n.ID)
+		if outbound {
+			list = t.Outbound(n.ID)
+		}
+		seen := map[string]bool{}
+		for _, e := range list {
+			other := e.From
+			if outbound {
+				other = e.To
+			}
+			if !seen[other] {
+				seen[other] = true
+				edges[n.ID] = append(edges[n.ID], e)
+			}
+		}
+		if len(seen) > highest {
+			highest = len(seen)
+		}
+		if len(seen) > max {
+			ids = append(ids, n.ID)
+		}
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return len(edges[ids[i]]) > len(edges[ids[j]]) })
+	return ids, edges, highest
+}
+
+func hotspotFinding(t *Topology, id string, edges []types.DiagramEdge, outbound bool) Finding {
+	f := Finding{Nodes: []FindingNode{t.NodeRef(id)}}
+	for _, e := range edges {
+		f.Edges = append(f.Edges, t.EdgeRef(e))
+		other := e.From
+		if outbound {
+			other = e.To
+		}
+		f.Nodes = append(f.Nodes, t.NodeRef(other))
+	}
+	if outbound {
+		f.Title = fmt.Sprintf("%s calls %d components", t.Label(id), len(edges))
+		f.Explanation = "A component with many downstream dependencies is exposed to the failures and latency of all of them, and often mixes responsibilities."
+	} else {
+		f.Title = fmt.Sprintf("%s is called by %d components", t.Label(id), len(edges))
+		f.Explanation = "A component many others depend on is a load and availability hotspot; it needs capacity headroom, caching or replication."
+	}
+	return f
+}
+
+func hotspotItems(fs []Finding, what string) string {
+	items := make([]string, 0, len(fs))
+	for _, f := range fs {
+		items = append(items, fmt.Sprintf("%s (%d %s)", f.Nodes[0].Label, len(f.Edges), what))
+	}
+	return strings.Join(items, "; ")
+}
+
+type fanOutRule struct{}
+
+func (fanOutRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleFanOut,
+		Description: "Component with more than max_fan_out distinct downstream dependencies.",
+		Severity:    SeverityWarning,
+		Params:      RuleParams{"max_fan_out": 5},
+		SignalKey:   "fan_out_hotspots_count",
+	}
+}
+
+func (fanOutRule) Check(t *Topology, p RuleParams) []Finding {
+	ids, edges, _ := degreeHotspots(t, true, p.Int("max_fan_out"))
+	var out []Finding
+	for _, id := range ids {
+		out = append(out, hotspotFinding(t, id, edges[id], true))
+	}
+	return out
+}
+
+func (fanOutRule) Measure(t *Topology, p RuleParams) map[string]any {
+	_, _, highest := degreeHotspots(t, true, p.Int("max_fan_out"))
+	if highest == 0 {
+		return nil
+	}
+	return map[string]any{"max_fan_out": highest}
+}
+
+func (fanOutRule) Hint(fs []Finding) string {
+	return "fan-out hotspots: " + hotspotItems(fs, "targets")
+}
+
+type fanInRule struct{}
+
+func (fanInRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleFanIn,
+		Description: "Component with more than max_fan_in distinct callers.",
+		Severity:    SeverityWarning,
+		Params:      RuleParams{"max_fan_in": 5},
+		SignalKey:   "fan_in_hotspots_count",
+	}
+}
+
+func (fanInRule) Check(t *Topology, p RuleParams) []Finding {
+	ids, edges, _ := degreeHotspots(t, false, p.Int("max_fan_in"))
+	var out []Finding
+	for _, id := range ids {
+		out = append(out, hotspotFinding(t, id, edges[id], false))
+	}
+	return out
+}
+
+func (fanInRule) Measure(t *Topology, p RuleParams) map[string]any {
+	_, _, highest := degreeHotspots(t, false, p.Int("max_fan_in"))
+	if highest == 0 {
+		return nil
+	}
+	return map[string]any{"max_fan_in": highest}
+}
+
+func (fanInRule) Hint(fs []Finding) string {
+	return "fan-in hotspots: " + hotspotItems(fs, "callers")
+}
+
+type spofRule struct{}
+
+func (spofRule) Meta() RuleMeta {
+	return RuleMeta{
+		ID:          RuleSPOF,
+		Description: "Component whose removal disconnects an entry node from at least min_cut services or datastores (a datastore is not counted against the one service that owns it).",
+		Severity:    SeverityWarning,
+		Params:      RuleParams{"min_cut": 1},
+		SignalKey:   "single_points_of_failure_count",
+	}
+}
+
+// reachable is the set of nodes reachable from start without passing through
+// skip.
+func (t *Topology) reachable(start, skip string) map[string]bool {
+	seen := map[string]bool{start: true}
+	queue := []string{start}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range t.Outbound(n) {
+			if e.To == skip || seen[e.To] {
+				continue
+			}
+			seen[e.To] = true
+			queue = append(queue, e.To)
+		}
+	}
+	return seen
+}
+
+// datastoreOwner returns the only caller of a datastore that exactly one
+// component calls (database-per-service).
+func (t *Topology) datastoreOwner(id string) (string, bool) {
+	if !t.Is(id, "database") {
+		return "", false
+	}
+	owner := ""
+	for _, e := range t.Inbound(id) {
+		if owner != "" && e.From != owner {
+			return "", false
+		}
+		owner = e.From
+	}
+	return owner, owner != ""
+}
+
+// Check reports a component when removing it cuts some entry node off from
+// at least min_cut services and datastores. A service is not reported for
+// the datastore only it calls: losing both together is expected.
+func (spofRule) Check(t *Topology, p RuleParams) []Finding {
+	minCut := p.Int("min_cut")
+	if minCut < 1 {
+		minCut = 1
+	}
+	entries := t.entryNodes()
+	if len(entries) == 0 {
+		return nil
+	}
+	isEntry := map[string]bool{}
+	for _, e := range entries {
+		isEntry[e] = true
+	}
+	var targets []string
+	for _, n := range t.Diagram.Nodes {
+		if !isEntry[n.ID] && (t.Is(n.ID, "service") || t.Is(n.ID, "database")) {
+			targets = append(targets, n.ID)
+		}
+	}
+
+	base := map[string]map[string]bool{}
+	for _, e := range entries {
+		base[e] = t.reachable(e, "")
+	}
+
+	var out []Finding
+	for _, n := range t.Diagram.Nodes {
+		if isEntry[n.ID] || t.Is(n.ID, "database") {
+			continue
+		}
+		cut := map[string]bool{}
+		var cutOrder []string
+		for _, e := range entries {
+			if !base[e][n.ID] {
+				continue
+			}
+			after := t.reachable(e, n.ID)
+			for _, target := range targets {
+				if target == n.ID || !base[e][target] || after[target] || cut[target] {
+					continue
+				}
+				if owner, ok := t.datastoreOwner(target); ok && owner == n.ID {
+					continue
+				}
+				cut[target] = true
+				cutOrder = append(cutOrder, target)
+			}
+		}
+		if len(cutOrder) < minCut {
+			continue
+		}
+		nodes := []FindingNode{t.NodeRef(n.ID)}
+		labels := make([]string, 0, len(cutOrder))
+		for _, id := range cutOrder {
+			nodes = append(nodes, t.NodeRef(id))
+			labels = append(labels, t.Label(id))
+		}
+		out = append(out, Finding{
+			Title:       fmt.Sprintf("%s is a single point of failure for %s", n.Name(), strings.Join(labels, ", ")),
+			Nodes:       nodes,
+			Explanation: "Every path from an entry point to these components goes through this one; if it fails or is deployed badly they become unreachable. Add redundancy or an alternative path.",
+		})
+	}
+	return out
+}
+
+func (spofRule) Hint(fs []Finding) string {
+	items := make([]string, 0, len(fs))
+	for _, f := range fs {
+		cut := make([]string, 0, len(f.Nodes)-1)
+		for _, n := range f.Nodes[1:] {
+			cut = append(cut, n.Label)
+		}
+		items = append(items, fmt.Sprintf("%s (cuts off %s)", f.Nodes[0].Label, strings.Join(cut, ", ")))
+	}
+	return "single points of failure: " + strings.Join(items, "; ")
+}
@@ -0,0 +1,242 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore keeps one JSON document per conversation under Root.
+// Writes go through a temp file and rename so a crash never leaves a torn file.
+// An in-memory index of conversation headers serves List and the limits; a
+// file's modification time records when it was last used, so LRU order and
+// the TTL survive a restart.
+type FileStore struct {
+	Root   string
+	limits Limits
+
+	mu    sync.Mutex
+	index map[string]fileEntry
+}
+
+type fileEntry struct {
+	head Conversation // without turns
+	used time.Time
+}
+
+// NewFileStore opens root, creating it if needed, and indexes the
+// conversations already in it.
+func NewFileStore(root string, limits Limits) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileStore{Root: root, limits: limits, index: map[string]fileEntry{}}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) path(id string) string { return filepath.Join(s.Root, id+".json") }
+
+func (s *FileStore) Create(_ context.Context, title string) (Conversation, error) {
+	now := time.Now().UTC()
+	c := Conversation{ID: newID(), Title: title, CreatedAt: now, UpdatedAt: now}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now)
+	if max := s.limits.MaxConversations; max > 0 {
+		for len(s.index) >= max {
+			s.evictLRU()
+		}
+	}
+	if err := s.write(c); err != nil {
+		return Conversation{}, err
+	}
+	s.index[c.ID] = fileEntry{head: c, used: now}
+	return c, nil
+}
+
+func (s *FileStore) Get(_ context.Context, id string) (Conversation, error) {
+	if !ValidID(id) {
+		return Conversation{}, ErrInvalidID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.touch(id); err != nil {
+		return Conversation{}, err
+	}
+	return s.read(id)
+}
+
+func (s *FileStore) List(_ context.Context) ([]Conversation, error) {
+	s.mu.Lock()
+	s.expire(time.Now().UTC())
+	out := make([]Conversation, 0, len(s.index))
+	for _, e := range s.index {
+		out = append(out, e.head)
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out, nil
+}
+
+func (s *FileStore) Append(_ context.Context, id string, turns ...Turn) error {
+	if !ValidID(id) {
+		return ErrInvalidID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.touch(id); err != nil {
+		return err
+	}
+	c, err := s.read(id)
+	if err != nil {
+		return err
+	}
+	c.Turns = s.limits.capTurns(append(c.Turns, turns...))
+	c.TurnCount = len(c.Turns)
+	c.UpdatedAt = time.Now().UTC()
+	if err := s.write(c); err != nil {
+		return err
+	}
+	c.Turns = nil
+	s.index[id] = fileEntry{head: c, used: c.UpdatedAt}
+	return nil
+}
+
+func (s *FileStore) Delete(_ context.Context, id string) error {
+	if !ValidID(id) {
+		return ErrInvalidID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.index, id)
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// load fills the index from Root, decoding each file's header but not its
+// turns.
+func (s *FileStore) load() error {
+	entries, err := os.ReadDir(s.Root)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(s.Root, name))
+		if err != nil {
+			continue
+		}
+		var h struct {
+			Conversation
+			Turns json.RawMessage `json:"turns,omitempty"`
+		}
+		if err := json.Unmarshal(b, &h); err != nil || !ValidID(h.ID) || h.ID+".json" != name {
+			continue
+		}
+		s.index[h.ID] = fileEntry{head: h.Conversation, used: info.ModTime().UTC()}
+	}
+	return nil
+}
+
+// touch marks a live conversation used; an expired one is removed and
+// reported missing. Callers hold mu.
+func (s *FileStore) touch(id string) error {
+	e, ok := s.index[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now().UTC()
+	if s.limits.expired(e.used, now) {
+		s.remove(id)
+		return ErrNotFound
+	}
+	e.used = now
+	s.index[id] = e
+	_ = os.Chtimes(s.path(id), now, now)
+	return nil
+}
+
+// expire removes every conversation idle longer than the TTL. Callers hold mu.
+func (s *FileStore) expire(now time.Time) {
+	if s.limits.TTL <= 0 {
+		return
+	}
+	for id, e := range s.index {
+		if s.limits.expired(e.used, now) {
+			s.remove(id)
+		}
+	}
+}
+
+// evictLRU removes the least recently used conversation. Callers hold mu.
+func (s *FileStore) evictLRU() {
+	oldest := ""
+	for id, e := range s.index {
+		if oldest == "" || e.used.Before(s.index[oldest].used) {
+			oldest = id
+		}
+	}
+	if oldest != "" {
+		s.remove(oldest)
+	}
+}
+
+func (s *FileStore) remove(id string) {
+	delete(s.index, id)
+	_ = os.Remove(s.path(id))
+}
+
+func (s *FileStore) read(id string) (Conversation, error) {
+	b, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Conversation{}, ErrNotFound
+	}
+	if err != nil {
+		return Conversation{}, err
+	}
+	var c Conversation
+	if err := json.Unmarshal(b, &c); err != nil {
+		return Conversation{}, err
+	}
+	return c, nil
+}
+
+func (s *FileStore) write(c Conversation) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.Root, c.ID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(c.ID))
+}
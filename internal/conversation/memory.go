@@ -0,0 +1,134 @@
+package conversation
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps conversations in process memory; they are lost on restart.
+type MemoryStore struct {
+	limits Limits
+
+	mu    sync.Mutex
+	convs map[string]*Conversation
+	// used is when each conversation was last read or written, for LRU
+	// eviction and the TTL.
+	used map[string]time.Time
+}
+
+func NewMemoryStore(limits Limits) *MemoryStore {
+	return &MemoryStore{limits: limits, convs: map[string]*Conversation{}, used: map[string]time.Time{}}
+}
+
+func (s *MemoryStore) Create(_ context.Context, title string) (Conversation, error) {
+	now := time.Now().UTC()
+	c := &Conversation{ID: newID(), Title: title, CreatedAt: now, UpdatedAt: now}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(now)
+	if max := s.limits.MaxConversations; max > 0 {
+		for len(s.convs) >= max {
+			s.evictLRU()
+		}
+	}
+	s.convs[c.ID] = c
+	s.used[c.ID] = now
+	return *c, nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (Conversation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.touch(id)
+	if !ok {
+		return Conversation{}, ErrNotFound
+	}
+	out := *c
+	out.Turns = append([]Turn(nil), c.Turns...)
+	return out, nil
+}
+
+func (s *MemoryStore) List(_ context.Context) ([]Conversation, error) {
+	s.mu.Lock()
+	s.expire(time.Now().UTC())
+	out := make([]Conversation, 0, len(s.convs))
+	for _, c := range s.convs {
+		cp := *c
+		cp.Turns = nil
+		out = append(out, cp)
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt.After(out[j].UpdatedAt) })
+	return out, nil
+}
+
+func (s *MemoryStore) Append(_ context.Context, id string, turns ...Turn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.touch(id)
+	if !ok {
+		return ErrNotFound
+	}
+	c.Turns = s.limits.capTurns(append(c.Turns, turns...))
+	c.TurnCount = len(c.Turns)
+	c.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.convs[id]; !ok {
+		return ErrNotFound
+	}
+	s.remove(id)
+	return nil
+}
+
+// touch returns a live conversation and marks it used; an expired one is
+// removed and reported missing. Callers hold mu.
+func (s *MemoryStore) touch(id string) (*Conversation, bool) {
+	c, ok := s.convs[id]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now().UTC()
+	if s.limits.expired(s.used[id], now) {
+		s.remove(id)
+		return nil, false
+	}
+	s.used[id] = now
+	return c, true
+}
+
+// expire removes every conversation idle longer than the TTL. Callers hold mu.
+func (s *MemoryStore) expire(now time.Time) {
+	if s.limits.TTL <= 0 {
+		return
+	}
+	for id, t := range s.used {
+		if s.limits.expired(t, now) {
+			s.remove(id)
+		}
+	}
+}
+
+// evictLRU removes the least recently used conversation. Callers hold mu.
+func (s *MemoryStore) evictLRU() {
+	oldest := ""
+	for id, t := range s.used {
+		if oldest == "" || t.Before(s.used[oldest]) {
+			oldest = id
+		}
+	}
+	if oldest != "" {
+		s.remove(oldest)
+	}
+}
+
+func (s *MemoryStore) remove(id string) {
+	delete(s.convs, id)
+	delete(s.used, id)
+}
@@ -0,0 +1,75 @@
+package conversation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrNotFound  = errors.New("conversation not found")
+	ErrInvalidID = errors.New("invalid conversation id")
+)
+
+type Turn struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Conversation struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	TurnCount int       `json:"turn_count"`
+	Turns     []Turn    `json:"turns,omitempty"`
+}
+
+// Store persists conversations so clients can send only the new message.
+// List returns conversations without their turns, most recently updated first.
+type Store interface {
+	Create(ctx context.Context, title string) (Conversation, error)
+	Get(ctx context.Context, id string) (Conversation, error)
+	List(ctx context.Context) ([]Conversation, error)
+	Append(ctx context.Context, id string, turns ...Turn) error
+	Delete(ctx context.Context, id string) error
+}
+
+// Limits bound a Store; zero disables a limit.
+type Limits struct {
+	// MaxConversations evicts the least recently used conversation when a
+	// new one would exceed it.
+	MaxConversations int
+	// MaxTurns keeps only the newest turns of each conversation.
+	MaxTurns int
+	// TTL drops conversations not read or written for this long.
+	TTL time.Duration
+}
+
+// capTurns keeps the newest MaxTurns turns.
+func (l Limits) capTurns(turns []Turn) []Turn {
+	if l.MaxTurns > 0 && len(turns) > l.MaxTurns {
+		return append([]Turn(nil), turns[len(turns)-l.MaxTurns:]...)
+	}
+	return turns
+}
+
+// expired reports whether a conversation last used at used has outlived the TTL.
+func (l Limits) expired(used, now time.Time) bool {
+	return l.TTL > 0 && now.Sub(used) > l.TTL
+}
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidID reports whether id is safe to use as a storage key.
+func ValidID(id string) bool { return validID.MatchString(id) }
+
+func newID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "conv_" + hex.EncodeToString(b[:])
+}
@@ -0,0 +1,158 @@
+// Package export renders diagrams into formats other tools read.
+package export
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// dotShapes maps node types (ingest and spec vocabulary) to Graphviz
+// shape and style. ingest.ParseDOT reads the same shapes back.
+var dotShapes = map[string][2]string{
+	"service":   {"box", "rounded"},
+	"db":        {"cylinder", ""},
+	"database":  {"cylinder", ""},
+	"datastore": {"cylinder", ""},
+	"queue":     {"cds", ""},
+	"topic":     {"cds", ""},
+	"stream":    {"cds", ""},
+	"gateway":   {"hexagon", ""},
+	"actor":     {"ellipse", ""},
+	"client":    {"tab", ""},
+	"ext":       {"box", "dashed"},
+}
+
+// dotEdgeStyles styles edges by protocol (ingest REST/gRPC/PUB/SUB and spec
+// rest/grpc/event kinds, lower-cased); other async edges are dashed.
+var dotEdgeStyles = map[string]string{
+	"rest": "solid", "grpc": "bold", "pub": "dashed", "sub": "dotted", "event": "dashed",
+}
+
+// GraphDOT renders an ingested graph as DOT.
+func GraphDOT(ig types.IntermediateGraph) []byte { return DOT(ig.Diagram()) }
+
+// SpecDOT renders an architecture spec (services, datastores, topics,
+// dependencies) as DOT.
+func SpecDOT(spec map[string]any) []byte {
+	d, _ := types.DecodeDiagram(spec)
+	return DOT(d)
+}
+
+// DOT renders a diagram as a Graphviz digraph. Groups become clusters, and
+// type and protocol are written as attributes too so the output parses back
+// to the same graph.
+func DOT(d types.Diagram) []byte {
+	var b bytes.Buffer
+	b.WriteString("digraph architecture {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [fontname=\"Helvetica\"];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\", fontsize=10];\n")
+
+	grouped := map[string]bool{}
+	for _, g := range d.Groups {
+		members := groupMembers(d, g)
+		if len(members) == 0 {
+			continue
+		}
+		b.WriteString("\n  subgraph " + dotID("cluster_"+g.ID) + " {\n")
+		label := g.Label
+		if label == "" {
+			label = g.ID
+		}
+		b.WriteString("    label=" + dotID(label) + ";\n")
+		if g.Kind != "" {
+			b.WriteString("    kind=" + dotID(g.Kind) + ";\n")
+		}
+		for _, n := range members {
+			if grouped[n.ID] {
+				continue
+			}
+			grouped[n.ID] = true
+			b.WriteString("    " + dotNode(n) + "\n")
+		}
+		b.WriteString("  }\n")
+	}
+
+	b.WriteString("\n")
+	for _, n := range d.Nodes {
+		if !grouped[n.ID] {
+			b.WriteString("  " + dotNode(n) + "\n")
+		}
+	}
+	if len(d.Edges) > 0 {
+		b.WriteString("\n")
+	}
+	for _, e := range d.Edges {
+		b.WriteString("  " + dotID(e.From) + " -> " + dotID(e.To) + dotEdgeAttrs(e) + ";\n")
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// groupMembers lists the group's nodes, from Members or from node.Group.
+func groupMembers(d types.Diagram, g types.DiagramGroup) []types.DiagramNode {
+	in := map[string]bool{}
+	for _, m := range g.Members {
+		in[m] = true
+	}
+	var out []types.DiagramNode
+	for _, n := range d.Nodes {
+		if in[n.ID] || (n.Group != "" && n.Group == g.ID) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func dotNode(n types.DiagramNode) string {
+	attrs := [][2]string{{"label", n.Name()}}
+	typ := strings.ToLower(n.Type)
+	if typ != "" {
+		attrs = append(attrs, [2]string{"type", typ})
+	}
+	shape, ok := dotShapes[typ]
+	if !ok {
+		shape = dotShapes["service"]
+	}
+	attrs = append(attrs, [2]string{"shape", shape[0]})
+	if shape[1] != "" {
+		attrs = append(attrs, [2]string{"style", shape[1]})
+	}
+	return dotID(n.ID) + dotAttrs(attrs) + ";"
+}
+
+func dotEdgeAttrs(e types.DiagramEdge) string {
+	var attrs [][2]string
+	if e.Label != "" {
+		attrs = append(attrs, [2]string{"label", e.Label})
+	}
+	if e.Protocol != "" {
+		attrs = append(attrs, [2]string{"protocol", e.Protocol})
+	}
+	style := dotEdgeStyles[strings.ToLower(e.Protocol)]
+	if style == "" && e.Async() {
+		style = "dashed"
+	}
+	if style != "" && style != "solid" {
+		attrs = append(attrs, [2]string{"style", style})
+	}
+	if len(attrs) == 0 {
+		return ""
+	}
+	return dotAttrs(attrs)
+}
+
+func dotAttrs(attrs [][2]string) string {
+	parts := make([]string, len(attrs))
+	for i, a := range attrs {
+		parts[i] = a[0] + "=" + dotID(a[1])
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
+// dotID quotes an id or value, escaping quotes and backslashes.
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
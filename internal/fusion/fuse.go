@@ -0,0 +1,148 @@
+package fusion
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/MalithGihan/uigp-service/internal/llm"
+	"github.com/MalithGihan/uigp-service/internal/llm/structured"
+	"github.com/MalithGihan/uigp-service/internal/validate"
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+const fuseSystem = `You are a microservice architecture fusion engine.
+Return ONLY valid JSON matching this schema keys:
+{ services:[], apis:[], datastores:[], topics:[], dependencies:[], configs:{}, constraints:{}, deploymentHints:{}, gaps:[], conflicts:[], trace:[], metadata:{schemaVersion:"` + validate.Current + `"} }
+Rules:
+- Do NOT invent info. If missing/uncertain, add an item in "gaps".
+- Prefer diagram facts (nodes/edges/protocols) over chat when conflicting; record conflicts.
+- Normalize service names to kebab-case; keep original in trace.
+- You only handle microservices architecture and performance questions.
+- If out of scope, reply: I can only help with microservices architecture. Ask about services, dependencies, APIs, data stores, scaling, latency, throughput, deployments.`
+
+type Options struct {
+	// MaxAttempts bounds the validate-and-repair loop, first call included.
+	MaxAttempts int
+	// JobDir, when set, lets SanitizeWithContext read the job's chat.txt.
+	JobDir string
+	// OnAttempt is called before each model call with its 1-based number.
+	OnAttempt func(n int)
+}
+
+type Result struct {
+	Spec  map[string]any
+	Valid bool
+	// Fallback is set when every attempt failed and Spec was built by
+	// MockFromIntermediate instead.
+	Fallback bool
+	Attempts []structured.Attempt
+}
+
+// Fuse asks the model to merge the ingested graph and the chat notes into a
+// spec matching the architecture schema, re-prompting with the failing JSON
+// pointers until it validates. When no attempt validates, the deterministic
+// mock spec is returned instead. The error is non-nil only when ctx ends.
+func Fuse(ctx context.Context, c llm.Client, ig types.IntermediateGraph, chat string, o Options) (Result, error) {
+	schema, err := validate.Schema()
+	if err != nil {
+		return Result{}, err
+	}
+	res, err := structured.Generate(ctx, c, structured.Request{
+		System:  fuseSystem,
+		Prompt:  mustJSON(lite(ig, chat)),
+		Schema:  schema,
+		Options: map[string]any{"temperature": 0.2, "num_ctx": 2048, "num_predict": 768},
+		Normalize: func(s map[string]any) map[string]any {
+			return Normalize(s, &ig, o.JobDir)
+		},
+		Validate:    validate.ValidateMap,
+		MaxAttempts: o.MaxAttempts,
+		OnAttempt:   o.OnAttempt,
+	})
+	if err != nil {
+		return Result{Attempts: res.Attempts}, err
+	}
+	if res.Valid {
+		last := res.Attempts[len(res.Attempts)-1]
+		stampMetadata(res.Value, "llm", last.Provider, last.Model)
+		traceAttempts(res.Value, res.Attempts)
+		return Result{Spec: res.Value, Valid: true, Attempts: res.Attempts}, nil
+	}
+
+	spec := Normalize(MockFromIntermediate(ig), &ig, o.JobDir)
+	stampMetadata(spec, "mock", "", "")
+	spec["metadata"].(map[string]any)["fallback"] = true
+	traceAttempts(spec, res.Attempts)
+	return Result{
+		Spec:     spec,
+		Valid:    validate.ValidateMap(spec) == nil,
+		Fallback: true,
+		Attempts: res.Attempts,
+	}, nil
+}
+
+// Normalize sanitizes a spec against the ingested graph and upgrades it to
+// the current schema version. An unknown version is left for validation to
+// report.
+func Normalize(spec map[string]any, ig *types.IntermediateGraph, jobDir string) map[string]any {
+	spec = SanitizeWithContext(spec, ig, jobDir)
+	_, _ = validate.Migrate(spec)
+	return spec
+}
+
+// stampMetadata records who generated the spec.
+func stampMetadata(s map[string]any, generator, provider, model string) {
+	md, _ := s["metadata"].(map[string]any)
+	if md == nil {
+		md = map[string]any{}
+	}
+	md["generator"] = generator
+	if provider != "" {
+		md["provider"] = provider
+	}
+	if model != "" {
+		md["model"] = model
+	}
+	s["metadata"] = md
+}
+
+// traceAttempts appends one {"kind": "attempt", ...} record per model call
+// to the spec's trace, after the model's own entries.
+func traceAttempts(s map[string]any, attempts []structured.Attempt) {
+	tr, _ := s["trace"].([]any)
+	for _, a := range attempts {
+		var rec map[string]any
+		if json.Unmarshal([]byte(mustJSON(a)), &rec) != nil {
+			continue
+		}
+		rec["kind"] = "attempt"
+		tr = append(tr, rec)
+	}
+	if tr == nil {
+		tr = []any{}
+	}
+	s["trace"] = tr
+}
+
+func mustJSON(v any) string { b, _ := json.Marshal(v); return string(b) }
+
+func LoadChat(jobDir string) string {
+	b, _ := os.ReadFile(jobDir + "/chat.txt")
+	return string(b)
+}
+
+func lite(ig types.IntermediateGraph, chat string) map[string]any {
+	labels := make([]string, 0, len(ig.Nodes))
+	for _, n := range ig.Nodes {
+		labels = append(labels, n.Label)
+	}
+	edges := make([][3]string, 0, len(ig.Edges))
+	for _, e := range ig.Edges {
+		edges = append(edges, [3]string{e.From, e.To, e.Protocol})
+	}
+	if len(chat) > 400 {
+		chat = chat[:400]
+	}
+	return map[string]any{"services": labels, "links": edges, "chat": chat}
+}
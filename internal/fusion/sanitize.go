@@ -26,7 +26,7 @@ func protoNormLower(s string) string {
 	switch strings.ToLower(s) {
 	case "grpc", "g_rpc":
 		return "grpc"
-	case "pubsub", "event":
+	case "pubsub", "event", "pub", "sub":
 		return "event"
 	case "rest":
 		return "rest"
@@ -45,6 +45,7 @@ func Sanitize(spec map[string]any) map[string]any {
 	ensureArr(spec, "topics")
 	ensureArr(spec, "gaps")
 	ensureArr(spec, "conflicts")
+	ensureArr(spec, "trace")
 	ensureObj(spec, "configs")
 	ensureObj(spec, "constraints")
 	ensureObj(spec, "deploymentHints")
@@ -112,64 +113,139 @@ func lower(s string) string {
 	return string(b)
 }
 
-func SanitizeWithContext(spec map[string]any, ig *types.IntermediateGraph, jobDir string) map[string]any {
-	if spec == nil {
-		spec = map[string]any{}
+// nameKey compares component names loosely: case, spaces, underscores and
+// hyphens do not matter, so "Orders Service" matches "orders-service".
+func nameKey(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_' || r == '.'
+	}), "-")
+}
+
+// specName is the spec name of an ingested node: its corrected label, or its
+// id when it has none, lower-cased.
+func specName(n types.Node) string {
+	label := n.Label
+	if strings.TrimSpace(label) == "" {
+		label = n.ID
 	}
-	var chat string
-	if jobDir != "" {
-		if b, err := os.ReadFile(filepath.Join(jobDir, "chat.txt")); err == nil {
-			chat = strings.ToLower(string(b))
+	return strings.ToLower(autoCorrectLabel(label))
+}
+
+// mergeGraph makes the spec cover the ingested graph without discarding
+// what the model wrote. Nodes missing from services, datastores and topics
+// are added as services; edges missing from dependencies are added; apis are
+// derived from the dependency kinds only when the spec has none. Dependency
+// endpoints are mapped onto the spelling of the component they name, or, when
+// they are ingest ids (the model sees them in its prompt), onto the name of
+// the node they refer to.
+func mergeGraph(spec map[string]any, ig *types.IntermediateGraph) {
+	named := map[string]string{}
+	for _, k := range []string{"services", "datastores", "topics"} {
+		for _, v := range anySlice(spec[k]) {
+			if m, ok := v.(map[string]any); ok {
+				if n := strOrEmpty(m["name"]); n != "" {
+					named[nameKey(n)] = n
+				}
+			}
 		}
 	}
 
-	if ig != nil && len(ig.Nodes) > 0 {
-		var outSvcs []map[string]any
-		for _, n := range ig.Nodes {
-			name := autoCorrectLabel(n.Label)
-			typ := n.Type
-			if typ == "" {
-				typ = "service"
-			}
-			if strings.ToLower(typ) == "db" || strings.ToLower(typ) == "datastore" {
-				typ = "datastore"
-			} else {
-				typ = "service"
-			}
-			outSvcs = append(outSvcs, map[string]any{
-				"name": strings.ToLower(name),
-				"type": typ,
-			})
+	svcs := anySlice(spec["services"])
+	ids := map[string]string{}
+	for _, n := range ig.Nodes {
+		name := specName(n)
+		if existing, ok := named[nameKey(name)]; ok {
+			ids[n.ID] = existing
+			continue
 		}
-		spec["services"] = outSvcs
+		typ := "service"
+		if t := strings.ToLower(n.Type); t == "db" || t == "datastore" {
+			typ = "datastore"
+		}
+		svcs = append(svcs, map[string]any{"name": name, "type": typ})
+		named[nameKey(name)] = name
+		ids[n.ID] = name
+	}
+	if len(ig.Nodes) > 0 {
+		spec["services"] = svcs
 	}
 
-	if ig != nil && len(ig.Edges) > 0 {
-		var deps []map[string]any
-		for _, e := range ig.Edges {
-			deps = append(deps, map[string]any{
-				"from": strings.ToLower(autoCorrectLabel(e.From)),
-				"to":   strings.ToLower(autoCorrectLabel(e.To)),
-				"kind": protoNormLower(e.Protocol),
-				"sync": true,
-			})
+	endpoint := func(s string) string {
+		if name, ok := named[nameKey(s)]; ok {
+			return name
+		}
+		if name, ok := ids[s]; ok {
+			return name
+		}
+		return s
+	}
+	deps := anySlice(spec["dependencies"])
+	have := map[[2]string]bool{}
+	for _, v := range deps {
+		if m, ok := v.(map[string]any); ok {
+			m["from"], m["to"] = endpoint(strOrEmpty(m["from"])), endpoint(strOrEmpty(m["to"]))
+			have[[2]string{nameKey(strOrEmpty(m["from"])), nameKey(strOrEmpty(m["to"]))}] = true
+		}
+	}
+	for _, e := range ig.Edges {
+		from, to := endpoint(e.From), endpoint(e.To)
+		if have[[2]string{nameKey(from), nameKey(to)}] {
+			continue
 		}
+		have[[2]string{nameKey(from), nameKey(to)}] = true
+		// []any, like decoded JSON, so the kind normalization below applies.
+		kind := protoNormLower(e.Protocol)
+		deps = append(deps, map[string]any{"from": from, "to": to, "kind": kind, "sync": kind != "event"})
+	}
+	if len(ig.Edges) > 0 {
 		spec["dependencies"] = deps
+	}
 
-		// 3) apis from deps
-		seen := map[string]bool{}
-		var apis []map[string]any
-		for _, d := range deps {
-			k := d["kind"].(string)
-			if !seen[k] {
-				seen[k] = true
-				apis = append(apis, map[string]any{
-					"name":     strings.ToUpper(k),
-					"protocol": k,
-				})
-			}
+	if len(anySlice(spec["apis"])) > 0 || len(deps) == 0 {
+		return
+	}
+	seen := map[string]bool{}
+	var apis []any
+	for _, v := range deps {
+		m, _ := v.(map[string]any)
+		k := protoNormLower(strOrEmpty(m["kind"]))
+		if k != "" && !seen[k] {
+			seen[k] = true
+			apis = append(apis, map[string]any{"name": strings.ToUpper(k), "protocol": k})
 		}
-		spec["apis"] = apis
+	}
+	spec["apis"] = apis
+}
+
+// anySlice reads a JSON array that may also be a typed slice of objects (as
+// MockFromIntermediate builds them).
+func anySlice(v any) []any {
+	switch s := v.(type) {
+	case []any:
+		return s
+	case []map[string]any:
+		out := make([]any, len(s))
+		for i, m := range s {
+			out[i] = m
+		}
+		return out
+	}
+	return nil
+}
+
+func SanitizeWithContext(spec map[string]any, ig *types.IntermediateGraph, jobDir string) map[string]any {
+	if spec == nil {
+		spec = map[string]any{}
+	}
+	var chat string
+	if jobDir != "" {
+		if b, err := os.ReadFile(filepath.Join(jobDir, "chat.txt")); err == nil {
+			chat = strings.ToLower(string(b))
+		}
+	}
+
+	if ig != nil {
+		mergeGraph(spec, ig)
 	}
 
 	if chat != "" && (strings.Contains(chat, "200 rps") || strings.Contains(chat, "~200 rps")) {
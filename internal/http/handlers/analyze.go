@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	archctx "github.com/MalithGihan/uigp-service/internal/context"
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// Analyze lints an architecture payload with the deterministic rules only; it
+// never calls the LLM.
+type Analyze struct {
+	rules *archctx.RuleSet
+}
+
+func NewAnalyze(rules *archctx.RuleSet) *Analyze { return &Analyze{rules: rules} }
+
+type analyzeRequest struct {
+	DiagramJSON map[string]any    `json:"diagram_json,omitempty"`
+	SpecSummary map[string]any    `json:"spec_summary,omitempty"`
+	YamlContent string            `json:"yaml_content,omitempty"`
+	Mermaid     string            `json:"mermaid,omitempty"`
+	InfraFiles  []types.InfraFile `json:"infra_files,omitempty"`
+}
+
+func (r analyzeRequest) input(rules *archctx.RuleSet) archctx.Input {
+	return archctx.Input{
+		DiagramJSON: r.DiagramJSON,
+		SpecSummary: r.SpecSummary,
+		YAMLContent: r.YamlContent,
+		Mermaid:     r.Mermaid,
+		Infra:       r.InfraFiles,
+		Rules:       rules,
+	}
+}
+
+func (h *Analyze) Analyze(w http.ResponseWriter, r *http.Request) {
+	var req analyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json body")
+		return
+	}
+	in := req.input(h.rules)
+	if in.Empty() {
+		writeError(w, http.StatusBadRequest, "bad_request", "diagram_json, mermaid, infra_files, spec_summary or yaml_content is required")
+		return
+	}
+	a := archctx.Analyze(in)
+	if a.Source == "" && strings.TrimSpace(req.Mermaid) != "" {
+		// Mermaid was all there was to analyze and none of it parsed.
+		writeError(w, http.StatusBadRequest, "bad_request", "mermaid: no components could be parsed"+mermaidNotes(a.Warnings))
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		OK bool `json:"ok"`
+		archctx.Analysis
+	}{OK: true, Analysis: a})
+}
+
+// mermaidNotes appends the Mermaid parser's notes to an error message.
+func mermaidNotes(ws []types.DiagramWarning) string {
+	var notes []string
+	for _, w := range ws {
+		if w.Code == "mermaid_note" {
+			notes = append(notes, w.Message)
+		}
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(notes, "; ") + ")"
+}
+
+type diffRequest struct {
+	Previous analyzeRequest `json:"previous"`
+	Current  analyzeRequest `json:"current"`
+}
+
+// Diff compares two versions of an architecture: node, edge and protocol
+// changes plus the findings that appeared or went away.
+func (h *Analyze) Diff(w http.ResponseWriter, r *http.Request) {
+	var req diffRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json body")
+		return
+	}
+	prev, cur := req.Previous.input(h.rules), req.Current.input(h.rules)
+	if prev.Empty() || cur.Empty() {
+		writeError(w, http.StatusBadRequest, "bad_request", "previous and current each need diagram_json, mermaid, infra_files, spec_summary or yaml_content")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		OK bool `json:"ok"`
+		archctx.Diff
+	}{OK: true, Diff: archctx.DiffArchitectures(prev, cur)})
+}
+
+// Rules lists the deployment's structural rules and their effective config.
+func (h *Analyze) Rules(w http.ResponseWriter, r *http.Request) {
+	rules := h.rules
+	if rules == nil {
+		rules = archctx.DefaultRuleSet()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "rules": rules.Rules()})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -15,6 +16,11 @@ type Chat struct {
 func NewChat(svc *chat.Service) *Chat { return &Chat{svc: svc} }
 
 func (h *Chat) Chat(w http.ResponseWriter, r *http.Request) {
+	if wantsEventStream(r) {
+		h.ChatStream(w, r)
+		return
+	}
+
 	var req chat.ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json body", http.StatusBadRequest)
@@ -22,7 +28,68 @@ func (h *Chat) Chat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := h.svc.Handle(r.Context(), req)
+	writeChatJSON(w, resp)
+}
+
+// ChatStream serves the chat answer as Server-Sent Events: zero or more "delta"
+// events carrying {"content": "..."} followed by a single "done" (or "error")
+// event carrying the full ChatResponse envelope. Failures that happen before the
+// first delta are returned as a regular JSON error response with a matching status.
+func (h *Chat) ChatStream(w http.ResponseWriter, r *http.Request) {
+	var req chat.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid json body", http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+	}
 
+	resp := h.svc.HandleStream(ctx, req, func(delta string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start()
+		if err := writeSSE(w, "delta", map[string]string{"content": delta}); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+
+	if !started && !resp.OK {
+		writeChatJSON(w, resp)
+		return
+	}
+
+	start()
+	event := "done"
+	if !resp.OK {
+		normalizeErrorMessage(&resp)
+		event = "error"
+	}
+	_ = writeSSE(w, event, resp)
+	flusher.Flush()
+}
+
+func writeChatJSON(w http.ResponseWriter, resp chat.ChatResponse) {
 	w.Header().Set("Content-Type", "application/json")
 	if !resp.OK {
 		status := mapErrorToStatus(resp)
@@ -32,6 +99,19 @@ func (h *Chat) Chat(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(resp)
 }
 
+func wantsEventStream(r *http.Request) bool {
+	return strings.Contains(strings.ToLower(r.Header.Get("Accept")), "text/event-stream")
+}
+
+func writeSSE(w http.ResponseWriter, event string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
+
 func mapErrorToStatus(resp chat.ChatResponse) int {
 	if resp.Error == nil {
 		return http.StatusBadRequest
@@ -39,12 +119,19 @@ func mapErrorToStatus(resp chat.ChatResponse) int {
 	switch resp.Error.Code {
 	case "bad_request":
 		return http.StatusBadRequest
-	case "timeout":
+	case "conversation_not_found":
+		return http.StatusNotFound
+	case "conversation_store_failed":
+		return http.StatusInternalServerError
+	case "timeout", "llm_timeout":
 		return http.StatusGatewayTimeout
-	case "llm_failed":
-		if isUpstreamTimeout(resp.Signals) {
-			return http.StatusGatewayTimeout
-		}
+	case "llm_unavailable":
+		return http.StatusServiceUnavailable
+	case "llm_rate_limited":
+		return http.StatusTooManyRequests
+	case "llm_context_length_exceeded":
+		return http.StatusRequestEntityTooLarge
+	case "llm_model_not_found", "llm_bad_response", "llm_failed":
 		return http.StatusBadGateway
 	default:
 		return http.StatusBadRequest
@@ -58,32 +145,21 @@ func normalizeErrorMessage(resp *chat.ChatResponse) {
 	switch resp.Error.Code {
 	case "timeout":
 		resp.Error.Message = "Request timed out while processing. Please retry."
+	case "llm_timeout":
+		resp.Error.Message = "Upstream LLM timed out. Please retry or reduce request complexity."
+	case "llm_unavailable":
+		resp.Error.Message = "Upstream LLM is unavailable. Please retry later."
+	case "llm_rate_limited":
+		resp.Error.Message = "Upstream LLM rate limit reached. Please retry later."
+	case "llm_context_length_exceeded":
+		resp.Error.Message = "Request exceeds the model context window. Reduce history or architecture context."
+	case "llm_model_not_found":
+		resp.Error.Message = "Configured LLM model was not found upstream."
+	case "llm_bad_response":
+		resp.Error.Message = "Upstream LLM returned an invalid response."
 	case "llm_failed":
-		if isUpstreamTimeout(resp.Signals) {
-			resp.Error.Message = "Upstream LLM timed out. Please retry or reduce request complexity."
-		} else if strings.TrimSpace(resp.Error.Message) == "" || resp.Error.Message == "LLM request failed" {
+		if strings.TrimSpace(resp.Error.Message) == "" || resp.Error.Message == "LLM request failed" {
 			resp.Error.Message = "Upstream LLM request failed."
 		}
 	}
 }
-
-func isUpstreamTimeout(signals map[string]any) bool {
-	if len(signals) == 0 {
-		return false
-	}
-	v, ok := signals["llm_error"]
-	if !ok || v == nil {
-		return false
-	}
-	s, ok := v.(string)
-	if !ok {
-		return false
-	}
-	errText := strings.ToLower(strings.TrimSpace(s))
-	if errText == "" {
-		return false
-	}
-	return strings.Contains(errText, "context deadline exceeded") ||
-		strings.Contains(errText, "client.timeout exceeded") ||
-		strings.Contains(errText, "timeout")
-}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MalithGihan/uigp-service/internal/conversation"
+)
+
+type Conversations struct {
+	store conversation.Store
+}
+
+func NewConversations(store conversation.Store) *Conversations {
+	return &Conversations{store: store}
+}
+
+func (h *Conversations) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title string `json:"title"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "bad_request", "invalid json body")
+		return
+	}
+	c, err := h.store.Create(r.Context(), strings.TrimSpace(req.Title))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "conversation_store_failed", "could not create conversation")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"ok": true, "conversation": c})
+}
+
+func (h *Conversations) List(w http.ResponseWriter, r *http.Request) {
+	list, err := h.store.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "conversation_store_failed", "could not list conversations")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "conversations": list})
+}
+
+func (h *Conversations) Get(w http.ResponseWriter, r *http.Request) {
+	c, err := h.store.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "conversation": c})
+}
+
+func (h *Conversations) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		h.storeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+}
+
+func (h *Conversations) storeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, conversation.ErrNotFound) || errors.Is(err, conversation.ErrInvalidID) {
+		writeError(w, http.StatusNotFound, "conversation_not_found", "conversation not found")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "conversation_store_failed", "conversation store error")
+}
@@ -27,6 +27,27 @@ func (h *Health) Readyz(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
 
+	if mr, ok := h.llm.(llm.MemberReporter); ok {
+		members := mr.Members(ctx)
+		ready := false
+		for _, m := range members {
+			if m.OK {
+				ready = true
+				break
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"ok":       ready,
+			"provider": h.llm.Provider(),
+			"members":  members,
+		})
+		return
+	}
+
 	err := h.llm.Ping(ctx)
 	out := map[string]any{
 		"ok":       err == nil,
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MalithGihan/uigp-service/internal/export"
+	"github.com/MalithGihan/uigp-service/internal/jobs"
+)
+
+// Jobs exposes the asynchronous diagram -> architecture spec pipeline.
+type Jobs struct {
+	m        *jobs.Manager
+	maxFiles int
+}
+
+func NewJobs(m *jobs.Manager, maxFiles int) *Jobs {
+	return &Jobs{m: m, maxFiles: maxFiles}
+}
+
+// Create accepts multipart/form-data with one or more "files" parts and an
+// optional "chat" field, and answers 202 with the queued job.
+func (h *Jobs) Create(w http.ResponseWriter, r *http.Request) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", "multipart/form-data body required")
+		return
+	}
+	var uploads []jobs.Upload
+	var chat string
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			h.readError(w, err)
+			return
+		}
+		data, err := io.ReadAll(part)
+		part.Close()
+		if err != nil {
+			h.readError(w, err)
+			return
+		}
+		switch part.FormName() {
+		case "files", "file":
+			name := baseName(part.FileName())
+			if name == "" || name == "." || name == ".." {
+				writeError(w, http.StatusBadRequest, "bad_request", "each file part needs a filename")
+				return
+			}
+			uploads = append(uploads, jobs.Upload{Name: name, Data: data})
+		case "chat":
+			chat = strings.TrimSpace(string(data))
+		}
+	}
+	if len(uploads) == 0 {
+		writeError(w, http.StatusBadRequest, "bad_request", "at least one file is required in the files field")
+		return
+	}
+	if h.maxFiles > 0 && len(uploads) > h.maxFiles {
+		writeError(w, http.StatusBadRequest, "too_many_files", "at most "+strconv.Itoa(h.maxFiles)+" files per job")
+		return
+	}
+	if dup := duplicateName(uploads); dup != "" {
+		writeError(w, http.StatusBadRequest, "bad_request", "duplicate file name: "+dup)
+		return
+	}
+
+	job, err := h.m.Submit(uploads, chat)
+	switch {
+	case errors.Is(err, jobs.ErrQueueFull):
+		writeError(w, http.StatusServiceUnavailable, "jobs_busy", "job queue is full, retry later")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "job_store_failed", "could not store the job")
+		return
+	}
+	w.Header().Set("Location", "/api/v1/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, map[string]any{"ok": true, "job": job})
+}
+
+func (h *Jobs) Get(w http.ResponseWriter, r *http.Request) {
+	job, err := h.m.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		h.jobError(w, job, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "job": job})
+}
+
+// Spec returns the schema-validated architecture spec of a succeeded job;
+// ?format=dot renders it as Graphviz DOT instead.
+func (h *Jobs) Spec(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" && format != "json" && format != "dot" {
+		writeError(w, http.StatusBadRequest, "bad_request", "format must be json or dot")
+		return
+	}
+	job, spec, err := h.m.Spec(chi.URLParam(r, "id"))
+	if err != nil {
+		h.jobError(w, job, err)
+		return
+	}
+	if format == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(export.SpecDOT(spec))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "job_id": job.ID, "spec": spec})
+}
+
+func (h *Jobs) jobError(w http.ResponseWriter, job jobs.Job, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeError(w, http.StatusNotFound, "job_not_found", "job not found")
+	case errors.Is(err, jobs.ErrNotReady):
+		writeError(w, http.StatusConflict, "job_not_ready", "job is "+job.Status+", poll GET /api/v1/jobs/"+job.ID)
+	case errors.Is(err, jobs.ErrFailed):
+		msg := "job failed"
+		if job.Error != nil {
+			msg = job.Error.Code + ": " + job.Error.Message
+		}
+		writeError(w, http.StatusUnprocessableEntity, "job_failed", msg)
+	default:
+		writeError(w, http.StatusInternalServerError, "job_store_failed", "could not read the job")
+	}
+}
+
+func (h *Jobs) readError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "request_too_large", "upload exceeds MAX_BODY_BYTES")
+		return
+	}
+	writeError(w, http.StatusBadRequest, "bad_request", "invalid multipart body")
+}
+
+func duplicateName(uploads []jobs.Upload) string {
+	seen := map[string]bool{}
+	for _, u := range uploads {
+		n := strings.ToLower(u.Name)
+		if seen[n] {
+			return u.Name
+		}
+		seen[n] = true
+	}
+	return ""
+}
+
+// baseName strips client paths; some browsers send C:\dir\file.drawio.
+func baseName(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
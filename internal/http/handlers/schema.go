@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/MalithGihan/uigp-service/internal/validate"
+)
+
+// Schema serves the embedded architecture spec schemas.
+type Schema struct{}
+
+func NewSchema() *Schema { return &Schema{} }
+
+// List reports the current version and every version the service can serve.
+func (h *Schema) List(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":       true,
+		"current":  validate.Current,
+		"versions": validate.Versions(),
+	})
+}
+
+// Get returns one schema document as stored; "current" and "latest" name
+// the version the pipeline produces.
+func (h *Schema) Get(w http.ResponseWriter, r *http.Request) {
+	v := chi.URLParam(r, "version")
+	if v == "current" || v == "latest" {
+		v = validate.Current
+	}
+	b, ok := validate.SchemaJSON(v)
+	if !ok {
+		writeError(w, http.StatusNotFound, "schema_not_found", "unknown schema version: "+v)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(b)
+}
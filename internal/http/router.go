@@ -8,12 +8,15 @@ import (
 
 	"github.com/MalithGihan/uigp-service/internal/chat"
 	"github.com/MalithGihan/uigp-service/internal/config"
+	archctx "github.com/MalithGihan/uigp-service/internal/context"
+	"github.com/MalithGihan/uigp-service/internal/conversation"
 	"github.com/MalithGihan/uigp-service/internal/http/handlers"
 	"github.com/MalithGihan/uigp-service/internal/http/middleware"
+	"github.com/MalithGihan/uigp-service/internal/jobs"
 	"github.com/MalithGihan/uigp-service/internal/llm"
 )
 
-func NewRouter(cfg config.Config, llmClient llm.Client, chatSvc *chat.Service) http.Handler {
+func NewRouter(cfg config.Config, llmClient llm.Client, chatSvc *chat.Service, convStore conversation.Store, rules *archctx.RuleSet, jobMgr *jobs.Manager) http.Handler {
 	r := chi.NewRouter()
 
 	// Baseline middleware
@@ -31,9 +34,33 @@ func NewRouter(cfg config.Config, llmClient llm.Client, chatSvc *chat.Service) h
 
 	// Versioned API
 	ch := handlers.NewChat(chatSvc)
+	ah := handlers.NewAnalyze(rules)
 	r.Route("/api/v1", func(v1 chi.Router) {
 		v1.Use(middleware.APIKey(cfg.APIKey))
 		v1.Post("/chat", ch.Chat)
+		v1.Post("/chat/stream", ch.ChatStream)
+		v1.Post("/analyze", ah.Analyze)
+		v1.Post("/diff", ah.Diff)
+		v1.Get("/rules", ah.Rules)
+
+		sh := handlers.NewSchema()
+		v1.Get("/schema", sh.List)
+		v1.Get("/schema/{version}", sh.Get)
+
+		if convStore != nil {
+			cv := handlers.NewConversations(convStore)
+			v1.Post("/conversations", cv.Create)
+			v1.Get("/conversations", cv.List)
+			v1.Get("/conversations/{id}", cv.Get)
+			v1.Delete("/conversations/{id}", cv.Delete)
+		}
+
+		if jobMgr != nil {
+			jh := handlers.NewJobs(jobMgr, cfg.JobsMaxFiles)
+			v1.Post("/jobs", jh.Create)
+			v1.Get("/jobs/{id}", jh.Get)
+			v1.Get("/jobs/{id}/spec", jh.Spec)
+		}
 	})
 
 	return r
@@ -0,0 +1,322 @@
+package ingest
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// c4Call is one C4 model macro call, as written in Mermaid C4 diagrams and
+// C4-PlantUML: Container(api, "API", "Go", "Handles orders") {
+type c4Call struct {
+	Macro string
+	Args  []string
+	// Named holds $name=value arguments ($tags, $techn, $link, ...).
+	Named map[string]string
+	// Open is set when the call ends in "{" (a boundary with members).
+	Open bool
+}
+
+var reC4Call = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_]*)\s*\((.*)\)\s*(\{)?\s*$`)
+
+func parseC4Call(line string) (c4Call, bool) {
+	m := reC4Call.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return c4Call{}, false
+	}
+	c := c4Call{Macro: m[1], Named: map[string]string{}, Open: m[3] != ""}
+	for _, a := range splitC4Args(m[2]) {
+		if strings.HasPrefix(a, "$") {
+			if k, v, ok := strings.Cut(a, "="); ok {
+				c.Named[strings.TrimSpace(k[1:])] = unquote(v)
+				continue
+			}
+		}
+		c.Args = append(c.Args, unquote(a))
+	}
+	return c, true
+}
+
+// arg returns positional argument i, or the named one when given.
+func (c c4Call) arg(i int, name string) string {
+	if v, ok := c.Named[name]; ok {
+		return v
+	}
+	if i < len(c.Args) {
+		return c.Args[i]
+	}
+	return ""
+}
+
+// splitC4Args splits on commas outside double quotes.
+func splitC4Args(s string) []string {
+	var out []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case r == ',' && !quoted:
+			out = append(out, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if t := strings.TrimSpace(cur.String()); t != "" || len(out) > 0 {
+		out = append(out, t)
+	}
+	return out
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	return strings.TrimSpace(s)
+}
+
+// c4ElementType maps an element macro (Person, SystemDb_Ext, ContainerQueue,
+// ...) to a node type; ok is false for macros that are not elements.
+func c4ElementType(c c4Call) (typ string, ok bool) {
+	m := c.Macro
+	ext := strings.HasSuffix(m, "_Ext")
+	m = strings.TrimSuffix(m, "_Ext")
+	var base string
+	for _, b := range []string{"Person", "System", "Container", "Component"} {
+		if strings.HasPrefix(m, b) {
+			base = b
+			break
+		}
+	}
+	if base == "" {
+		return "", false
+	}
+	switch strings.TrimPrefix(m, base) {
+	case "":
+	case "Db":
+		return "db", true
+	case "Queue":
+		return "queue", true
+	default:
+		return "", false
+	}
+	switch {
+	case base == "Person":
+		return "actor", true
+	case ext:
+		return "ext", true
+	}
+	if t := techType(c4Techn(c)); t != "" {
+		return t, true
+	}
+	return guessTypeFromLabel(c.arg(1, "label")), true
+}
+
+// techTypes maps words of a technology string ("PostgreSQL", "Kong",
+// "JavaScript and Angular") onto node types.
+var techTypes = map[string]string{
+	"database": "db", "db": "db", "sql": "db", "postgres": "db", "postgresql": "db", "mysql": "db",
+	"mariadb": "db", "oracle": "db", "mongodb": "db", "mongo": "db", "redis": "db", "memcached": "db",
+	"cassandra": "db", "dynamodb": "db", "elasticsearch": "db", "s3": "db", "minio": "db",
+	"queue": "queue", "kafka": "queue", "rabbitmq": "queue", "sqs": "queue", "activemq": "queue", "nats": "queue",
+	"redpanda": "queue", "pulsar": "queue",
+	"gateway": "gateway", "nginx": "gateway", "kong": "gateway", "envoy": "gateway", "apigee": "gateway",
+	"traefik": "gateway", "haproxy": "gateway",
+	"browser": "client", "spa": "client", "angular": "client", "react": "client", "vue": "client",
+	"mobile": "client", "ios": "client", "android": "client",
+}
+
+func techType(s string) string {
+	for _, w := range reWord.FindAllString(strings.ToLower(s), -1) {
+		if t, ok := techTypes[w]; ok {
+			return t
+		}
+	}
+	return ""
+}
+
+// c4Techn is the technology argument; System and Person have none.
+func c4Techn(c c4Call) string {
+	if strings.HasPrefix(c.Macro, "Container") || strings.HasPrefix(c.Macro, "Component") {
+		return c.arg(2, "techn")
+	}
+	return c.Named["techn"]
+}
+
+// c4BoundaryKinds maps boundary macros to group kinds.
+var c4BoundaryKinds = map[string]string{
+	"Boundary":            "boundary",
+	"Enterprise_Boundary": "enterprise_boundary",
+	"System_Boundary":     "system_boundary",
+	"Container_Boundary":  "container_boundary",
+	"Deployment_Node":     "deployment_node",
+	"Deployment_Node_L":   "deployment_node",
+	"Deployment_Node_R":   "deployment_node",
+	"Node":                "deployment_node",
+	"Node_L":              "deployment_node",
+	"Node_R":              "deployment_node",
+}
+
+// c4Relation reports relation macros: Rel, Rel_U/D/L/R and their long
+// forms, Rel_Back, Rel_Neighbor, BiRel*, and RelIndex* whose first argument
+// is the step number. Rel_Back* and RelIndex_Back* draw the relation from
+// their second argument to their first, so they are reversed.
+func c4Relation(macro string) (ok, bidirectional, indexed, reversed bool) {
+	switch {
+	case strings.HasPrefix(macro, "RelIndex"):
+		return true, false, true, strings.HasPrefix(macro, "RelIndex_Back")
+	case strings.HasPrefix(macro, "BiRel"):
+		return true, true, false, false
+	case macro == "Rel" || strings.HasPrefix(macro, "Rel_"):
+		return true, false, false, strings.HasPrefix(macro, "Rel_Back")
+	}
+	return false, false, false, false
+}
+
+// c4Graph collects elements, boundaries and relations from C4 macro calls
+// in source order. Calls it does not know (styles, tags, layout) are ignored.
+type c4Graph struct {
+	source string
+	pf     *ParsedFile
+	nodes  map[string]bool
+	groups map[string]int
+	// open is the stack of boundaries whose "{" has not been closed.
+	open []int
+	// pending is a boundary declared without "{" on its line, which a
+	// following lone "{" opens.
+	pending int
+}
+
+func newC4Graph(source string, pf *ParsedFile) *c4Graph {
+	return &c4Graph{source: source, pf: pf, nodes: map[string]bool{}, groups: map[string]int{}, pending: -1}
+}
+
+// line consumes one statement and reports whether it was C4.
+func (g *c4Graph) line(l string) bool {
+	switch l {
+	case "{":
+		if g.pending >= 0 {
+			g.open = append(g.open, g.pending)
+			g.pending = -1
+		}
+		return true
+	case "}":
+		if len(g.open) > 0 {
+			g.open = g.open[:len(g.open)-1]
+		}
+		return true
+	}
+	c, ok := parseC4Call(l)
+	if !ok {
+		return false
+	}
+	g.pending = -1
+	if typ, ok := c4ElementType(c); ok {
+		g.element(c, typ)
+		return true
+	}
+	if kind, ok := c4BoundaryKinds[c.Macro]; ok {
+		g.boundary(c, kind)
+		return true
+	}
+	if ok, bi, indexed, reversed := c4Relation(c.Macro); ok {
+		if indexed && len(c.Args) > 0 {
+			c.Args = c.Args[1:]
+		}
+		g.relation(c, bi, reversed)
+		return true
+	}
+	return strings.HasPrefix(c.Macro, "Update") || strings.HasPrefix(c.Macro, "Add")
+}
+
+func (g *c4Graph) element(c c4Call, typ string) {
+	id := c.arg(0, "alias")
+	if id == "" || g.nodes[id] {
+		return
+	}
+	g.nodes[id] = true
+	label := c.arg(1, "label")
+	if label == "" {
+		label = id
+	}
+	n := types.Node{ID: id, Type: typ, Label: label, Source: g.source}
+	if len(g.open) > 0 {
+		gi := g.open[len(g.open)-1]
+		n.Group = g.pf.Groups[gi].ID
+		g.pf.Groups[gi].Members = append(g.pf.Groups[gi].Members, id)
+	}
+	g.pf.Nodes = append(g.pf.Nodes, n)
+}
+
+func (g *c4Graph) boundary(c c4Call, kind string) {
+	id := c.arg(0, "alias")
+	if id == "" {
+		return
+	}
+	gi, ok := g.groups[id]
+	if !ok {
+		label := c.arg(1, "label")
+		if label == "" {
+			label = id
+		}
+		gi = len(g.pf.Groups)
+		g.groups[id] = gi
+		g.pf.Groups = append(g.pf.Groups, types.Group{ID: id, Label: label, Kind: kind})
+	}
+	if c.Open {
+		g.open = append(g.open, gi)
+	} else {
+		g.pending = gi
+	}
+}
+
+func (g *c4Graph) relation(c c4Call, bidirectional, reversed bool) {
+	from, to := c.arg(0, "from"), c.arg(1, "to")
+	if from == "" || to == "" {
+		g.pf.Notes = append(g.pf.Notes, g.source+": "+c.Macro+" needs from and to; skipped")
+		return
+	}
+	if reversed {
+		from, to = to, from
+	}
+	label, techn := c.arg(2, "label"), c.arg(3, "techn")
+	proto := guessProtocolFromValue(techn)
+	if proto == "" {
+		proto = guessProtocolFromValue(label)
+	}
+	if techn != "" {
+		label = strings.TrimSpace(label + " [" + techn + "]")
+	}
+	g.pf.Edges = append(g.pf.Edges, types.Edge{From: from, To: to, Protocol: proto, Label: label})
+	if bidirectional {
+		g.pf.Edges = append(g.pf.Edges, types.Edge{From: to, To: from, Protocol: proto, Label: label})
+	}
+}
+
+// finish drops relations whose ends were never declared as elements, with
+// a note for each.
+func (g *c4Graph) finish() {
+	kept := g.pf.Edges[:0]
+	for _, e := range g.pf.Edges {
+		missing := ""
+		for _, id := range []string{e.From, e.To} {
+			if !g.nodes[id] && missing == "" {
+				missing = id
+			}
+		}
+		switch _, isGroup := g.groups[missing]; {
+		case missing == "":
+			kept = append(kept, e)
+		case isGroup:
+			g.pf.Notes = append(g.pf.Notes, g.source+": relation "+e.From+" -> "+e.To+" points at boundary "+missing+"; skipped")
+		default:
+			g.pf.Notes = append(g.pf.Notes, g.source+": relation "+e.From+" -> "+e.To+" uses undeclared "+missing+"; skipped")
+		}
+	}
+	g.pf.Edges = kept
+}
@@ -1,7 +1,9 @@
 package ingest
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -32,16 +34,10 @@ type canvasDoc struct {
 	} `json:"dependencies"`
 }
 
-// parse one canvas JSON file into IntermediateGraph
-func fromCanvasJSON(path string) (*types.IntermediateGraph, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
+// parse one canvas JSON document into IntermediateGraph
+func fromCanvasJSON(r io.Reader) (*types.IntermediateGraph, error) {
 	var doc canvasDoc
-	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
 		return nil, err
 	}
 
@@ -102,13 +98,27 @@ func fromCanvasJSON(path string) (*types.IntermediateGraph, error) {
 }
 
 func ParseCanvasJSON(path string) (ParsedFile, error) {
-	g, err := fromCanvasJSON(path)
+	f, err := os.Open(path)
 	if err != nil {
 		return ParsedFile{}, err
 	}
+	defer f.Close()
+	return parseCanvas(filepath.Base(path), f)
+}
+
+// ParseCanvasJSONBytes parses a canvas JSON document held in memory.
+func ParseCanvasJSONBytes(name string, b []byte) (ParsedFile, error) {
+	return parseCanvas(name, bytes.NewReader(b))
+}
+
+func parseCanvas(name string, r io.Reader) (ParsedFile, error) {
+	g, err := fromCanvasJSON(r)
+	if err != nil {
+		return ParsedFile{Name: name}, err
+	}
 
 	return ParsedFile{
-		Name:  filepath.Base(path),
+		Name:  name,
 		Nodes: g.Nodes,
 		Edges: g.Edges,
 		Notes: g.Notes,
@@ -0,0 +1,215 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+func ParseCompose(fp string) (ParsedFile, error) {
+	b, err := os.ReadFile(fp)
+	if err != nil {
+		return ParsedFile{Name: fp}, err
+	}
+	return ParseComposeBytes(filepath.Base(fp), b)
+}
+
+// ParseComposeBytes reads a docker-compose file. Each service is a node,
+// typed by its image (postgres, redis, kafka, nginx, ...) and then its name;
+// ports and expose become the node's ports. depends_on, links and env vars
+// naming another service's host (DATABASE_URL=postgres://db:5432/x,
+// ORDERS_HOST=orders) become edges, with the protocol from the URL scheme or
+// the variable name.
+func ParseComposeBytes(name string, b []byte) (ParsedFile, error) {
+	pf := ParsedFile{Name: name}
+	var root yaml.Node
+	if err := yaml.Unmarshal(b, &root); err != nil {
+		return pf, err
+	}
+	services := mappingValue(docNode(&root), "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		pf.Notes = append(pf.Notes, "compose: no services mapping found")
+		return pf, nil
+	}
+
+	type entry struct {
+		id  string
+		svc composeService
+	}
+	var entries []entry
+	// hosts maps service names, container names, hostnames and network
+	// aliases onto service ids.
+	hosts := map[string]string{}
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		id := services.Content[i].Value
+		var svc composeService
+		if err := services.Content[i+1].Decode(&svc); err != nil {
+			pf.Notes = append(pf.Notes, "compose: service "+id+": "+err.Error())
+			continue
+		}
+		entries = append(entries, entry{id, svc})
+		for _, h := range append([]string{id, svc.ContainerName, svc.Hostname}, svc.aliases()...) {
+			if h != "" {
+				hosts[strings.ToLower(h)] = id
+			}
+		}
+	}
+
+	g := newInfraGraph(&pf)
+	for _, e := range entries {
+		typ := imageType(e.svc.Image)
+		if typ == "" {
+			typ = techType(e.id)
+		}
+		if typ == "" {
+			typ = guessTypeFromLabel(e.id)
+		}
+		n := g.node(types.Node{ID: e.id, Type: typ, Label: e.id, Source: "compose"})
+		n.Ports = append(composePorts(e.svc.Ports), composePorts(e.svc.Expose)...)
+	}
+	for _, e := range entries {
+		for _, dep := range nameList(&e.svc.DependsOn) {
+			if _, ok := g.nodes[dep]; !ok {
+				pf.Notes = append(pf.Notes, "compose: "+e.id+" depends_on unknown service "+dep)
+				continue
+			}
+			g.link(e.id, dep, "", "depends_on")
+		}
+		for _, l := range e.svc.Links {
+			target, _, _ := strings.Cut(l, ":")
+			if _, ok := g.nodes[target]; ok {
+				g.link(e.id, target, "", "links")
+			}
+		}
+		for _, kv := range keyValues(&e.svc.Environment) {
+			for _, h := range envHosts(kv[0], kv[1]) {
+				if target, ok := hosts[h]; ok {
+					g.link(e.id, target, envProtocol(kv[0], kv[1]), "env "+kv[0])
+				}
+			}
+		}
+	}
+	if len(pf.Nodes) == 0 {
+		pf.Notes = append(pf.Notes, "compose: no services declared")
+	}
+	return pf, nil
+}
+
+type composeService struct {
+	Image         string      `yaml:"image"`
+	ContainerName string      `yaml:"container_name"`
+	Hostname      string      `yaml:"hostname"`
+	Ports         []yaml.Node `yaml:"ports"`
+	Expose        []yaml.Node `yaml:"expose"`
+	DependsOn     yaml.Node   `yaml:"depends_on"`
+	Links         []string    `yaml:"links"`
+	Environment   yaml.Node   `yaml:"environment"`
+	Networks      yaml.Node   `yaml:"networks"`
+}
+
+// aliases lists networks.<name>.aliases.
+func (s composeService) aliases() []string {
+	var out []string
+	if s.Networks.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 1; i < len(s.Networks.Content); i += 2 {
+		var n struct {
+			Aliases []string `yaml:"aliases"`
+		}
+		if s.Networks.Content[i].Decode(&n) == nil {
+			out = append(out, n.Aliases...)
+		}
+	}
+	return out
+}
+
+// composePorts reads the short ("8080:80", 80) and long ({published: 8080,
+// target: 80}) port syntaxes.
+func composePorts(nodes []yaml.Node) []string {
+	var out []string
+	for _, n := range nodes {
+		switch n.Kind {
+		case yaml.ScalarNode:
+			out = append(out, n.Value)
+		case yaml.MappingNode:
+			var p struct {
+				Target    string `yaml:"target"`
+				Published string `yaml:"published"`
+			}
+			if n.Decode(&p) != nil || p.Target == "" {
+				continue
+			}
+			if p.Published != "" {
+				out = append(out, p.Published+":"+p.Target)
+			} else {
+				out = append(out, p.Target)
+			}
+		}
+	}
+	return out
+}
+
+// docNode unwraps a document node.
+func docNode(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		return n.Content[0]
+	}
+	return n
+}
+
+// mappingValue returns the value of key in a mapping node, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// nameList reads a list of names or the keys of a mapping (the long
+// depends_on form with conditions).
+func nameList(n *yaml.Node) []string {
+	var out []string
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			out = append(out, c.Value)
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(n.Content); i += 2 {
+			out = append(out, n.Content[i].Value)
+		}
+	case yaml.ScalarNode:
+		if n.Value != "" {
+			out = append(out, n.Value)
+		}
+	}
+	return out
+}
+
+// keyValues reads environment as a mapping or a list of KEY=VALUE.
+func keyValues(n *yaml.Node) [][2]string {
+	var out [][2]string
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			out = append(out, [2]string{n.Content[i].Value, n.Content[i+1].Value})
+		}
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			if k, v, ok := strings.Cut(c.Value, "="); ok {
+				out = append(out, [2]string{k, v})
+			}
+		}
+	}
+	return out
+}
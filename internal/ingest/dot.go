@@ -0,0 +1,498 @@
+package ingest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+func ParseDOT(fp string) (ParsedFile, error) {
+	b, err := os.ReadFile(fp)
+	if err != nil {
+		return ParsedFile{Name: fp}, err
+	}
+	return ParseDOTBytes(filepath.Base(fp), b), nil
+}
+
+// ParseDOTBytes parses Graphviz DOT (graph or digraph). Node types come from
+// a type attribute (written by export.DOT), then the shape, then the label;
+// edge protocols from a protocol attribute, then the label. Subgraphs named
+// cluster* become groups; other subgraphs only scope default attributes.
+func ParseDOTBytes(name string, b []byte) ParsedFile {
+	pf := ParsedFile{Name: name}
+	p := &dotParser{toks: dotTokens(string(b)), pf: &pf, nodes: map[string]int{}, groups: map[string]bool{}}
+	for !p.done() {
+		if err := p.graph(); err != nil {
+			pf.Notes = append(pf.Notes, "dot: "+err.Error())
+			break
+		}
+	}
+	if len(pf.Nodes) == 0 {
+		pf.Notes = append(pf.Notes, "dot: no nodes recognized (check syntax)")
+	}
+	return pf
+}
+
+// looksLikeDOT reports content that opens, after comments, with
+// [strict] graph|digraph [ID] {. Mermaid's "graph TD" has no brace.
+func looksLikeDOT(head []byte) bool {
+	toks := dotTokens(string(head))
+	if len(toks) > 0 && strings.EqualFold(toks[0].text, "strict") {
+		toks = toks[1:]
+	}
+	if len(toks) < 2 || !(strings.EqualFold(toks[0].text, "graph") || strings.EqualFold(toks[0].text, "digraph")) {
+		return false
+	}
+	if toks[1].text != "{" && len(toks) > 2 {
+		toks = toks[1:]
+	}
+	return toks[1].text == "{" && !toks[1].id
+}
+
+// dotShapeTypes maps Graphviz shapes that imply a component type.
+var dotShapeTypes = map[string]string{
+	"cylinder": "db", "cds": "queue", "hexagon": "gateway", "tab": "client",
+}
+
+// dotTypes folds type attribute values onto the ingest vocabulary.
+var dotTypes = map[string]string{
+	"database": "db", "datastore": "db", "db": "db",
+}
+
+type dotToken struct {
+	text string
+	// id is set for identifiers, numerals and quoted or HTML strings;
+	// unset for punctuation and edge operators.
+	id bool
+}
+
+// dotTokens splits DOT source. Comments and # lines are dropped, quoted
+// strings joined with + are concatenated, and <...> HTML strings are kept
+// whole.
+func dotTokens(src string) []dotToken {
+	var out []dotToken
+	lines := strings.Split(src, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(strings.TrimSpace(l), "#") {
+			lines[i] = ""
+		}
+	}
+	s := strings.Join(lines, "\n")
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 4
+		case strings.HasPrefix(s[i:], "->"), strings.HasPrefix(s[i:], "--"):
+			out = append(out, dotToken{text: s[i : i+2]})
+			i += 2
+		case strings.ContainsRune("{}[]=;,:", rune(c)):
+			out = append(out, dotToken{text: string(c)})
+			i++
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' && j+1 < len(s) && (s[j+1] == '"' || s[j+1] == '\n') {
+					if s[j+1] == '"' {
+						b.WriteByte('"')
+					}
+					j += 2
+					continue
+				}
+				b.WriteByte(s[j])
+				j++
+			}
+			i = j + 1
+			if n := len(out); n >= 2 && out[n-1].text == "+" && !out[n-1].id && out[n-2].id {
+				out[n-2].text += b.String()
+				out = out[:n-1]
+				continue
+			}
+			out = append(out, dotToken{text: b.String(), id: true})
+		case c == '+':
+			out = append(out, dotToken{text: "+"})
+			i++
+		case c == '<':
+			depth, j := 0, i
+			for ; j < len(s); j++ {
+				if s[j] == '<' {
+					depth++
+				} else if s[j] == '>' {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			out = append(out, dotToken{text: s[i+1 : min(j, len(s))], id: true})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n{}[]=;,:\"<+", rune(s[j])) &&
+				!strings.HasPrefix(s[j:], "->") && !strings.HasPrefix(s[j:], "--") && !strings.HasPrefix(s[j:], "//") {
+				j++
+			}
+			if j == i {
+				j++
+			}
+			out = append(out, dotToken{text: s[i:j], id: true})
+			i = j
+		}
+	}
+	return out
+}
+
+// dotScope holds the node/edge defaults of a graph or subgraph and the
+// cluster it stands for.
+type dotScope struct {
+	node, edge map[string]string
+	group      int
+}
+
+type dotParser struct {
+	toks   []dotToken
+	pos    int
+	pf     *ParsedFile
+	nodes  map[string]int
+	groups map[string]bool
+	scopes []dotScope
+	// mentioned collects the node ids seen in the subgraph being read.
+	mentioned []string
+}
+
+var errDOTSyntax = errors.New("unexpected end of input")
+
+func (p *dotParser) done() bool { return p.pos >= len(p.toks) }
+
+func (p *dotParser) peek() dotToken {
+	if p.done() {
+		return dotToken{}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *dotParser) next() dotToken {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// is reports whether the next token is the punctuation or keyword s.
+func (p *dotParser) is(s string) bool {
+	t := p.peek()
+	if t.id {
+		return strings.EqualFold(t.text, s) && s != "" && isDOTKeyword(s)
+	}
+	return t.text == s && !p.done()
+}
+
+func isDOTKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "strict", "graph", "digraph", "subgraph", "node", "edge":
+		return true
+	}
+	return false
+}
+
+func (p *dotParser) expect(s string) error {
+	if !p.is(s) {
+		if p.done() {
+			return errDOTSyntax
+		}
+		return errors.New("expected " + s + ", got " + p.peek().text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *dotParser) graph() error {
+	if p.is("strict") {
+		p.pos++
+	}
+	if !p.is("graph") && !p.is("digraph") {
+		return errors.New("expected graph or digraph, got " + p.peek().text)
+	}
+	p.pos++
+	if p.peek().id {
+		p.pos++
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	p.scopes = []dotScope{{node: map[string]string{}, edge: map[string]string{}, group: -1}}
+	return p.stmts()
+}
+
+// stmts reads statements up to and including the closing "}".
+func (p *dotParser) stmts() error {
+	for {
+		if p.done() {
+			return errDOTSyntax
+		}
+		if p.is("}") {
+			p.pos++
+			return nil
+		}
+		if p.is(";") {
+			p.pos++
+			continue
+		}
+		if err := p.stmt(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *dotParser) scope() *dotScope { return &p.scopes[len(p.scopes)-1] }
+
+func (p *dotParser) stmt() error {
+	switch {
+	case p.is("graph"):
+		p.pos++
+		attrs, err := p.attrList()
+		if err != nil {
+			return err
+		}
+		p.graphAttrs(attrs)
+		return nil
+	case p.is("node"), p.is("edge"):
+		target := p.scope().node
+		if strings.EqualFold(p.next().text, "edge") {
+			target = p.scope().edge
+		}
+		attrs, err := p.attrList()
+		if err != nil {
+			return err
+		}
+		for k, v := range attrs {
+			target[k] = v
+		}
+		return nil
+	}
+
+	// ID = ID sets a graph attribute.
+	if p.peek().id && p.pos+1 < len(p.toks) && p.toks[p.pos+1].text == "=" && !p.toks[p.pos+1].id {
+		k := p.next().text
+		p.pos++
+		v := p.next().text
+		p.graphAttrs(map[string]string{k: v})
+		return nil
+	}
+
+	ends, sub, err := p.endpoint()
+	if err != nil {
+		return err
+	}
+	if !p.is("->") && !p.is("--") {
+		if sub {
+			return nil
+		}
+		attrs, err := p.attrList()
+		if err != nil {
+			return err
+		}
+		p.declare(ends[0], attrs)
+		return nil
+	}
+	chain := [][]string{ends}
+	for p.is("->") || p.is("--") {
+		p.pos++
+		next, _, err := p.endpoint()
+		if err != nil {
+			return err
+		}
+		chain = append(chain, next)
+	}
+	attrs, err := p.attrList()
+	if err != nil {
+		return err
+	}
+	for k, v := range p.scope().edge {
+		if _, ok := attrs[k]; !ok {
+			attrs[k] = v
+		}
+	}
+	label := dotText(attrs["label"], "")
+	proto := guessProtocolFromValue(attrs["protocol"])
+	if proto == "" {
+		proto = guessProtocolFromValue(label)
+	}
+	for i := 1; i < len(chain); i++ {
+		for _, f := range chain[i-1] {
+			for _, t := range chain[i] {
+				p.pf.Edges = append(p.pf.Edges, types.Edge{From: f, To: t, Protocol: proto, Label: label})
+			}
+		}
+	}
+	return nil
+}
+
+// endpoint reads a node id (with an optional port) or a subgraph, whose
+// nodes all take part in the edge; sub reports the latter.
+func (p *dotParser) endpoint() (ids []string, sub bool, err error) {
+	if p.is("subgraph") || p.is("{") {
+		ids, err := p.subgraph()
+		return ids, true, err
+	}
+	t := p.next()
+	if !t.id {
+		if t.text == "" {
+			return nil, false, errDOTSyntax
+		}
+		return nil, false, errors.New("unexpected " + t.text)
+	}
+	for p.is(":") {
+		p.pos += 2
+	}
+	p.declare(t.text, nil)
+	p.mentioned = append(p.mentioned, t.text)
+	return []string{t.text}, false, nil
+}
+
+// subgraph reads [subgraph [ID]] { stmts } and returns the nodes it mentions.
+func (p *dotParser) subgraph() ([]string, error) {
+	var name string
+	if p.is("subgraph") {
+		p.pos++
+		if p.peek().id {
+			name = p.next().text
+		}
+	}
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	outer := p.scope()
+	sc := dotScope{node: copyAttrs(outer.node), edge: copyAttrs(outer.edge), group: outer.group}
+	if strings.HasPrefix(strings.ToLower(name), "cluster") {
+		id := strings.TrimLeft(name[len("cluster"):], "_-")
+		if id == "" {
+			id = name
+		}
+		if !p.groups[id] {
+			p.groups[id] = true
+			sc.group = len(p.pf.Groups)
+			p.pf.Groups = append(p.pf.Groups, types.Group{ID: id, Label: id, Kind: "cluster"})
+		}
+	}
+	mentioned := p.mentioned
+	p.mentioned = nil
+	p.scopes = append(p.scopes, sc)
+	err := p.stmts()
+	p.scopes = p.scopes[:len(p.scopes)-1]
+	ids := p.mentioned
+	p.mentioned = append(mentioned, ids...)
+	return ids, err
+}
+
+func copyAttrs(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// attrList reads zero or more [k=v, ...] lists.
+func (p *dotParser) attrList() (map[string]string, error) {
+	attrs := map[string]string{}
+	for p.is("[") {
+		p.pos++
+		for !p.is("]") {
+			if p.done() {
+				return nil, errDOTSyntax
+			}
+			if p.is(",") || p.is(";") {
+				p.pos++
+				continue
+			}
+			k := p.next().text
+			v := "true"
+			if p.is("=") {
+				p.pos++
+				v = p.next().text
+			}
+			attrs[strings.ToLower(k)] = v
+		}
+		p.pos++
+	}
+	return attrs, nil
+}
+
+// graphAttrs applies graph attributes; only a cluster's label and kind are
+// kept.
+func (p *dotParser) graphAttrs(attrs map[string]string) {
+	gi := p.scope().group
+	if gi < 0 || len(p.scopes) < 2 || p.scopes[len(p.scopes)-2].group == gi {
+		return
+	}
+	g := &p.pf.Groups[gi]
+	if l, ok := attrs["label"]; ok {
+		if l = dotText(l, g.ID); l != "" {
+			g.Label = l
+		}
+	}
+	if k, ok := attrs["kind"]; ok && k != "" {
+		g.Kind = strings.ToLower(k)
+	}
+}
+
+// declare creates the node on first mention, in the current cluster and
+// with the scope's defaults, and applies attrs given on a node statement.
+func (p *dotParser) declare(id string, attrs map[string]string) {
+	i, seen := p.nodes[id]
+	if !seen {
+		i = len(p.pf.Nodes)
+		p.nodes[id] = i
+		n := types.Node{ID: id, Label: id, Source: "dot"}
+		if gi := p.scope().group; gi >= 0 {
+			n.Group = p.pf.Groups[gi].ID
+			p.pf.Groups[gi].Members = append(p.pf.Groups[gi].Members, id)
+		}
+		p.pf.Nodes = append(p.pf.Nodes, n)
+		merged := copyAttrs(p.scope().node)
+		for k, v := range attrs {
+			merged[k] = v
+		}
+		attrs = merged
+	} else if len(attrs) == 0 {
+		return
+	}
+	n := &p.pf.Nodes[i]
+	if l, ok := attrs["label"]; ok {
+		if l = dotText(l, id); l != "" {
+			n.Label = l
+		}
+	}
+	switch t := strings.ToLower(attrs["type"]); {
+	case t != "":
+		if d, ok := dotTypes[t]; ok {
+			t = d
+		}
+		n.Type = t
+	case dotShapeTypes[strings.ToLower(attrs["shape"])] != "":
+		n.Type = dotShapeTypes[strings.ToLower(attrs["shape"])]
+	case n.Type == "" || attrs["label"] != "":
+		n.Type = guessTypeFromLabel(n.Label)
+	}
+}
+
+// dotText turns a DOT label into plain text: \N is the node name, \n \l \r
+// are line breaks, \\ is a backslash, and HTML labels lose their markup.
+func dotText(v, id string) string {
+	v = strings.NewReplacer(`\\`, `\`, `\N`, id, `\n`, " ", `\l`, " ", `\r`, " ").Replace(v)
+	return cellText(v)
+}
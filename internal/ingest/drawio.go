@@ -0,0 +1,390 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+type mxfile struct {
+	Diagram []diagram `xml:"diagram"`
+}
+
+// diagram is one page. Uncompressed pages carry an mxGraphModel child;
+// compressed ones hold base64(deflate(encodeURIComponent(xml))) as text.
+type diagram struct {
+	Name         string        `xml:"name,attr"`
+	MxGraphModel *mxGraphModel `xml:"mxGraphModel"`
+	Content      string        `xml:",chardata"`
+}
+type mxGraphModel struct {
+	Root root `xml:"root"`
+}
+
+// root keeps mxCell, UserObject and object children in document order.
+type root struct {
+	Items []mxItem `xml:",any"`
+}
+
+// mxItem is an mxCell, or a UserObject/object wrapper whose id and label sit
+// on the wrapper and the rest on its inner mxCell.
+type mxItem struct {
+	XMLName  xml.Name
+	ID       string      `xml:"id,attr"`
+	Label    string      `xml:"label,attr"`
+	Value    string      `xml:"value,attr"`
+	Style    string      `xml:"style,attr"`
+	Vertex   string      `xml:"vertex,attr"`
+	Edge     string      `xml:"edge,attr"`
+	Source   string      `xml:"source,attr"`
+	Target   string      `xml:"target,attr"`
+	Parent   string      `xml:"parent,attr"`
+	Geometry *mxGeometry `xml:"mxGeometry"`
+	Cell     *mxCell     `xml:"mxCell"`
+}
+
+type mxCell struct {
+	ID       string      `xml:"id,attr"`
+	Value    string      `xml:"value,attr"`
+	Style    string      `xml:"style,attr"`
+	Vertex   string      `xml:"vertex,attr"`
+	Edge     string      `xml:"edge,attr"`
+	Source   string      `xml:"source,attr"`
+	Target   string      `xml:"target,attr"`
+	Parent   string      `xml:"parent,attr"`
+	Geometry *mxGeometry `xml:"mxGeometry"`
+}
+
+type mxGeometry struct {
+	X      float64 `xml:"x,attr"`
+	Y      float64 `xml:"y,attr"`
+	Width  float64 `xml:"width,attr"`
+	Height float64 `xml:"height,attr"`
+}
+
+func (it mxItem) cell() mxCell {
+	if it.Cell == nil {
+		return mxCell{
+			ID: it.ID, Value: it.Value, Style: it.Style, Vertex: it.Vertex, Edge: it.Edge,
+			Source: it.Source, Target: it.Target, Parent: it.Parent, Geometry: it.Geometry,
+		}
+	}
+	c := *it.Cell
+	c.ID, c.Value = it.ID, it.Label
+	return c
+}
+
+func ParseDrawIO(path string) (ParsedFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ParsedFile{Name: path}, err
+	}
+	return ParseDrawIOBytes(path, b), nil
+}
+
+// ParseDrawIOBytes parses a draw.io document held in memory: an mxfile with
+// compressed or plain pages, or a bare mxGraphModel. draw.io numbers cells
+// per page, so in multi-page files ids are prefixed with the page ("p2:3").
+func ParseDrawIOBytes(name string, b []byte) ParsedFile {
+	pf := ParsedFile{Name: name}
+	var models []*mxGraphModel
+	var prefixes []string
+	if bytes.Contains(b, []byte("<mxfile")) {
+		var doc mxfile
+		if err := xml.Unmarshal(b, &doc); err != nil {
+			pf.Notes = append(pf.Notes, "xml unmarshal failed")
+			return pf
+		}
+		budget := int64(maxInflated)
+		for i, d := range doc.Diagram {
+			m, err := d.model(&budget)
+			if err != nil {
+				pf.Notes = append(pf.Notes, "drawio: page "+pageName(d, i)+": "+err.Error())
+				continue
+			}
+			models = append(models, m)
+			if len(doc.Diagram) > 1 {
+				prefixes = append(prefixes, "p"+itoa(i+1)+":")
+			} else {
+				prefixes = append(prefixes, "")
+			}
+		}
+	} else {
+		var m mxGraphModel
+		if err := xml.Unmarshal(b, &m); err != nil {
+			pf.Notes = append(pf.Notes, "xml unmarshal failed")
+			return pf
+		}
+		models = append(models, &m)
+		prefixes = append(prefixes, "")
+	}
+	for i, m := range models {
+		parseModel(&pf, m, prefixes[i])
+	}
+	return pf
+}
+
+func pageName(d diagram, i int) string {
+	if d.Name != "" {
+		return strconv.Quote(d.Name)
+	}
+	return itoa(i + 1)
+}
+
+// model returns the page's graph model, inflating a compressed page within
+// the document's remaining budget.
+func (d diagram) model(budget *int64) (*mxGraphModel, error) {
+	if d.MxGraphModel != nil {
+		return d.MxGraphModel, nil
+	}
+	s := strings.TrimSpace(d.Content)
+	if s == "" {
+		return &mxGraphModel{}, nil
+	}
+	raw, err := inflatePage(s, *budget)
+	if err != nil {
+		return nil, err
+	}
+	*budget -= int64(len(raw))
+	var m mxGraphModel
+	if err := xml.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// maxInflated caps what the compressed pages of one document may inflate
+// to, 8x the default MAX_BODY_BYTES, so a small upload cannot expand into
+// gigabytes.
+const maxInflated = 64 << 20
+
+var errInflateLimit = errors.New("compressed pages inflate beyond " + itoa(maxInflated>>20) + " MB; skipped")
+
+// inflatePage undoes draw.io's page compression, reading at most limit
+// bytes. Older files skip the URL-encoding step.
+func inflatePage(s string, limit int64) ([]byte, error) {
+	z, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(z)), limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > limit {
+		return nil, errInflateLimit
+	}
+	if bytes.HasPrefix(raw, []byte("%3C")) {
+		u, err := url.PathUnescape(string(raw))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(u), nil
+	}
+	return raw, nil
+}
+
+// parseModel maps one page: shapes become nodes (typed from style, then
+// label), containers become groups, edge label cells are folded into their
+// edge, and geometry is made absolute. Cell ids, and the ids they refer
+// to, get prefix.
+func parseModel(pf *ParsedFile, m *mxGraphModel, prefix string) {
+	cells := make([]mxCell, 0, len(m.Root.Items))
+	byID := map[string]int{}
+	for _, it := range m.Root.Items {
+		c := it.cell()
+		if c.ID == "" {
+			continue
+		}
+		if prefix != "" {
+			for _, id := range []*string{&c.ID, &c.Parent, &c.Source, &c.Target} {
+				if *id != "" {
+					*id = prefix + *id
+				}
+			}
+		}
+		byID[c.ID] = len(cells)
+		cells = append(cells, c)
+	}
+	isVertex := func(id string) bool {
+		i, ok := byID[id]
+		return ok && cells[i].Vertex == "1"
+	}
+	isEdge := func(id string) bool {
+		i, ok := byID[id]
+		return ok && cells[i].Edge == "1"
+	}
+
+	styles := make([]style, len(cells))
+	hasChildren := map[string]bool{}
+	connected := map[string]bool{}
+	edgeLabels := map[string][]string{}
+	for i, c := range cells {
+		styles[i] = parseStyle(c.Style)
+		switch {
+		case c.Edge == "1":
+			connected[c.Source], connected[c.Target] = true, true
+		case c.Vertex == "1" && isEdge(c.Parent):
+			if l := cellText(c.Value); l != "" {
+				edgeLabels[c.Parent] = append(edgeLabels[c.Parent], l)
+			}
+		case c.Vertex == "1" && isVertex(c.Parent):
+			hasChildren[c.Parent] = true
+		}
+	}
+
+	var absolute func(i int, depth int) (float64, float64)
+	absolute = func(i int, depth int) (float64, float64) {
+		c := cells[i]
+		var x, y float64
+		if c.Geometry != nil {
+			x, y = c.Geometry.X, c.Geometry.Y
+		}
+		if p, ok := byID[c.Parent]; ok && cells[p].Vertex == "1" && depth < 32 {
+			px, py := absolute(p, depth+1)
+			x, y = x+px, y+py
+		}
+		return x, y
+	}
+	bbox := func(i int) [4]int {
+		g := cells[i].Geometry
+		if g == nil {
+			return [4]int{}
+		}
+		x, y := absolute(i, 0)
+		return [4]int{int(x), int(y), int(g.Width), int(g.Height)}
+	}
+
+	groupIdx := map[string]int{}
+	for i, c := range cells {
+		if c.Vertex != "1" || isEdge(c.Parent) || !(styles[i].container() || hasChildren[c.ID]) {
+			continue
+		}
+		groupIdx[c.ID] = len(pf.Groups)
+		pf.Groups = append(pf.Groups, types.Group{
+			ID:    c.ID,
+			Label: cellText(c.Value),
+			Kind:  styles[i].groupKind(),
+			BBox:  bbox(i),
+		})
+	}
+
+	for i, c := range cells {
+		if c.Vertex != "1" || isEdge(c.Parent) {
+			continue
+		}
+		if _, ok := groupIdx[c.ID]; ok {
+			continue
+		}
+		label := cellText(c.Value)
+		if styles[i].textOnly() && !connected[c.ID] {
+			// free-standing annotation, not a component
+			continue
+		}
+		typ := styles[i].nodeType()
+		if label == "" {
+			label = "node-" + c.ID
+		}
+		if typ == "" {
+			typ = guessTypeFromLabel(label)
+		}
+		n := types.Node{ID: c.ID, Type: typ, Label: label, Source: "drawio", BBox: bbox(i)}
+		if gi, ok := groupIdx[c.Parent]; ok {
+			n.Group = c.Parent
+			pf.Groups[gi].Members = append(pf.Groups[gi].Members, c.ID)
+		}
+		pf.Nodes = append(pf.Nodes, n)
+	}
+
+	for _, c := range cells {
+		if c.Edge != "1" {
+			continue
+		}
+		if c.Source == "" || c.Target == "" {
+			pf.Notes = append(pf.Notes, "drawio: edge "+c.ID+" is not connected at both ends; skipped")
+			continue
+		}
+		parts := edgeLabels[c.ID]
+		if l := cellText(c.Value); l != "" {
+			parts = append([]string{l}, parts...)
+		}
+		label := strings.Join(parts, " ")
+		pf.Edges = append(pf.Edges, types.Edge{
+			From: c.Source, To: c.Target,
+			Protocol: guessProtocolFromValue(label),
+			Label:    label,
+		})
+	}
+}
+
+var (
+	reBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(div|p|li)>`)
+	reTag   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// cellText turns an HTML cell value into plain text on one line.
+func cellText(v string) string {
+	v = reBreak.ReplaceAllString(v, " ")
+	v = reTag.ReplaceAllString(v, "")
+	v = html.UnescapeString(v)
+	return strings.Join(strings.Fields(v), " ")
+}
+
+func guessTypeFromLabel(label string) string {
+	l := strings.ToLower(label)
+	switch {
+	case strings.HasPrefix(l, "db:"):
+		return "db"
+	case strings.HasPrefix(l, "q:"), strings.Contains(l, "queue"):
+		return "queue"
+	case strings.Contains(l, "gateway") || strings.HasPrefix(l, "gw:"):
+		return "gateway"
+	default:
+		return "service"
+	}
+}
+
+// protocolVerbs and protocolWords map words in edge labels and technology
+// strings onto the REST|gRPC|PUB|SUB vocabulary. A direction verb ("consumes
+// from Kafka") wins over a transport word; otherwise the first match in label
+// order wins. Datastore protocols (SQL, JDBC) map to nothing.
+var protocolVerbs = map[string]string{
+	"pub": "PUB", "publish": "PUB", "publishes": "PUB", "produce": "PUB", "produces": "PUB", "emit": "PUB", "emits": "PUB",
+	"sub": "SUB", "subscribe": "SUB", "subscribes": "SUB", "consume": "SUB", "consumes": "SUB", "listens": "SUB",
+}
+
+var protocolWords = map[string]string{
+	"grpc": "gRPC", "protobuf": "gRPC",
+	"rest": "REST", "http": "REST", "https": "REST", "json": "REST", "graphql": "REST", "soap": "REST",
+	"websocket": "REST", "websockets": "REST", "webhook": "REST",
+	"kafka": "PUB", "amqp": "PUB", "rabbitmq": "PUB", "mqtt": "PUB", "nats": "PUB", "jms": "PUB",
+	"sqs": "PUB", "sns": "PUB", "event": "PUB", "events": "PUB", "async": "PUB", "message": "PUB", "messages": "PUB",
+}
+
+var reWord = regexp.MustCompile(`[a-z0-9]+`)
+
+func guessProtocolFromValue(v string) string {
+	words := reWord.FindAllString(strings.ToLower(v), -1)
+	for _, w := range words {
+		if p, ok := protocolVerbs[w]; ok {
+			return p
+		}
+	}
+	for _, w := range words {
+		if p, ok := protocolWords[w]; ok {
+			return p
+		}
+	}
+	return ""
+}
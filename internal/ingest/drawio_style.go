@@ -0,0 +1,152 @@
+package ingest
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// style is a parsed draw.io style string ("shape=cylinder3;whiteSpace=wrap;").
+// Bare tokens such as "swimlane" or "text" are stored under "".
+type style map[string]string
+
+func parseStyle(s string) style {
+	st := style{}
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			if st[""] == "" {
+				st[""] = strings.ToLower(part)
+			}
+			continue
+		}
+		st[k] = v
+	}
+	return st
+}
+
+// names are the lower-cased shape identifiers the type tables match on:
+// shape, AWS resIcon, GCP/Kubernetes prIcon, the image file name (Azure
+// icons) and its folder, and the bare style name.
+func (st style) names() []string {
+	var out []string
+	for _, k := range []string{"shape", "resIcon", "prIcon", "grIcon", ""} {
+		if v := strings.ToLower(st[k]); v != "" {
+			out = append(out, v)
+		}
+	}
+	if img := strings.ToLower(st["image"]); img != "" && !strings.HasPrefix(img, "data:") {
+		if u, _, _ := strings.Cut(img, "?"); u != "" {
+			out = append(out, strings.TrimSuffix(path.Base(u), path.Ext(u)), path.Base(path.Dir(u)))
+		}
+	}
+	return out
+}
+
+var reStyleWord = regexp.MustCompile(`[a-z0-9]+`)
+
+// styleRule assigns typ when any shape name contains one of names, or any
+// word of a shape name equals one of words (short, ambiguous tokens).
+type styleRule struct {
+	typ   string
+	names []string
+	words []string
+}
+
+// styleRules are checked in order; the first match wins. Icons from the
+// AWS (mxgraph.aws4.*), Azure (img/lib/azure2/...), GCP (mxgraph.gcp2.*) and
+// Kubernetes (mxgraph.kubernetes.*) libraries are covered by name.
+var styleRules = []styleRule{
+	{typ: "actor", names: []string{"umlactor", "actor", "person"}, words: []string{"user", "users"}},
+	{typ: "client", names: []string{"mobile_client", "mobile", "browser", "desktop", "laptop", "client"}},
+	{typ: "gateway", names: []string{"api_gateway", "apigateway", "api_management", "apigee", "gateway", "ingress", "front_door"}, words: []string{"ing", "apim"}},
+	{typ: "topic", names: []string{"pubsub", "pub_sub", "notification", "eventbridge", "event_bridge", "event_grid", "topic"}, words: []string{"sns"}},
+	{typ: "stream", names: []string{"kinesis", "kafka", "event_hub", "streaming", "dataflow"}, words: []string{"msk"}},
+	{typ: "queue", names: []string{"queue", "service_bus", "cloud_tasks", "rabbitmq"}, words: []string{"sqs", "mq"}},
+	{typ: "db", names: []string{
+		"cylinder", "datastore", "database", "dynamodb", "aurora", "elasticache", "redis", "memcache",
+		"memorystore", "cosmos", "bigtable", "spanner", "firestore", "bigquery", "redshift", "documentdb",
+		"neptune", "keyspaces", "timestream", "storage", "bucket", "blob", "cache",
+	}, words: []string{"rds", "sql", "s3", "pv", "pvc", "efs", "ebs"}},
+	{typ: "ext", names: []string{"internet"}, words: []string{"cloud"}},
+	{typ: "service", names: []string{
+		"lambda", "function", "ec2", "ecs", "eks", "fargate", "container", "instance", "app_service",
+		"cloud_run", "app_engine", "compute", "kubernetes", "deploy", "server",
+	}, words: []string{"pod", "svc", "vm", "aks", "gke"}},
+}
+
+// nodeType infers the component type from the shape, or "" when the style
+// says nothing and the label has to decide.
+func (st style) nodeType() string {
+	names := st.names()
+	for _, r := range styleRules {
+		for _, n := range names {
+			for _, s := range r.names {
+				if strings.Contains(n, s) {
+					return r.typ
+				}
+			}
+			for _, w := range reStyleWord.FindAllString(n, -1) {
+				for _, s := range r.words {
+					if w == s {
+						if r.typ == "ext" && n != w {
+							continue // "cloud" only as the whole shape, not cloud_sql
+						}
+						return r.typ
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// container reports cells draw.io draws as containers: swimlanes, groups,
+// container=1 shapes and the cloud provider group shapes.
+func (st style) container() bool {
+	if st["container"] == "1" {
+		return true
+	}
+	switch st[""] {
+	case "swimlane", "group":
+		return true
+	}
+	sh := strings.ToLower(st["shape"])
+	return strings.Contains(sh, ".group") || strings.Contains(sh, "groupcenter") || st["grIcon"] != ""
+}
+
+func (st style) groupKind() string {
+	icon := strings.ToLower(st["grIcon"] + " " + st["shape"] + " " + st["prIcon"])
+	switch {
+	case strings.Contains(icon, "vpc"):
+		return "vpc"
+	case strings.Contains(icon, "subnet"):
+		return "subnet"
+	case strings.Contains(icon, "availability_zone"):
+		return "zone"
+	case strings.Contains(icon, "region"):
+		return "region"
+	case strings.Contains(icon, "security_group"):
+		return "security_group"
+	case strings.Contains(icon, "cloud"), strings.Contains(icon, "account"):
+		return "cloud"
+	case strings.EqualFold(st["prIcon"], "ns"), strings.Contains(icon, "namespace"):
+		return "namespace"
+	case st[""] == "swimlane":
+		return "swimlane"
+	}
+	return "group"
+}
+
+// textOnly reports free text and edge labels, which are not components.
+func (st style) textOnly() bool {
+	switch st[""] {
+	case "text", "edgelabel", "label":
+		return true
+	}
+	return st["text"] == "1"
+}
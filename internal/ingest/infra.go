@@ -0,0 +1,160 @@
+package ingest
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+// yamlKind tells docker-compose files from Kubernetes manifests; other YAML
+// (including the architecture yaml_content format, whose services are a
+// list) is "".
+func yamlKind(data []byte) string {
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return ""
+	}
+	if _, ok := doc["apiVersion"]; ok {
+		if _, ok := doc["kind"]; ok {
+			return "kubernetes"
+		}
+	}
+	if svcs, ok := doc["services"].(map[string]any); ok {
+		for _, v := range svcs {
+			s, ok := v.(map[string]any)
+			if !ok {
+				return ""
+			}
+			if s["image"] != nil || s["build"] != nil {
+				return "compose"
+			}
+		}
+	}
+	return ""
+}
+
+// imageType types a container image by its repository words, dropping the
+// registry and tag: bitnami/postgresql:15 -> db, confluentinc/cp-kafka -> queue.
+func imageType(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	repo := image
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo = image[:i]
+	}
+	if first, rest, ok := strings.Cut(repo, "/"); ok && strings.ContainsAny(first, ".:") {
+		repo = rest
+	}
+	return techType(repo)
+}
+
+// hostKeyWords mark env vars whose plain values (no scheme) are host names.
+var hostKeyWords = map[string]bool{
+	"host": true, "hosts": true, "hostname": true, "addr": true, "address": true, "url": true, "uri": true,
+	"server": true, "servers": true, "broker": true, "brokers": true, "endpoint": true, "dsn": true,
+	"service": true, "upstream": true, "target": true, "bootstrap": true,
+}
+
+// envHosts returns the host names an env var points at: the hosts of URLs
+// (postgres://u:p@db:5432/x, jdbc:mysql://db/x), or plain host[:port] lists
+// when the key names a host (DB_HOST=db, KAFKA_BROKERS=k1:9092,k2:9092).
+func envHosts(key, value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	var items []string
+	if _, rest, ok := strings.Cut(value, "://"); ok {
+		auth, _, _ := strings.Cut(rest, "/")
+		auth, _, _ = strings.Cut(auth, "?")
+		if i := strings.LastIndex(auth, "@"); i >= 0 {
+			auth = auth[i+1:]
+		}
+		items = strings.Split(auth, ",")
+	} else {
+		named := false
+		for _, w := range reWord.FindAllString(strings.ToLower(key), -1) {
+			named = named || hostKeyWords[w]
+		}
+		if !named {
+			return nil
+		}
+		items = strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == ' ' })
+	}
+	var hosts []string
+	for _, it := range items {
+		h, _, _ := strings.Cut(strings.TrimSpace(it), ":")
+		if h != "" && !strings.ContainsAny(h, "/=$()") {
+			hosts = append(hosts, strings.ToLower(h))
+		}
+	}
+	return hosts
+}
+
+// envSchemes maps URL schemes onto protocols; datastore schemes map to "".
+var envSchemes = map[string]string{
+	"http": "REST", "https": "REST", "ws": "REST", "wss": "REST",
+	"grpc": "gRPC", "grpcs": "gRPC",
+	"kafka": "PUB", "amqp": "PUB", "amqps": "PUB", "nats": "PUB", "mqtt": "PUB",
+	"postgres": "", "postgresql": "", "mysql": "", "mongodb": "", "mongodb+srv": "", "redis": "", "rediss": "",
+	"jdbc": "",
+}
+
+// envProtocol reads the protocol of an env-var reference from the URL scheme,
+// else from the words of the key (ORDERS_GRPC_ADDR, KAFKA_BROKERS).
+func envProtocol(key, value string) string {
+	if scheme, _, ok := strings.Cut(strings.TrimSpace(value), "://"); ok {
+		scheme = strings.ToLower(scheme)
+		if s, _, ok := strings.Cut(scheme, ":"); ok {
+			scheme = s // jdbc:postgresql
+		}
+		if p, ok := envSchemes[scheme]; ok {
+			return p
+		}
+	}
+	return guessProtocolFromValue(strings.ReplaceAll(key, "_", " "))
+}
+
+// infraGraph collects nodes and de-duplicated edges for the compose and
+// Kubernetes importers.
+type infraGraph struct {
+	pf    *ParsedFile
+	nodes map[string]int
+	edges map[[2]string]int
+}
+
+func newInfraGraph(pf *ParsedFile) *infraGraph {
+	return &infraGraph{pf: pf, nodes: map[string]int{}, edges: map[[2]string]int{}}
+}
+
+func (g *infraGraph) node(n types.Node) *types.Node {
+	if i, ok := g.nodes[n.ID]; ok {
+		return &g.pf.Nodes[i]
+	}
+	g.nodes[n.ID] = len(g.pf.Nodes)
+	g.pf.Nodes = append(g.pf.Nodes, n)
+	return &g.pf.Nodes[len(g.pf.Nodes)-1]
+}
+
+// link adds from -> to once; a repeated link fills in a missing protocol and
+// adds its label.
+func (g *infraGraph) link(from, to, proto, label string) {
+	if from == to {
+		return
+	}
+	k := [2]string{from, to}
+	if i, ok := g.edges[k]; ok {
+		e := &g.pf.Edges[i]
+		if e.Protocol == "" {
+			e.Protocol = proto
+		}
+		if label != "" && !strings.Contains(e.Label, label) {
+			e.Label = strings.TrimPrefix(e.Label+", "+label, ", ")
+		}
+		return
+	}
+	g.edges[k] = len(g.pf.Edges)
+	g.pf.Edges = append(g.pf.Edges, types.Edge{From: from, To: to, Protocol: proto, Label: label})
+}
@@ -0,0 +1,443 @@
+package ingest
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+func ParseKubernetes(fp string) (ParsedFile, error) {
+	b, err := os.ReadFile(fp)
+	if err != nil {
+		return ParsedFile{Name: fp}, err
+	}
+	return ParseKubernetesBytes(filepath.Base(fp), b)
+}
+
+// ParseKubernetesBytes reads Kubernetes manifests (multi-document, List
+// kinds included). Workloads (Deployment, StatefulSet, DaemonSet, Job,
+// CronJob, Pod) are nodes typed by their container images, with container
+// ports. A Service stands for the workloads its selector matches: env vars
+// and ConfigMap entries naming a Service host (orders, orders.shop,
+// orders.shop.svc.cluster.local) become edges to them, and Ingress rules
+// become a gateway node routing to them. Namespaces set in metadata become
+// groups.
+func ParseKubernetesBytes(name string, b []byte) (ParsedFile, error) {
+	pf := ParsedFile{Name: name}
+	var objs []k8sObject
+	dec := yaml.NewDecoder(strings.NewReader(string(b)))
+	for {
+		var o k8sObject
+		err := dec.Decode(&o)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return pf, err
+		}
+		objs = append(objs, o.flatten()...)
+	}
+	k := &k8sGraph{
+		g:          newInfraGraph(&pf),
+		configMaps: map[string]map[string]string{},
+		services:   map[string][]string{},
+		namespaces: map[string]bool{},
+		groups:     map[string]bool{},
+	}
+	k.build(objs)
+	if len(pf.Nodes) == 0 {
+		pf.Notes = append(pf.Notes, "kubernetes: no workloads, services or ingresses found")
+	}
+	return pf, nil
+}
+
+type k8sMeta struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
+type k8sObject struct {
+	Kind     string            `yaml:"kind"`
+	Metadata k8sMeta           `yaml:"metadata"`
+	Spec     yaml.Node         `yaml:"spec"`
+	Data     map[string]string `yaml:"data"`
+	Items    []k8sObject       `yaml:"items"`
+}
+
+// flatten expands List kinds into their items.
+func (o k8sObject) flatten() []k8sObject {
+	if !strings.HasSuffix(o.Kind, "List") {
+		if o.Kind == "" {
+			return nil
+		}
+		return []k8sObject{o}
+	}
+	var out []k8sObject
+	for _, it := range o.Items {
+		out = append(out, it.flatten()...)
+	}
+	return out
+}
+
+func (o k8sObject) namespace() string {
+	if o.Metadata.Namespace == "" {
+		return "default"
+	}
+	return o.Metadata.Namespace
+}
+
+type k8sPod struct {
+	Metadata k8sMeta `yaml:"metadata"`
+	Spec     struct {
+		Containers     []k8sContainer `yaml:"containers"`
+		InitContainers []k8sContainer `yaml:"initContainers"`
+	} `yaml:"spec"`
+}
+
+type k8sContainer struct {
+	Image string `yaml:"image"`
+	Ports []struct {
+		ContainerPort int `yaml:"containerPort"`
+	} `yaml:"ports"`
+	Env []struct {
+		Name      string `yaml:"name"`
+		Value     string `yaml:"value"`
+		ValueFrom struct {
+			ConfigMapKeyRef *struct {
+				Name string `yaml:"name"`
+				Key  string `yaml:"key"`
+			} `yaml:"configMapKeyRef"`
+		} `yaml:"valueFrom"`
+	} `yaml:"env"`
+	EnvFrom []struct {
+		ConfigMapRef *struct {
+			Name string `yaml:"name"`
+		} `yaml:"configMapRef"`
+	} `yaml:"envFrom"`
+}
+
+type k8sService struct {
+	Type         string            `yaml:"type"`
+	Selector     map[string]string `yaml:"selector"`
+	ExternalName string            `yaml:"externalName"`
+	Ports        []struct {
+		Port     int `yaml:"port"`
+		NodePort int `yaml:"nodePort"`
+	} `yaml:"ports"`
+}
+
+// k8sBackend covers networking.k8s.io/v1 (service.name) and v1beta1
+// (serviceName) Ingress backends.
+type k8sBackend struct {
+	ServiceName string `yaml:"serviceName"`
+	Service     struct {
+		Name string `yaml:"name"`
+	} `yaml:"service"`
+}
+
+func (b k8sBackend) name() string {
+	if b.Service.Name != "" {
+		return b.Service.Name
+	}
+	return b.ServiceName
+}
+
+type k8sIngress struct {
+	DefaultBackend *k8sBackend `yaml:"defaultBackend"`
+	Backend        *k8sBackend `yaml:"backend"`
+	Rules          []struct {
+		Host string `yaml:"host"`
+		HTTP struct {
+			Paths []struct {
+				Path    string     `yaml:"path"`
+				Backend k8sBackend `yaml:"backend"`
+			} `yaml:"paths"`
+		} `yaml:"http"`
+	} `yaml:"rules"`
+}
+
+// k8sWorkload is a node with the pods it runs.
+type k8sWorkload struct {
+	id     string
+	ns     string
+	labels map[string]string
+	pod    k8sPod
+}
+
+type k8sGraph struct {
+	g          *infraGraph
+	workloads  []k8sWorkload
+	configMaps map[string]map[string]string // "ns/name" -> data
+	// services maps "ns/name" of a Service onto the node ids it stands for.
+	services   map[string][]string
+	namespaces map[string]bool
+	groups     map[string]bool
+}
+
+func (k *k8sGraph) note(s string) { k.g.pf.Notes = append(k.g.pf.Notes, "kubernetes: "+s) }
+
+func (k *k8sGraph) build(objs []k8sObject) {
+	for _, o := range objs {
+		k.namespaces[o.namespace()] = true
+		switch o.Kind {
+		case "ConfigMap":
+			k.configMaps[o.namespace()+"/"+o.Metadata.Name] = o.Data
+		case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job", "CronJob", "Pod":
+			k.workload(o)
+		}
+	}
+	for _, o := range objs {
+		if o.Kind == "Service" {
+			k.service(o)
+		}
+	}
+	for _, o := range objs {
+		if o.Kind == "Ingress" {
+			k.ingress(o)
+		}
+	}
+	for _, w := range k.workloads {
+		k.envLinks(w)
+	}
+}
+
+func (k *k8sGraph) workload(o k8sObject) {
+	var pod k8sPod
+	switch o.Kind {
+	case "Pod":
+		pod.Metadata = o.Metadata
+		if err := o.Spec.Decode(&pod.Spec); err != nil {
+			k.note(o.Kind + " " + o.Metadata.Name + ": " + err.Error())
+			return
+		}
+	case "CronJob":
+		var s struct {
+			JobTemplate struct {
+				Spec struct {
+					Template k8sPod `yaml:"template"`
+				} `yaml:"spec"`
+			} `yaml:"jobTemplate"`
+		}
+		if err := o.Spec.Decode(&s); err != nil {
+			k.note(o.Kind + " " + o.Metadata.Name + ": " + err.Error())
+			return
+		}
+		pod = s.JobTemplate.Spec.Template
+	default:
+		var s struct {
+			Template k8sPod `yaml:"template"`
+		}
+		if err := o.Spec.Decode(&s); err != nil {
+			k.note(o.Kind + " " + o.Metadata.Name + ": " + err.Error())
+			return
+		}
+		pod = s.Template
+	}
+	id := o.Metadata.Name
+	if id == "" {
+		return
+	}
+	if _, dup := k.g.nodes[id]; dup {
+		k.note(o.Kind + " " + id + " repeats a workload name; skipped")
+		return
+	}
+
+	typ := ""
+	var ports []string
+	for _, c := range pod.Spec.Containers {
+		if typ == "" {
+			typ = imageType(c.Image)
+		}
+		for _, p := range c.Ports {
+			if p.ContainerPort > 0 {
+				ports = append(ports, strconv.Itoa(p.ContainerPort))
+			}
+		}
+	}
+	if typ == "" {
+		typ = techType(id)
+	}
+	if typ == "" {
+		typ = guessTypeFromLabel(id)
+	}
+	n := k.g.node(types.Node{ID: id, Type: typ, Label: id, Source: "kubernetes", Ports: ports})
+	k.inNamespace(n, o)
+	labels := pod.Metadata.Labels
+	if len(labels) == 0 {
+		labels = o.Metadata.Labels
+	}
+	k.workloads = append(k.workloads, k8sWorkload{id: id, ns: o.namespace(), labels: labels, pod: pod})
+}
+
+// inNamespace puts the node in a group for an explicitly set namespace.
+func (k *k8sGraph) inNamespace(n *types.Node, o k8sObject) {
+	ns := o.Metadata.Namespace
+	if ns == "" {
+		return
+	}
+	n.Group = ns
+	pf := k.g.pf
+	if !k.groups[ns] {
+		k.groups[ns] = true
+		pf.Groups = append(pf.Groups, types.Group{ID: ns, Label: ns, Kind: "namespace"})
+	}
+	for i := range pf.Groups {
+		if pf.Groups[i].ID == ns {
+			pf.Groups[i].Members = append(pf.Groups[i].Members, n.ID)
+		}
+	}
+}
+
+func (k *k8sGraph) service(o k8sObject) {
+	var s k8sService
+	if err := o.Spec.Decode(&s); err != nil {
+		k.note("Service " + o.Metadata.Name + ": " + err.Error())
+		return
+	}
+	key := o.namespace() + "/" + o.Metadata.Name
+	var ids []string
+	for _, w := range k.workloads {
+		if w.ns == o.namespace() && len(s.Selector) > 0 && selects(s.Selector, w.labels) {
+			ids = append(ids, w.id)
+		}
+	}
+	if len(ids) == 0 {
+		// A Service without a workload here (ExternalName, a managed
+		// database, another team's app) is a node of its own.
+		id := o.Metadata.Name
+		typ, label := techType(id), id
+		if s.ExternalName != "" {
+			typ, label = "ext", id+" ("+s.ExternalName+")"
+		} else if typ == "" {
+			typ = guessTypeFromLabel(id)
+		}
+		if _, taken := k.g.nodes[id]; taken {
+			k.note("Service " + id + " selects no workload and shares a workload's name; skipped")
+			return
+		}
+		n := k.g.node(types.Node{ID: id, Type: typ, Label: label, Source: "kubernetes"})
+		k.inNamespace(n, o)
+		if s.ExternalName == "" {
+			k.note("Service " + id + " selects no workload in these manifests; kept as its own node")
+		}
+		ids = []string{id}
+	}
+	k.services[key] = ids
+	if s.Type == "NodePort" || s.Type == "LoadBalancer" {
+		for _, p := range s.Ports {
+			port := strconv.Itoa(p.Port)
+			if p.NodePort > 0 {
+				port = strconv.Itoa(p.NodePort) + ":" + port
+			}
+			for _, id := range ids {
+				n := &k.g.pf.Nodes[k.g.nodes[id]]
+				n.Ports = append(n.Ports, port+" ("+s.Type+")")
+			}
+		}
+	}
+}
+
+func selects(selector, labels map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (k *k8sGraph) ingress(o k8sObject) {
+	var s k8sIngress
+	if err := o.Spec.Decode(&s); err != nil {
+		k.note("Ingress " + o.Metadata.Name + ": " + err.Error())
+		return
+	}
+	id := o.Metadata.Name
+	if _, taken := k.g.nodes[id]; taken {
+		id = "ingress-" + id
+	}
+	n := k.g.node(types.Node{ID: id, Type: "gateway", Label: o.Metadata.Name, Source: "kubernetes"})
+	k.inNamespace(n, o)
+	route := func(b *k8sBackend, label string) {
+		if b == nil || b.name() == "" {
+			return
+		}
+		targets, ok := k.services[o.namespace()+"/"+b.name()]
+		if !ok {
+			k.note("Ingress " + o.Metadata.Name + " routes to unknown Service " + b.name())
+			return
+		}
+		for _, t := range targets {
+			k.g.link(id, t, "REST", label)
+		}
+	}
+	for _, r := range s.Rules {
+		for _, p := range r.HTTP.Paths {
+			b := p.Backend
+			route(&b, strings.TrimSpace(r.Host+p.Path))
+		}
+	}
+	route(s.DefaultBackend, "default")
+	route(s.Backend, "default")
+}
+
+// envLinks adds edges for env vars (inline, configMapKeyRef and envFrom
+// ConfigMaps) that name a Service host.
+func (k *k8sGraph) envLinks(w k8sWorkload) {
+	for _, c := range append(w.pod.Spec.InitContainers, w.pod.Spec.Containers...) {
+		var env [][2]string
+		for _, e := range c.EnvFrom {
+			if e.ConfigMapRef == nil {
+				continue
+			}
+			data := k.configMaps[w.ns+"/"+e.ConfigMapRef.Name]
+			keys := make([]string, 0, len(data))
+			for key := range data {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				env = append(env, [2]string{key, data[key]})
+			}
+		}
+		for _, e := range c.Env {
+			v := e.Value
+			if ref := e.ValueFrom.ConfigMapKeyRef; ref != nil {
+				v = k.configMaps[w.ns+"/"+ref.Name][ref.Key]
+			}
+			env = append(env, [2]string{e.Name, v})
+		}
+		for _, kv := range env {
+			for _, h := range envHosts(kv[0], kv[1]) {
+				for _, t := range k.resolve(h, w.ns) {
+					k.g.link(w.id, t, envProtocol(kv[0], kv[1]), "env "+kv[0])
+				}
+			}
+		}
+	}
+}
+
+// resolve maps a host onto the node ids of a Service: name (same
+// namespace), name.ns, name.ns.svc[.cluster.local].
+func (k *k8sGraph) resolve(host, ns string) []string {
+	name, rest, _ := strings.Cut(host, ".")
+	if rest == "" {
+		return k.services[ns+"/"+name]
+	}
+	other, suffix, _ := strings.Cut(rest, ".")
+	if suffix == "" || suffix == "svc" || strings.HasPrefix(suffix, "svc.") {
+		if k.namespaces[other] {
+			return k.services[other+"/"+name]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,407 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+func ParseMermaid(fp string) (ParsedFile, error) {
+	b, err := os.ReadFile(fp)
+	if err != nil {
+		return ParsedFile{Name: fp}, err
+	}
+	return ParseMermaidBytes(filepath.Base(fp), b), nil
+}
+
+// ParseMermaidBytes parses Mermaid source held in memory: flowchart/graph
+// diagrams and the C4Context, C4Container, C4Component, C4Dynamic and
+// C4Deployment diagrams. A surrounding ```mermaid fence and front matter
+// are allowed.
+func ParseMermaidBytes(name string, b []byte) ParsedFile {
+	pf := ParsedFile{Name: name}
+	lines := mermaidLines(string(b))
+	if len(lines) == 0 {
+		pf.Notes = append(pf.Notes, "mermaid: empty diagram")
+		return pf
+	}
+	kind := strings.Fields(lines[0])[0]
+	switch {
+	case kind == "flowchart" || kind == "graph" || kind == "flowchart-elk":
+		parseFlowchart(&pf, lines[1:])
+	case strings.HasPrefix(kind, "C4"):
+		g := newC4Graph("mermaid", &pf)
+		for _, l := range lines[1:] {
+			g.line(l)
+		}
+		g.finish()
+	default:
+		pf.Notes = append(pf.Notes, "mermaid: "+kind+" diagrams are not supported; use flowchart or C4")
+		return pf
+	}
+	if len(pf.Nodes) == 0 {
+		pf.Notes = append(pf.Notes, "mermaid: no components recognized (check syntax)")
+	}
+	return pf
+}
+
+// looksLikeMermaid reports content whose first statement is a Mermaid
+// diagram keyword this package parses.
+func looksLikeMermaid(head []byte) bool {
+	lines := mermaidLines(string(head))
+	if len(lines) == 0 {
+		return false
+	}
+	switch kind := strings.Fields(lines[0])[0]; {
+	case kind == "flowchart", kind == "graph", kind == "flowchart-elk":
+		return true
+	case strings.HasPrefix(kind, "C4"):
+		return true
+	}
+	return false
+}
+
+// mermaidLines strips fences, front matter, %% comments and blank lines.
+func mermaidLines(src string) []string {
+	var out []string
+	inFrontMatter := false
+	for _, raw := range strings.Split(src, "\n") {
+		l := strings.TrimSpace(raw)
+		switch {
+		case strings.HasPrefix(l, "```"):
+			continue
+		case l == "---" && len(out) == 0:
+			inFrontMatter = !inFrontMatter
+			continue
+		case inFrontMatter, l == "", strings.HasPrefix(l, "%%"):
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// flowchart shapes by opening bracket, longest first. Only the cylinder
+// says anything about the component type; the rest fall back to the label.
+var flowShapes = []struct{ open, close, typ string }{
+	{"(((", ")))", ""},
+	{"((", "))", ""},
+	{"([", "])", ""},
+	{"[[", "]]", ""},
+	{"[(", ")]", "db"},
+	{"[/", "/]", ""},
+	{"[/", `\]`, ""},
+	{`[\`, `\]`, ""},
+	{`[\`, "/]", ""},
+	{"{{", "}}", ""},
+	{"(", ")", ""},
+	{"[", "]", ""},
+	{"{", "}", ""},
+	{">", "]", ""},
+}
+
+// flowShapeTypes maps the names of the @{ shape: ... } syntax.
+var flowShapeTypes = map[string]string{
+	"cyl": "db", "cylinder": "db", "database": "db", "db": "db", "das": "db",
+	"h-cyl": "db", "horizontal-cylinder": "db", "lin-cyl": "db", "disk": "db",
+}
+
+// faIcons maps Font Awesome icons used in labels ("fa:fa-user Customer").
+var faIcons = map[string]string{
+	"fa-user": "actor", "fa-users": "actor", "fa-person": "actor", "fa-user-tie": "actor",
+	"fa-database": "db", "fa-globe": "ext", "fa-cloud": "ext",
+	"fa-mobile": "client", "fa-mobile-alt": "client", "fa-desktop": "client", "fa-laptop": "client",
+}
+
+var (
+	reFaIcon   = regexp.MustCompile(`fa[bsr]?:(fa-[a-z0-9-]+)`)
+	reFlowID   = regexp.MustCompile(`^[A-Za-z0-9_$][\w$.]*(?:-[A-Za-z0-9_$][\w$.]*)*`)
+	reFlowLink = regexp.MustCompile(`^\s*([<ox]?)(-{2,}[>ox]?|-\.+-[>ox]?|={2,}[>ox]?|~{3,})\s*(?:\|([^|]*)\|)?`)
+	// reFlowTextLink is the "A -- text --> B" form.
+	reFlowTextLink = regexp.MustCompile(`^\s*([<ox]?)(--|-\.|==)\s+(.+?)\s*(-{2,}[>ox]?|\.+-[>ox]?|={2,}[>ox]?)`)
+	reSubgraph     = regexp.MustCompile(`^subgraph\s+(.+?)\s*$`)
+	reSubgraphID   = regexp.MustCompile(`^([\w$.-]+)\s*\[\s*(.*?)\s*\]$`)
+)
+
+// flowchart accumulates nodes, subgraphs and links.
+type flowchart struct {
+	pf     *ParsedFile
+	nodes  map[string]int
+	groups map[string]int
+	open   []int
+	// named and typed mark nodes whose label or shape type was given
+	// explicitly; later bare mentions do not override them.
+	named, typed map[string]bool
+	// pending is the link read after the last nodes, applied to the next.
+	pending *flowLink
+}
+
+func parseFlowchart(pf *ParsedFile, lines []string) {
+	fc := &flowchart{pf: pf, nodes: map[string]int{}, groups: map[string]int{}, named: map[string]bool{}, typed: map[string]bool{}}
+	for _, l := range lines {
+		for _, st := range splitStatements(l) {
+			fc.statement(st)
+		}
+	}
+
+	// Links to a subgraph id draw to its border; they are not dependencies.
+	kept := pf.Edges[:0]
+	for _, e := range pf.Edges {
+		_, fromNode := fc.nodes[e.From]
+		_, toNode := fc.nodes[e.To]
+		if fromNode && toNode {
+			kept = append(kept, e)
+			continue
+		}
+		pf.Notes = append(pf.Notes, "mermaid: link "+e.From+" -> "+e.To+" touches a subgraph; skipped")
+	}
+	pf.Edges = kept
+}
+
+func (fc *flowchart) statement(st string) {
+	first := strings.Fields(st)[0]
+	switch first {
+	case "end":
+		if len(fc.open) > 0 {
+			fc.open = fc.open[:len(fc.open)-1]
+		}
+		return
+	case "subgraph":
+		fc.subgraph(st)
+		return
+	case "direction", "classDef", "class", "style", "linkStyle", "click", "accTitle:", "accDescr:", "accDescr", "title":
+		return
+	}
+
+	// A chain: nodes (& nodes) (link nodes (& nodes))*
+	rest := st
+	var prev []string
+	for rest != "" {
+		var ids []string
+		for {
+			id, r, ok := fc.node(rest)
+			if !ok {
+				fc.pf.Notes = append(fc.pf.Notes, "mermaid: could not read "+strings.TrimSpace(rest))
+				return
+			}
+			ids = append(ids, id)
+			rest = strings.TrimSpace(r)
+			if !strings.HasPrefix(rest, "&") {
+				break
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+		if prev != nil {
+			fc.link(prev, ids)
+		}
+		if rest == "" {
+			return
+		}
+		l, r, ok := readFlowLink(rest)
+		if !ok {
+			fc.pf.Notes = append(fc.pf.Notes, "mermaid: could not read "+rest)
+			return
+		}
+		prev, rest = ids, strings.TrimSpace(r)
+		fc.pending = &l
+	}
+}
+
+// flowLink is a parsed arrow with its label.
+type flowLink struct {
+	label     string
+	both      bool
+	invisible bool
+}
+
+func readFlowLink(s string) (flowLink, string, bool) {
+	if m := reFlowTextLink.FindStringSubmatchIndex(s); m != nil {
+		open, close := s[m[2]:m[3]], s[m[8]:m[9]]
+		return flowLink{label: s[m[6]:m[7]], both: open == "<" && strings.HasSuffix(close, ">")}, s[m[1]:], true
+	}
+	if m := reFlowLink.FindStringSubmatchIndex(s); m != nil {
+		open, arrow := s[m[2]:m[3]], s[m[4]:m[5]]
+		l := flowLink{invisible: strings.HasPrefix(arrow, "~"), both: open == "<" && strings.HasSuffix(arrow, ">")}
+		if m[6] >= 0 {
+			l.label = s[m[6]:m[7]]
+		}
+		return l, s[m[1]:], true
+	}
+	return flowLink{}, s, false
+}
+
+func (fc *flowchart) link(from, to []string) {
+	l := fc.pending
+	fc.pending = nil
+	if l == nil || l.invisible {
+		return
+	}
+	label := flowText(l.label)
+	proto := guessProtocolFromValue(label)
+	for _, f := range from {
+		for _, t := range to {
+			fc.pf.Edges = append(fc.pf.Edges, types.Edge{From: f, To: t, Protocol: proto, Label: label})
+			if l.both {
+				fc.pf.Edges = append(fc.pf.Edges, types.Edge{From: t, To: f, Protocol: proto, Label: label})
+			}
+		}
+	}
+}
+
+// node reads "id", "id[label]", "id[(label)]", "id@{ shape: cyl }" and
+// the like, with an optional :::class suffix, and registers the node.
+func (fc *flowchart) node(s string) (id, rest string, ok bool) {
+	id = reFlowID.FindString(s)
+	if id == "" {
+		return "", s, false
+	}
+	rest = s[len(id):]
+	var label, typ string
+	shaped := false
+	if strings.HasPrefix(rest, "@{") {
+		end := strings.Index(rest, "}")
+		if end < 0 {
+			return "", s, false
+		}
+		for _, kv := range splitC4Args(rest[2:end]) {
+			k, v, _ := strings.Cut(kv, ":")
+			switch strings.TrimSpace(k) {
+			case "shape":
+				typ = flowShapeTypes[unquote(v)]
+			case "label":
+				label = unquote(v)
+			}
+		}
+		rest, shaped = rest[end+1:], true
+	} else {
+		for _, sh := range flowShapes {
+			if !strings.HasPrefix(rest, sh.open) {
+				continue
+			}
+			body := rest[len(sh.open):]
+			var end int
+			if strings.HasPrefix(body, `"`) {
+				q := strings.Index(body[1:], `"`)
+				if q < 0 || !strings.HasPrefix(body[q+2:], sh.close) {
+					continue
+				}
+				end = q + 2
+			} else if end = strings.Index(body, sh.close); end < 0 {
+				continue
+			}
+			label, typ = body[:end], sh.typ
+			rest, shaped = body[end+len(sh.close):], true
+			break
+		}
+	}
+	if strings.HasPrefix(rest, ":::") {
+		rest = rest[3:]
+		rest = rest[len(reFlowID.FindString(rest)):]
+	}
+	fc.declare(id, label, typ, shaped)
+	return id, rest, true
+}
+
+func (fc *flowchart) declare(id, rawLabel, typ string, shaped bool) {
+	if _, isGroup := fc.groups[id]; isGroup && !shaped {
+		return
+	}
+	label := flowText(rawLabel)
+	if m := reFaIcon.FindStringSubmatch(rawLabel); m != nil && typ == "" {
+		typ = faIcons[m[1]]
+	}
+	i, seen := fc.nodes[id]
+	if !seen {
+		i = len(fc.pf.Nodes)
+		fc.nodes[id] = i
+		n := types.Node{ID: id, Label: id, Source: "mermaid"}
+		if len(fc.open) > 0 {
+			gi := fc.open[len(fc.open)-1]
+			n.Group = fc.pf.Groups[gi].ID
+			fc.pf.Groups[gi].Members = append(fc.pf.Groups[gi].Members, id)
+		}
+		fc.pf.Nodes = append(fc.pf.Nodes, n)
+	}
+	n := &fc.pf.Nodes[i]
+	if label != "" && !fc.named[id] {
+		n.Label = label
+		fc.named[id] = true
+		if !fc.typed[id] {
+			n.Type = guessTypeFromLabel(label)
+		}
+	}
+	switch {
+	case typ != "":
+		n.Type = typ
+		fc.typed[id] = true
+	case n.Type == "":
+		n.Type = guessTypeFromLabel(n.Label)
+	}
+}
+
+func (fc *flowchart) subgraph(st string) {
+	m := reSubgraph.FindStringSubmatch(st)
+	if m == nil {
+		return
+	}
+	id, label := m[1], ""
+	if sm := reSubgraphID.FindStringSubmatch(id); sm != nil {
+		id, label = sm[1], flowText(sm[2])
+	} else if strings.HasPrefix(id, `"`) {
+		label = unquote(id)
+		id = slugify(label)
+	} else {
+		label = id
+	}
+	if label == "" {
+		label = id
+	}
+	gi, ok := fc.groups[id]
+	if !ok {
+		gi = len(fc.pf.Groups)
+		fc.groups[id] = gi
+		fc.pf.Groups = append(fc.pf.Groups, types.Group{ID: id, Label: label, Kind: "subgraph"})
+	}
+	fc.open = append(fc.open, gi)
+}
+
+// flowText turns a node or link label into plain text: quotes, markdown
+// backticks, Font Awesome icons and HTML are removed.
+func flowText(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.Trim(s, `"`)
+	s = strings.Trim(s, "`")
+	s = reFaIcon.ReplaceAllString(s, "")
+	return cellText(s)
+}
+
+// splitStatements splits a line on ";" outside quotes and brackets.
+func splitStatements(l string) []string {
+	var out []string
+	depth, quoted, start := 0, false, 0
+	for i, r := range l {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '[' || r == '(' || r == '{':
+			depth++
+		case r == ']' || r == ')' || r == '}':
+			if depth > 0 {
+				depth--
+			}
+		case r == ';' && depth == 0:
+			if s := strings.TrimSpace(l[start:i]); s != "" {
+				out = append(out, s)
+			}
+			start = i + 1
+		}
+	}
+	if s := strings.TrimSpace(l[start:]); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
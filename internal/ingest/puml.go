@@ -19,7 +19,16 @@ func ParsePUML(path string) (ParsedFile, error) {
 	if err != nil {
 		return ParsedFile{Name: path}, err
 	}
+	return ParsePUMLBytes(path, b), nil
+}
+
+// ParsePUMLBytes parses PlantUML source held in memory: component,
+// rectangle and node declarations with arrows, and C4-PlantUML macros
+// (Person, Container, ContainerDb, System_Boundary, Rel, ...).
+func ParsePUMLBytes(name string, b []byte) ParsedFile {
 	lines := strings.Split(string(b), "\n")
+	var c4pf ParsedFile
+	c4 := newC4Graph("puml", &c4pf)
 
 	idByLabel := map[string]string{}
 	var nodes []types.Node
@@ -40,6 +49,9 @@ func ParsePUML(path string) (ParsedFile, error) {
 		if l == "" || strings.HasPrefix(l, "'") {
 			continue
 		}
+		if c4.line(l) {
+			continue
+		}
 		if m := reComp.FindStringSubmatch(l); m != nil {
 			label := strings.TrimSpace(m[2])
 			alias := strings.TrimSpace(m[3])
@@ -86,10 +98,15 @@ func ParsePUML(path string) (ParsedFile, error) {
 		}
 	}
 
+	c4.finish()
+	nodes = append(nodes, c4pf.Nodes...)
+	edges = append(edges, c4pf.Edges...)
+	notes = append(notes, c4pf.Notes...)
+
 	if len(nodes) == 0 && len(edges) == 0 {
 		notes = append(notes, "puml: no components/links recognized (check syntax)")
 	}
-	return ParsedFile{Name: path, Nodes: nodes, Edges: edges, Notes: notes}, nil
+	return ParsedFile{Name: name, Nodes: nodes, Edges: edges, Groups: c4pf.Groups, Notes: notes}
 }
 
 func sanitizeID(s string) string {
@@ -100,7 +117,9 @@ func sanitizeID(s string) string {
 }
 
 func cleanRef(s string, ids map[string]string) string {
-	s = strings.TrimSpace(s)
+	// The link pattern's name group also matches the start of the arrow
+	// ("a --> b" captures "a --").
+	s = strings.TrimRight(strings.TrimSpace(s), "-.~ ")
 	s = strings.Trim(s, `"`)
 	if id, ok := ids[s]; ok {
 		return id
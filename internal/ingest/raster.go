@@ -6,6 +6,7 @@ package ingest
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"os/exec"
 	"path/filepath"
@@ -91,9 +92,9 @@ func protocolNorm(s string) string {
 
 func centerOf(l ocrLine) (cx, cy int) { return l.Left + l.Width/2, l.Top + l.Ht/2 }
 
-func ocrWithTesseract(fp string) (string, error) {
+func ocrWithTesseract(ctx context.Context, fp string) (string, error) {
 	try := func(psm string) (string, error) {
-		cmd := exec.Command(
+		cmd := exec.CommandContext(ctx,
 			"tesseract", fp, "stdout",
 			"--oem", "1",
 			"--psm", psm,
@@ -109,20 +110,19 @@ func ocrWithTesseract(fp string) (string, error) {
 		}
 		return out.String(), nil
 	}
-	if s, err := try("11"); err == nil && strings.TrimSpace(s) != "" {
-		return s, nil
-	}
-	if s, err := try("6"); err == nil && strings.TrimSpace(s) != "" {
-		return s, nil
-	}
-	if s, err := try("12"); err == nil && strings.TrimSpace(s) != "" {
-		return s, nil
+	for _, psm := range []string{"11", "6", "12"} {
+		if s, err := try(psm); err == nil && strings.TrimSpace(s) != "" {
+			return s, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
 	}
 	return "", nil
 }
 
-func ocrTSVLinesWithBoxes(fp string) ([]ocrLine, error) {
-	cmd := exec.Command("tesseract", fp, "stdout", "-l", "eng", "--oem", "1", "--psm", "6", "tsv")
+func ocrTSVLinesWithBoxes(ctx context.Context, fp string) ([]ocrLine, error) {
+	cmd := exec.CommandContext(ctx, "tesseract", fp, "stdout", "-l", "eng", "--oem", "1", "--psm", "6", "tsv")
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	if err := cmd.Run(); err != nil {
@@ -433,9 +433,10 @@ func closestNodeID(name string, nodes []types.Node) (string, bool) {
 	return bestID, best <= 2
 }
 
-func ParseRaster(fp string) (ParsedFile, error) {
-
-	tsv, _ := ocrTSVLinesWithBoxes(fp)
+// ParseRaster OCRs an image with tesseract. The tesseract processes are
+// killed when ctx ends, and ctx's error is returned.
+func ParseRaster(ctx context.Context, fp string) (ParsedFile, error) {
+	tsv, _ := ocrTSVLinesWithBoxes(ctx, fp)
 
 	var norm []string
 	if len(tsv) > 0 {
@@ -447,13 +448,13 @@ func ParseRaster(fp string) (ParsedFile, error) {
 			}
 			norm[i] = strings.Join(parts, " ")
 			tsv[i].Text = norm[i]
-
-			fmt.Printf("[tsv %d] %q at (%d,%d) %dx%d\n",
-				i, tsv[i].Text, ln.Left, ln.Top, ln.Width, ln.Ht)
 		}
 	}
 
-	plain, _ := ocrWithTesseract(fp)
+	plain, _ := ocrWithTesseract(ctx, fp)
+	if err := ctx.Err(); err != nil {
+		return ParsedFile{Name: filepath.Base(fp)}, err
+	}
 
 	var rawParts []string
 	if len(norm) > 0 {
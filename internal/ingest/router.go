@@ -0,0 +1,220 @@
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+func DetectType(name string) string {
+	base := strings.ToLower(filepath.Base(name))
+	if (strings.HasPrefix(base, "docker-compose") || strings.HasPrefix(base, "compose.")) &&
+		(strings.HasSuffix(base, ".yml") || strings.HasSuffix(base, ".yaml")) {
+		return "compose"
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	switch ext {
+	case ".drawio":
+		return "drawio"
+	case ".puml", ".plantuml":
+		return "puml"
+	case ".mmd", ".mermaid":
+		return "mermaid"
+	case ".dsl":
+		return "structurizr"
+	case ".dot", ".gv":
+		return "dot"
+	case ".svg":
+		return "svg"
+	case ".pdf":
+		return "pdf"
+	case ".png", ".jpg", ".jpeg":
+		return "raster"
+	case ".json":
+		return "canvas-json"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnsupported is returned by ParseBytes for types that need files on disk
+// or external tools (pdf, raster) and for unknown types.
+var ErrUnsupported = errors.New("ingest: unsupported type")
+
+// DetectContent is DetectType with a look at the content when the name does
+// not decide it (draw.io saved as .xml, PlantUML pasted as .txt, compose or
+// Kubernetes YAML under any name).
+func DetectContent(name string, data []byte) string {
+	if t := DetectType(name); t != "unknown" {
+		return t
+	}
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	head = bytes.TrimSpace(head)
+	switch {
+	case bytes.Contains(head, []byte("<mxfile")), bytes.Contains(head, []byte("<mxGraphModel")):
+		return "drawio"
+	case bytes.HasPrefix(head, []byte("@startuml")):
+		return "puml"
+	case looksLikeDOT(head):
+		// Before mermaid: an undirected DOT graph also starts with "graph".
+		return "dot"
+	case looksLikeMermaid(head):
+		return "mermaid"
+	case bytes.HasPrefix(head, []byte("workspace")):
+		return "structurizr"
+	case bytes.Contains(head, []byte("<svg")):
+		return "svg"
+	case bytes.HasPrefix(head, []byte("{")):
+		return "canvas-json"
+	}
+	if k := yamlKind(data); k != "" {
+		return k
+	}
+	return "unknown"
+}
+
+// ParseBytes parses an in-memory file of the given DetectContent type. Only
+// the text formats are supported; the rest return ErrUnsupported.
+func ParseBytes(kind, name string, data []byte) (ParsedFile, error) {
+	switch kind {
+	case "drawio":
+		return ParseDrawIOBytes(name, data), nil
+	case "puml":
+		return ParsePUMLBytes(name, data), nil
+	case "mermaid":
+		return ParseMermaidBytes(name, data), nil
+	case "structurizr":
+		return ParseStructurizrBytes(name, data), nil
+	case "dot":
+		return ParseDOTBytes(name, data), nil
+	case "compose":
+		return ParseComposeBytes(name, data)
+	case "kubernetes":
+		return ParseKubernetesBytes(name, data)
+	case "svg":
+		return ParseSVGBytes(name, data), nil
+	case "canvas-json":
+		return ParseCanvasJSONBytes(name, data)
+	}
+	return ParsedFile{Name: name}, ErrUnsupported
+}
+
+// ParseFile parses a file on disk of the given DetectType type; unlike
+// ParseBytes it also handles pdf and raster images. ctx bounds the OCR of
+// raster images.
+func ParseFile(ctx context.Context, kind, path string) (ParsedFile, error) {
+	switch kind {
+	case "drawio":
+		return ParseDrawIO(path)
+	case "puml":
+		return ParsePUML(path)
+	case "mermaid":
+		return ParseMermaid(path)
+	case "structurizr":
+		return ParseStructurizr(path)
+	case "dot":
+		return ParseDOT(path)
+	case "compose":
+		return ParseCompose(path)
+	case "kubernetes":
+		return ParseKubernetes(path)
+	case "svg":
+		return ParseSVG(path)
+	case "canvas-json":
+		return ParseCanvasJSON(path)
+	case "pdf":
+		return ParsePDF(path)
+	case "raster":
+		return ParseRaster(ctx, path)
+	}
+	return ParsedFile{Name: path}, ErrUnsupported
+}
+
+// BuildIntermediate merges parsed files into one graph. Ids are only unique
+// within a file (draw.io and canvas files all number their cells 2, 3, ...),
+// so a file whose node or group ids clash with an earlier file's has all its
+// ids prefixed with its name ("b.drawio:2").
+func BuildIntermediate(files []ParsedFile) types.IntermediateGraph {
+	ig := types.IntermediateGraph{}
+	seen := map[string]bool{}
+	prefixes := map[string]bool{}
+	for i, f := range files {
+		if clashes(f, seen) {
+			prefix := filepath.Base(f.Name) + ":"
+			if prefixes[prefix] {
+				prefix = filepath.Base(f.Name) + "#" + itoa(i+1) + ":"
+			}
+			prefixes[prefix] = true
+			f = f.prefixed(prefix)
+		}
+		for _, n := range f.Nodes {
+			seen[n.ID] = true
+		}
+		for _, g := range f.Groups {
+			seen[g.ID] = true
+		}
+		ig.Nodes = append(ig.Nodes, f.Nodes...)
+		ig.Edges = append(ig.Edges, f.Edges...)
+		ig.Groups = append(ig.Groups, f.Groups...)
+		ig.Notes = append(ig.Notes, f.Notes...)
+	}
+	return ig
+}
+
+func clashes(f ParsedFile, seen map[string]bool) bool {
+	for _, n := range f.Nodes {
+		if seen[n.ID] {
+			return true
+		}
+	}
+	for _, g := range f.Groups {
+		if seen[g.ID] {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixed returns a copy of f with every id and id reference prefixed.
+func (f ParsedFile) prefixed(prefix string) ParsedFile {
+	p := func(id string) string {
+		if id == "" {
+			return ""
+		}
+		return prefix + id
+	}
+	out := ParsedFile{Name: f.Name, Notes: f.Notes}
+	for _, n := range f.Nodes {
+		n.ID, n.Group = p(n.ID), p(n.Group)
+		out.Nodes = append(out.Nodes, n)
+	}
+	for _, e := range f.Edges {
+		e.From, e.To = p(e.From), p(e.To)
+		out.Edges = append(out.Edges, e)
+	}
+	for _, g := range f.Groups {
+		g.ID = p(g.ID)
+		members := make([]string, len(g.Members))
+		for i, m := range g.Members {
+			members[i] = p(m)
+		}
+		g.Members = members
+		out.Groups = append(out.Groups, g)
+	}
+	return out
+}
+
+type ParsedFile struct {
+	Name   string
+	Nodes  []types.Node
+	Edges  []types.Edge
+	Groups []types.Group
+	Notes  []string
+}
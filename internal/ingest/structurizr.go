@@ -0,0 +1,428 @@
+package ingest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/pkg/types"
+)
+
+func ParseStructurizr(fp string) (ParsedFile, error) {
+	b, err := os.ReadFile(fp)
+	if err != nil {
+		return ParsedFile{Name: fp}, err
+	}
+	return ParseStructurizrBytes(filepath.Base(fp), b), nil
+}
+
+// ParseStructurizrBytes parses the model of a Structurizr DSL workspace.
+// People, software systems and containers become nodes; components fold
+// into their container so relationships are read at container level.
+// Software systems that declare containers and group blocks become groups.
+// Views, styles and deployment environments are skipped.
+func ParseStructurizrBytes(name string, b []byte) ParsedFile {
+	p := &dslParser{pf: &ParsedFile{Name: name}, alias: map[string]int{}}
+	for _, l := range dslLines(string(b)) {
+		p.line(dslTokens(l))
+	}
+	p.build()
+	if len(p.pf.Nodes) == 0 {
+		p.pf.Notes = append(p.pf.Notes, "structurizr: no elements recognized in the model (check syntax)")
+	}
+	return *p.pf
+}
+
+type dslElement struct {
+	kind, name, desc, tech string
+	// path is the identifier (parent.child with !identifiers hierarchical).
+	path   string
+	tags   []string
+	parent int
+	// group is the innermost group block around the element, or -1.
+	group int
+	// parentsGroup is set when the parent block is nearer than group.
+	parentsGroup bool
+	hasChildren  bool
+}
+
+type dslRelation struct {
+	from, to, desc, tech string
+	// scope is the element whose block the relationship sits in, or -1.
+	scope int
+}
+
+// dslFrame is one open "{" block.
+type dslFrame struct {
+	elem  int // element block, or -1
+	group int // group block, or -1
+	skip  bool
+}
+
+type dslParser struct {
+	pf           *ParsedFile
+	elems        []dslElement
+	rels         []dslRelation
+	alias        map[string]int
+	frames       []dslFrame
+	hierarchical bool
+}
+
+// dslLines removes // and # line comments and /* */ blocks.
+func dslLines(src string) []string {
+	var out []string
+	inBlock := false
+	for _, raw := range strings.Split(src, "\n") {
+		l := strings.TrimSpace(raw)
+		if inBlock {
+			i := strings.Index(l, "*/")
+			if i < 0 {
+				continue
+			}
+			l, inBlock = strings.TrimSpace(l[i+2:]), false
+		}
+		if strings.HasPrefix(l, "/*") {
+			if i := strings.Index(l, "*/"); i >= 0 {
+				l = strings.TrimSpace(l[i+2:])
+			} else {
+				inBlock = true
+				continue
+			}
+		}
+		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "//") {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// dslToken is a word, a quoted string, or one of { } = ->.
+type dslToken struct {
+	text   string
+	quoted bool
+}
+
+func dslTokens(l string) []dslToken {
+	var out []dslToken
+	for i := 0; i < len(l); {
+		switch c := l[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			j := i + 1
+			var b strings.Builder
+			for j < len(l) && l[j] != '"' {
+				if l[j] == '\\' && j+1 < len(l) {
+					j++
+				}
+				b.WriteByte(l[j])
+				j++
+			}
+			out = append(out, dslToken{text: b.String(), quoted: true})
+			i = j + 1
+		case c == '{' || c == '}' || c == '=':
+			out = append(out, dslToken{text: string(c)})
+			i++
+		case strings.HasPrefix(l[i:], "->"):
+			out = append(out, dslToken{text: "->"})
+			i += 2
+		default:
+			j := i
+			for j < len(l) && !strings.ContainsRune(" \t\"{}=", rune(l[j])) && !strings.HasPrefix(l[j:], "->") {
+				j++
+			}
+			out = append(out, dslToken{text: l[i:j]})
+			i = j
+		}
+	}
+	return out
+}
+
+func (p *dslParser) line(toks []dslToken) {
+	for len(toks) > 0 && toks[0].text == "}" && !toks[0].quoted {
+		if len(p.frames) > 0 {
+			p.frames = p.frames[:len(p.frames)-1]
+		}
+		toks = toks[1:]
+	}
+	if len(toks) == 0 {
+		return
+	}
+	opens := toks[len(toks)-1].text == "{" && !toks[len(toks)-1].quoted
+	if opens {
+		toks = toks[:len(toks)-1]
+	}
+	if len(p.frames) > 0 && p.frames[len(p.frames)-1].skip {
+		if opens {
+			p.frames = append(p.frames, dslFrame{elem: -1, group: -1, skip: true})
+		}
+		return
+	}
+	frame := dslFrame{elem: -1, group: -1}
+
+	var ident string
+	if len(toks) >= 2 && toks[1].text == "=" {
+		ident, toks = toks[0].text, toks[2:]
+	}
+	if len(toks) == 0 {
+		return
+	}
+	args := func(from int) []string {
+		var out []string
+		for _, t := range toks[from:] {
+			out = append(out, t.text)
+		}
+		return out
+	}
+
+	switch kw := strings.ToLower(toks[0].text); {
+	case len(toks) > 1 && toks[1].text == "->" || kw == "->":
+		from := "this"
+		if kw != "->" {
+			from, toks = toks[0].text, toks[1:]
+		}
+		a := args(1)
+		if len(a) == 0 {
+			p.note("relationship from " + from + " has no destination")
+		} else {
+			p.rels = append(p.rels, dslRelation{from: from, to: a[0], desc: at(a, 1), tech: at(a, 2), scope: p.currentElem()})
+		}
+		frame.skip = true
+	case kw == "workspace", kw == "model":
+	case kw == "!identifiers":
+		p.hierarchical = strings.EqualFold(at(args(1), 0), "hierarchical")
+	case kw == "group", kw == "enterprise":
+		frame.group = len(p.pf.Groups)
+		label := at(args(1), 0)
+		p.pf.Groups = append(p.pf.Groups, types.Group{ID: p.groupID(label), Label: label, Kind: kw})
+	case kw == "person", kw == "softwaresystem", kw == "container", kw == "component", kw == "element":
+		frame.elem = p.element(ident, kw, args(1))
+	case kw == "tags":
+		if e := p.currentElem(); e >= 0 {
+			p.elems[e].tags = append(p.elems[e].tags, args(1)...)
+		}
+	case kw == "technology":
+		if e := p.currentElem(); e >= 0 {
+			p.elems[e].tech = at(args(1), 0)
+		}
+	case kw == "description":
+		if e := p.currentElem(); e >= 0 {
+			p.elems[e].desc = at(args(1), 0)
+		}
+	case strings.HasPrefix(kw, "!include"), kw == "!extend", kw == "!element", kw == "!ref", kw == "!script":
+		p.note(toks[0].text + " is not supported; its content is ignored")
+		frame.skip = true
+	default:
+		// views, styles, deploymentEnvironment, properties, perspectives
+		// and the like say nothing about the model's topology.
+		frame.skip = true
+	}
+	if opens {
+		p.frames = append(p.frames, frame)
+	}
+}
+
+func (p *dslParser) note(s string) {
+	p.pf.Notes = append(p.pf.Notes, "structurizr: "+s)
+}
+
+func at(a []string, i int) string {
+	if i < len(a) {
+		return a[i]
+	}
+	return ""
+}
+
+// currentElem is the innermost open element block, or -1.
+func (p *dslParser) currentElem() int {
+	for i := len(p.frames) - 1; i >= 0; i-- {
+		if p.frames[i].elem >= 0 {
+			return p.frames[i].elem
+		}
+	}
+	return -1
+}
+
+func (p *dslParser) groupID(label string) string {
+	id := "group-" + slugify(label)
+	for n, taken := 2, true; taken; n++ {
+		taken = false
+		for _, g := range p.pf.Groups {
+			if g.ID == id {
+				taken = true
+				id = "group-" + slugify(label) + "-" + itoa(n)
+				break
+			}
+		}
+	}
+	return id
+}
+
+// element records person/softwareSystem/container/component/element; the
+// argument order differs per keyword.
+func (p *dslParser) element(ident, kind string, a []string) int {
+	e := dslElement{kind: kind, name: at(a, 0), parent: -1, group: -1}
+	switch kind {
+	case "person", "softwaresystem":
+		e.desc = at(a, 1)
+		e.tags = splitTags(at(a, 2))
+	case "container", "component":
+		e.desc, e.tech = at(a, 1), at(a, 2)
+		e.tags = splitTags(at(a, 3))
+	case "element":
+		e.tech, e.desc = at(a, 1), at(a, 2)
+		e.tags = splitTags(at(a, 3))
+	}
+	for i := len(p.frames) - 1; i >= 0; i-- {
+		f := p.frames[i]
+		if f.group >= 0 && e.group < 0 && e.parent < 0 {
+			e.group = f.group
+		}
+		if f.elem >= 0 {
+			e.parent = f.elem
+			e.parentsGroup = e.group < 0
+			break
+		}
+	}
+	if e.parent >= 0 {
+		p.elems[e.parent].hasChildren = true
+	}
+
+	switch {
+	case ident == "":
+		e.path = slugify(e.name)
+	case p.hierarchical && e.parent >= 0:
+		e.path = p.elems[e.parent].path + "." + ident
+	default:
+		e.path = ident
+	}
+	idx := len(p.elems)
+	p.elems = append(p.elems, e)
+	if _, dup := p.alias[e.path]; !dup {
+		p.alias[e.path] = idx
+	}
+	return idx
+}
+
+func splitTags(s string) []string {
+	var out []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// resolve finds the element a relationship end names: "this", a full
+// identifier, or one relative to an enclosing element.
+func (p *dslParser) resolve(ref string, scope int) int {
+	if ref == "this" {
+		return scope
+	}
+	if i, ok := p.alias[ref]; ok {
+		return i
+	}
+	for s := scope; s >= 0; s = p.elems[s].parent {
+		if i, ok := p.alias[p.elems[s].path+"."+ref]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+// isGroup reports software systems drawn as boundaries around containers.
+func (p *dslParser) isGroup(i int) bool {
+	return p.elems[i].kind == "softwaresystem" && p.elems[i].hasChildren
+}
+
+// nodeOf maps an element to the node that stands for it: components fold
+// into their container. It returns -1 for software systems drawn as groups.
+func (p *dslParser) nodeOf(i int) int {
+	for p.elems[i].kind == "component" && p.elems[i].parent >= 0 {
+		i = p.elems[i].parent
+	}
+	if p.isGroup(i) {
+		return -1
+	}
+	return i
+}
+
+func (p *dslParser) build() {
+	groupOf := map[int]int{}
+	for i, e := range p.elems {
+		if p.isGroup(i) {
+			groupOf[i] = len(p.pf.Groups)
+			p.pf.Groups = append(p.pf.Groups, types.Group{ID: e.path, Label: e.name, Kind: "software_system"})
+		}
+	}
+	for i, e := range p.elems {
+		if p.nodeOf(i) != i {
+			continue
+		}
+		n := types.Node{ID: e.path, Type: e.nodeType(), Label: e.name, Source: "structurizr"}
+		gi := e.group
+		if e.parentsGroup {
+			if g, ok := groupOf[e.parent]; ok {
+				gi = g
+			}
+		}
+		if gi >= 0 {
+			n.Group = p.pf.Groups[gi].ID
+			p.pf.Groups[gi].Members = append(p.pf.Groups[gi].Members, n.ID)
+		}
+		p.pf.Nodes = append(p.pf.Nodes, n)
+	}
+
+	seen := map[string]bool{}
+	for _, r := range p.rels {
+		fi, ti := p.resolve(r.from, r.scope), p.resolve(r.to, r.scope)
+		if fi < 0 || ti < 0 {
+			p.note("relationship " + r.from + " -> " + r.to + " uses an undeclared element; skipped")
+			continue
+		}
+		fn, tn := p.nodeOf(fi), p.nodeOf(ti)
+		if fn < 0 || tn < 0 {
+			p.note("relationship " + r.from + " -> " + r.to + " points at a software system with containers; skipped")
+			continue
+		}
+		if fn == tn {
+			continue // between components of one container
+		}
+		from, to := p.elems[fn].path, p.elems[tn].path
+		proto := guessProtocolFromValue(r.tech)
+		if proto == "" {
+			proto = guessProtocolFromValue(r.desc)
+		}
+		key := from + "\x00" + to + "\x00" + proto
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		label := r.desc
+		if r.tech != "" {
+			label = strings.TrimSpace(label + " [" + r.tech + "]")
+		}
+		p.pf.Edges = append(p.pf.Edges, types.Edge{From: from, To: to, Protocol: proto, Label: label})
+	}
+}
+
+// nodeType reads the type from the keyword, then tags ("Database",
+// "External"), then technology, then the name.
+func (e dslElement) nodeType() string {
+	tags := strings.ToLower(strings.Join(e.tags, " "))
+	switch {
+	case e.kind == "person":
+		return "actor"
+	case strings.Contains(tags, "external"), strings.Contains(tags, "existing"):
+		return "ext"
+	}
+	if t := techType(tags); t != "" {
+		return t
+	}
+	if t := techType(e.tech); t != "" {
+		return t
+	}
+	return guessTypeFromLabel(e.name)
+}
@@ -34,13 +34,17 @@ func ParseSVG(fp string) (ParsedFile, error) {
 	if err != nil {
 		return ParsedFile{}, err
 	}
+	return ParseSVGBytes(filepath.Base(fp), b), nil
+}
 
+// ParseSVGBytes parses an SVG document held in memory.
+func ParseSVGBytes(name string, b []byte) ParsedFile {
 	var doc svgDoc
 	if err := xml.Unmarshal(b, &doc); err != nil {
 		return ParsedFile{
-			Name:  filepath.Base(fp),
+			Name:  name,
 			Notes: []string{"svg: unmarshal failed (unsupported structure)"},
-		}, nil
+		}
 	}
 
 	var nodes []types.Node
@@ -135,9 +139,9 @@ func ParseSVG(fp string) (ParsedFile, error) {
 	}
 
 	return ParsedFile{
-		Name:  filepath.Base(fp),
+		Name:  name,
 		Nodes: nodes,
 		Edges: edges,
 		Notes: notes,
-	}, nil
+	}
 }
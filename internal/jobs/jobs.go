@@ -0,0 +1,299 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/MalithGihan/uigp-service/internal/ingest"
+	"github.com/MalithGihan/uigp-service/internal/llm"
+	"github.com/MalithGihan/uigp-service/internal/llm/structured"
+	"github.com/MalithGihan/uigp-service/internal/store"
+)
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Pipeline stages reported while a job runs.
+const (
+	StageIngest   = "ingest"
+	StageFuse     = "fuse"
+	StageValidate = "validate"
+	StageRepair   = "repair"
+	StageDone     = "done"
+)
+
+// Fusers selectable with Deps.Fusion.
+const (
+	FusionLLM  = "llm"
+	FusionMock = "mock"
+)
+
+var (
+	ErrNotFound  = errors.New("job not found")
+	ErrNotReady  = errors.New("job not finished")
+	ErrFailed    = errors.New("job failed")
+	ErrQueueFull = errors.New("job queue is full")
+	ErrNoFiles   = errors.New("no files uploaded")
+)
+
+const (
+	jobFile  = "job.json"
+	specFile = "spec.json"
+	chatFile = "chat.txt"
+)
+
+// FileInfo describes one uploaded file and, once ingested, what was read from it.
+type FileInfo struct {
+	Name  string   `json:"name"`
+	Type  string   `json:"type"`
+	Size  int      `json:"size"`
+	Nodes int      `json:"nodes"`
+	Edges int      `json:"edges"`
+	Notes []string `json:"notes,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Job struct {
+	ID        string     `json:"id"`
+	Status    string     `json:"status"`
+	Stage     string     `json:"stage,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Files     []FileInfo `json:"files"`
+	HasChat   bool       `json:"has_chat"`
+	Generator string     `json:"generator,omitempty"`
+	// Repaired is set when the first answer failed validation and a repair
+	// attempt produced the stored spec; Fallback when no attempt validated
+	// and the spec was built deterministically instead.
+	Repaired bool                 `json:"repaired"`
+	Fallback bool                 `json:"fallback"`
+	Attempts []structured.Attempt `json:"attempts,omitempty"`
+	Error    *Error               `json:"error,omitempty"`
+}
+
+// Upload is one file of a job submission.
+type Upload struct {
+	Name string
+	Data []byte
+}
+
+type Deps struct {
+	Store *store.FS
+
+	Workers   int
+	QueueSize int
+	// Timeout bounds one job's whole pipeline run.
+	Timeout time.Duration
+
+	// Fusion is llm (default) or mock. The llm fuser calls LLM, holding a
+	// Limiter slot per call when one is set, for up to MaxAttempts
+	// validate-and-repair rounds.
+	Fusion      string
+	LLM         llm.Client
+	Limiter     *llm.Limiter
+	MaxAttempts int
+}
+
+// Manager accepts jobs, persists them under Store and runs them on a fixed
+// pool of workers. Job state lives on disk, so status and specs survive a
+// restart; jobs cut off by one are reported as failed.
+type Manager struct {
+	store       *store.FS
+	timeout     time.Duration
+	fusion      string
+	llm         llm.Client
+	maxAttempts int
+
+	queue  chan string
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+func NewManager(d Deps) *Manager {
+	workers := d.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	size := d.QueueSize
+	if size <= 0 {
+		size = 32
+	}
+	timeout := d.Timeout
+	if timeout <= 0 {
+		timeout = 300 * time.Second
+	}
+	fusion := d.Fusion
+	if fusion == "" {
+		fusion = FusionLLM
+	}
+	client := d.LLM
+	if client != nil && d.Limiter != nil {
+		client = d.Limiter.Wrap(client)
+	}
+	attempts := d.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		store:       d.Store,
+		timeout:     timeout,
+		fusion:      fusion,
+		llm:         client,
+		maxAttempts: attempts,
+		queue:       make(chan string, size),
+		ctx:         ctx,
+		cancel:      cancel,
+		active:      map[string]bool{},
+	}
+	for i := 0; i < workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+	return m
+}
+
+// Close stops the workers, cancelling running jobs, and waits for them.
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Submit stores the uploads and optional chat text and queues the job.
+func (m *Manager) Submit(uploads []Upload, chat string) (Job, error) {
+	if len(uploads) == 0 {
+		return Job{}, ErrNoFiles
+	}
+	id := newID()
+	if _, err := m.store.MkJob(id); err != nil {
+		return Job{}, err
+	}
+	now := time.Now().UTC()
+	job := Job{ID: id, Status: StatusQueued, CreatedAt: now, UpdatedAt: now, HasChat: chat != ""}
+	for _, u := range uploads {
+		p, err := m.store.SaveUpload(id, u.Name, u.Data)
+		if err != nil {
+			m.discard(id)
+			return Job{}, err
+		}
+		job.Files = append(job.Files, FileInfo{
+			Name: filepath.Base(p),
+			Type: ingest.DetectContent(u.Name, u.Data),
+			Size: len(u.Data),
+		})
+	}
+	if chat != "" {
+		if err := m.store.WriteFile(id, chatFile, []byte(chat)); err != nil {
+			m.discard(id)
+			return Job{}, err
+		}
+	}
+	if err := m.store.WriteJSON(id, jobFile, job); err != nil {
+		m.discard(id)
+		return Job{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case m.queue <- id:
+		m.active[id] = true
+		return job, nil
+	default:
+		m.discard(id)
+		return Job{}, ErrQueueFull
+	}
+}
+
+func (m *Manager) Get(id string) (Job, error) {
+	if !validID.MatchString(id) {
+		return Job{}, ErrNotFound
+	}
+	var job Job
+	if err := m.store.ReadJSON(id, jobFile, &job); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return Job{}, ErrNotFound
+		}
+		return Job{}, err
+	}
+	if job.Status == StatusQueued || job.Status == StatusRunning {
+		m.mu.Lock()
+		live := m.active[id]
+		m.mu.Unlock()
+		if !live {
+			job.Status = StatusFailed
+			job.Error = &Error{Code: "interrupted", Message: "the service restarted before the job finished"}
+		}
+	}
+	return job, nil
+}
+
+// Spec returns the validated spec of a succeeded job, ErrNotReady while it
+// is queued or running and ErrFailed once it has failed.
+func (m *Manager) Spec(id string) (Job, map[string]any, error) {
+	job, err := m.Get(id)
+	if err != nil {
+		return Job{}, nil, err
+	}
+	switch job.Status {
+	case StatusSucceeded:
+	case StatusFailed:
+		return job, nil, ErrFailed
+	default:
+		return job, nil, ErrNotReady
+	}
+	var spec map[string]any
+	if err := m.store.ReadJSON(id, specFile, &spec); err != nil {
+		return job, nil, err
+	}
+	return job, spec, nil
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case id := <-m.queue:
+			m.run(id)
+			m.mu.Lock()
+			delete(m.active, id)
+			m.mu.Unlock()
+		}
+	}
+}
+
+func (m *Manager) discard(id string) {
+	_ = os.RemoveAll(m.store.JobDir(id))
+}
+
+var validID = regexp.MustCompile(`^job_[a-f0-9]{24}$`)
+
+func newID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "job_" + hex.EncodeToString(b[:])
+}
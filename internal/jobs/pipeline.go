@@ -0,0 +1,128 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"time"
+
+	"github.com/MalithGihan/uigp-service/internal/fusion"
+	"github.com/MalithGihan/uigp-service/internal/ingest"
+	"github.com/MalithGihan/uigp-service/internal/validate"
+)
+
+// run takes a queued job through ingest -> fuse -> sanitize -> validate;
+// fusion.Fuse re-prompts with the validation errors up to maxAttempts times.
+func (m *Manager) run(id string) {
+	var job Job
+	if err := m.store.ReadJSON(id, jobFile, &job); err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, m.timeout)
+	defer cancel()
+
+	job.Status = StatusRunning
+	m.stage(&job, StageIngest)
+
+	dir := m.store.JobDir(id)
+	var parsed []ingest.ParsedFile
+	for i := range job.Files {
+		f := &job.Files[i]
+		pf, err := ingest.ParseFile(ctx, f.Type, filepath.Join(dir, "uploads", f.Name))
+		f.Nodes, f.Edges, f.Notes = len(pf.Nodes), len(pf.Edges), pf.Notes
+		switch {
+		case errors.Is(err, ingest.ErrUnsupported):
+			f.Error = "unsupported file type"
+		case err != nil:
+			f.Error = err.Error()
+		default:
+			parsed = append(parsed, pf)
+		}
+	}
+	if ctx.Err() != nil {
+		// OCR was cut short by the job timeout or shutdown.
+		m.fail(&job, m.errCode(ctx, "interrupted"), "ingest did not finish: "+ctx.Err().Error())
+		return
+	}
+	ig := ingest.BuildIntermediate(parsed)
+	if len(ig.Nodes) == 0 && !job.HasChat {
+		m.fail(&job, "no_components", "no components were recognized in the uploaded files")
+		return
+	}
+
+	m.stage(&job, StageFuse)
+	var spec map[string]any
+	var verr error
+	switch m.fusion {
+	case FusionMock:
+		spec = fusion.Normalize(fusion.MockFromIntermediate(ig), &ig, dir)
+		m.stage(&job, StageValidate)
+		verr = validate.ValidateMap(spec)
+	default:
+		res, err := fusion.Fuse(ctx, m.llm, ig, fusion.LoadChat(dir), fusion.Options{
+			MaxAttempts: m.maxAttempts,
+			JobDir:      dir,
+			OnAttempt: func(n int) {
+				if n > 1 {
+					m.stage(&job, StageRepair)
+				}
+			},
+		})
+		job.Attempts = res.Attempts
+		if err != nil {
+			m.fail(&job, m.errCode(ctx, "fusion_failed"), err.Error())
+			return
+		}
+		spec = res.Spec
+		job.Fallback = res.Fallback
+		job.Repaired = res.Valid && !res.Fallback && len(res.Attempts) > 1
+		if !res.Valid {
+			verr = validate.ValidateMap(spec)
+		}
+	}
+	job.Generator = m.fusion
+	if job.Fallback {
+		job.Generator = FusionMock
+	}
+	if verr != nil {
+		// Keep the rejected spec next to the job for debugging.
+		_ = m.store.WriteJSON(id, "spec.invalid.json", spec)
+		m.fail(&job, "spec_invalid", verr.Error())
+		return
+	}
+
+	if err := m.store.WriteJSON(id, specFile, spec); err != nil {
+		m.fail(&job, "internal_error", err.Error())
+		return
+	}
+	job.Status = StatusSucceeded
+	m.stage(&job, StageDone)
+}
+
+func (m *Manager) stage(job *Job, stage string) {
+	job.Stage = stage
+	m.save(job)
+}
+
+// errCode maps an LLM call failure to timeout or interrupted when the job's
+// context, not the model, ended it.
+func (m *Manager) errCode(ctx context.Context, code string) string {
+	switch {
+	case m.ctx.Err() != nil:
+		return "interrupted"
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return "timeout"
+	}
+	return code
+}
+
+func (m *Manager) fail(job *Job, code, msg string) {
+	job.Status = StatusFailed
+	job.Error = &Error{Code: code, Message: msg}
+	m.save(job)
+}
+
+func (m *Manager) save(job *Job) {
+	job.UpdatedAt = time.Now().UTC()
+	_ = m.store.WriteJSON(job.ID, jobFile, job)
+}
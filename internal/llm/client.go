@@ -15,9 +15,31 @@ type ChatRequest struct {
 	Options  map[string]any `json:"options,omitempty"`
 }
 
+// StreamFunc receives answer content as it is produced by the provider.
+// Returning an error aborts the stream and is returned from ChatStream.
+type StreamFunc func(delta string) error
+
 type Client interface {
 	Provider() string
 	Model() string
 	Ping(ctx context.Context) error
 	Chat(ctx context.Context, req ChatRequest) (string, error)
+	// ChatStream behaves like Chat but forwards content deltas to fn as they arrive.
+	// It returns the full concatenated answer.
+	ChatStream(ctx context.Context, req ChatRequest, fn StreamFunc) (string, error)
+}
+
+// BufferedChatStream implements ChatStream for providers without native streaming:
+// it waits for the complete answer and delivers it as a single delta.
+func BufferedChatStream(ctx context.Context, c Client, req ChatRequest, fn StreamFunc) (string, error) {
+	answer, err := c.Chat(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if fn != nil && answer != "" {
+		if err := fn(answer); err != nil {
+			return answer, err
+		}
+	}
+	return answer, nil
 }
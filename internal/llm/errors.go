@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrorKind classifies upstream LLM failures so callers can decide on retries
+// and HTTP status codes without inspecting error text.
+type ErrorKind string
+
+const (
+	KindTimeout           ErrorKind = "timeout"
+	KindCanceled          ErrorKind = "canceled"
+	KindConnectionRefused ErrorKind = "connection_refused"
+	KindUnavailable       ErrorKind = "unavailable"
+	KindModelNotFound     ErrorKind = "model_not_found"
+	KindContextLength     ErrorKind = "context_length_exceeded"
+	KindRateLimited       ErrorKind = "rate_limited"
+	KindBadResponse       ErrorKind = "bad_upstream_response"
+	KindUnknown           ErrorKind = "unknown"
+)
+
+// Error is the typed error returned by llm.Client implementations.
+type Error struct {
+	Kind       ErrorKind
+	Provider   string
+	StatusCode int
+	// RetryAfter is the upstream's Retry-After hint, if any.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("%s %s (status=%d): %v", e.Provider, e.Kind, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %v", e.Provider, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// KindOf returns the kind of a typed error anywhere in err's chain, classifying
+// untyped transport/context errors on the fly.
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	var le *Error
+	if errors.As(err, &le) {
+		return le.Kind
+	}
+	return classifyTransport(err)
+}
+
+// TransportError wraps an error returned by http.Client.Do or while reading the body.
+func TransportError(provider string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var le *Error
+	if errors.As(err, &le) {
+		return err
+	}
+	return &Error{Kind: classifyTransport(err), Provider: provider, Err: err}
+}
+
+// DecodeError wraps a failure to parse the upstream response body.
+func DecodeError(provider string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if k := classifyTransport(err); k == KindTimeout || k == KindCanceled {
+		return &Error{Kind: k, Provider: provider, Err: err}
+	}
+	return &Error{Kind: KindBadResponse, Provider: provider, Err: fmt.Errorf("decode response: %w", err)}
+}
+
+// StatusError classifies a non-2xx upstream response.
+func StatusError(provider string, resp *http.Response, body []byte) error {
+	text := strings.TrimSpace(string(body))
+	e := &Error{
+		Kind:       classifyStatus(resp.StatusCode, text),
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Err:        fmt.Errorf("%s", text),
+	}
+	if text == "" {
+		e.Err = errors.New(http.StatusText(resp.StatusCode))
+	}
+	if ra := resp.Header.Get("Retry-After"); ra != "" {
+		if secs, err := strconv.Atoi(strings.TrimSpace(ra)); err == nil && secs > 0 {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+	}
+	return e
+}
+
+// StreamError classifies an error reported inside a streamed response,
+// which has no status code of its own: the text is read like a status body,
+// and an error it does not recognize is a bad upstream response.
+func StreamError(provider, text string) error {
+	kind := classifyStatus(0, text)
+	if kind == KindUnknown {
+		kind = KindBadResponse
+	}
+	return &Error{Kind: kind, Provider: provider, Err: errors.New(text)}
+}
+
+func classifyStatus(status int, body string) ErrorKind {
+	b := strings.ToLower(body)
+	switch {
+	case status == http.StatusTooManyRequests:
+		return KindRateLimited
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return KindTimeout
+	case strings.Contains(b, "context length") || strings.Contains(b, "context_length") ||
+		strings.Contains(b, "maximum context") || strings.Contains(b, "too many tokens"):
+		return KindContextLength
+	case strings.Contains(b, "rate limit") || strings.Contains(b, "rate_limit") || strings.Contains(b, "too many requests"):
+		return KindRateLimited
+	case status == http.StatusNotFound && (strings.Contains(b, "model") || strings.Contains(b, "not found")):
+		return KindModelNotFound
+	case strings.Contains(b, "model") && strings.Contains(b, "not found"):
+		return KindModelNotFound
+	case status == http.StatusServiceUnavailable:
+		return KindUnavailable
+	case status >= 500:
+		return KindBadResponse
+	default:
+		return KindUnknown
+	}
+}
+
+func classifyTransport(err error) ErrorKind {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return KindCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return KindTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return KindConnectionRefused
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return KindTimeout
+	}
+	var oe *net.OpError
+	if errors.As(err, &oe) && oe.Op == "dial" {
+		return KindConnectionRefused
+	}
+	var de *net.DNSError
+	if errors.As(err, &de) {
+		return KindConnectionRefused
+	}
+	return KindUnknown
+}
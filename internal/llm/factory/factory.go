@@ -2,15 +2,69 @@ package factory
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/MalithGihan/uigp-service/internal/config"
 	"github.com/MalithGihan/uigp-service/internal/llm"
 	"github.com/MalithGihan/uigp-service/internal/llm/fake"
+	"github.com/MalithGihan/uigp-service/internal/llm/fallback"
 	"github.com/MalithGihan/uigp-service/internal/llm/ollama"
+	"github.com/MalithGihan/uigp-service/internal/llm/openai"
+	"github.com/MalithGihan/uigp-service/internal/llm/retry"
 )
 
+// NewClientFromConfig builds the configured provider wrapped in the retry policy.
+// LLM_PROVIDER may list several providers in priority order (e.g. "ollama,openai");
+// they are then wrapped in a fallback chain with a circuit breaker per member.
 func NewClientFromConfig(cfg config.Config) (llm.Client, error) {
-	switch cfg.LLMProvider {
+	names := strings.Split(cfg.LLMProvider, ",")
+	if len(names) == 1 {
+		c, err := newProvider(strings.TrimSpace(names[0]), cfg)
+		if err != nil {
+			return nil, err
+		}
+		return withRetry(c, cfg), nil
+	}
+
+	var members []llm.Client
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		c, err := newProvider(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, withRetry(c, cfg))
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("unsupported LLM_PROVIDER: %s", cfg.LLMProvider)
+	}
+	if len(members) == 1 {
+		return members[0], nil
+	}
+	return fallback.New(members, fallback.Config{
+		FailureThreshold: cfg.LLMBreakerFailures,
+		Cooldown:         cfg.LLMBreakerCooldown,
+	}), nil
+}
+
+func withRetry(c llm.Client, cfg config.Config) llm.Client {
+	var on []llm.ErrorKind
+	for _, k := range cfg.LLMRetryOn {
+		on = append(on, llm.ErrorKind(strings.ToLower(k)))
+	}
+	return retry.Wrap(c, retry.Policy{
+		MaxAttempts: cfg.LLMRetryMaxAttempts,
+		BaseDelay:   cfg.LLMRetryBaseDelay,
+		MaxDelay:    cfg.LLMRetryMaxDelay,
+		RetryOn:     on,
+	})
+}
+
+func newProvider(name string, cfg config.Config) (llm.Client, error) {
+	switch name {
 	case "fake":
 		return fake.New(), nil
 	case "", "ollama":
@@ -19,7 +73,14 @@ func NewClientFromConfig(cfg config.Config) (llm.Client, error) {
 			DefaultModel: cfg.OllamaModel,
 			Timeout:      cfg.OllamaTimeout,
 		}), nil
+	case "openai":
+		return openai.NewClient(openai.Config{
+			BaseURL:      cfg.OpenAIBaseURL,
+			APIKey:       cfg.OpenAIAPIKey,
+			DefaultModel: cfg.OpenAIModel,
+			Timeout:      cfg.OpenAITimeout,
+		}), nil
 	default:
-		return nil, fmt.Errorf("unsupported LLM_PROVIDER: %s", cfg.LLMProvider)
+		return nil, fmt.Errorf("unsupported LLM_PROVIDER: %s", name)
 	}
 }
@@ -2,6 +2,7 @@ package fake
 
 import (
 	"context"
+	"encoding/json"
 	"strings"
 
 	"github.com/MalithGihan/uigp-service/internal/llm"
@@ -28,8 +29,26 @@ func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (string, error)
 			break
 		}
 	}
+	if req.Format != nil {
+		return jsonAnswer(last), nil
+	}
 	if last == "" {
 		return "fake: empty", nil
 	}
 	return "fake: " + last, nil
 }
+
+// jsonAnswer keeps JSON mode parseable: a JSON object prompt is echoed back,
+// anything else is wrapped as {"answer": "fake: ..."}.
+func jsonAnswer(last string) string {
+	var obj map[string]any
+	if json.Unmarshal([]byte(last), &obj) == nil && obj != nil {
+		return last
+	}
+	b, _ := json.Marshal(map[string]string{"answer": "fake: " + last})
+	return string(b)
+}
+
+func (c *Client) ChatStream(ctx context.Context, req llm.ChatRequest, fn llm.StreamFunc) (string, error) {
+	return llm.BufferedChatStream(ctx, c, req, fn)
+}
@@ -0,0 +1,262 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MalithGihan/uigp-service/internal/llm"
+)
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half_open"
+)
+
+type Config struct {
+	// FailureThreshold is the number of consecutive failures that opens a member's breaker.
+	FailureThreshold int
+	// Cooldown is how long an open breaker waits before probing the member with Ping.
+	Cooldown time.Duration
+	// ProbeTimeout bounds the half-open Ping probe.
+	ProbeTimeout time.Duration
+}
+
+// Client tries its members in priority order, skipping members whose circuit
+// breaker is open, and reports the member that answered via llm.ServedBy.
+type Client struct {
+	members []*member
+	cfg     Config
+}
+
+type member struct {
+	llm.Client
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+	lastErr  string
+}
+
+func New(clients []llm.Client, cfg Config) *Client {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 3
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 30 * time.Second
+	}
+	if cfg.ProbeTimeout <= 0 {
+		cfg.ProbeTimeout = 3 * time.Second
+	}
+	c := &Client{cfg: cfg}
+	for _, m := range clients {
+		c.members = append(c.members, &member{Client: m, state: StateClosed})
+	}
+	return c
+}
+
+func (c *Client) Provider() string { return "fallback" }
+
+func (c *Client) Model() string {
+	models := make([]string, 0, len(c.members))
+	for _, m := range c.members {
+		models = append(models, m.Provider()+":"+m.Model())
+	}
+	return strings.Join(models, ",")
+}
+
+// Ping succeeds when at least one member is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	var errs []string
+	for _, m := range c.members {
+		err := m.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, m.Provider()+": "+err.Error())
+	}
+	return fmt.Errorf("fallback: no provider reachable: %s", strings.Join(errs, "; "))
+}
+
+func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (string, error) {
+	return c.run(ctx, req, func(m *member, req llm.ChatRequest) (string, bool, error) {
+		answer, err := m.Chat(ctx, req)
+		return answer, false, err
+	})
+}
+
+// ChatStream falls back only while the failing member has not emitted any delta;
+// once content reached the caller, switching providers would corrupt the answer.
+func (c *Client) ChatStream(ctx context.Context, req llm.ChatRequest, fn llm.StreamFunc) (string, error) {
+	return c.run(ctx, req, func(m *member, req llm.ChatRequest) (string, bool, error) {
+		emitted := false
+		answer, err := m.ChatStream(ctx, req, func(delta string) error {
+			emitted = true
+			if fn == nil {
+				return nil
+			}
+			return fn(delta)
+		})
+		return answer, emitted, err
+	})
+}
+
+func (c *Client) run(ctx context.Context, req llm.ChatRequest, call func(*member, llm.ChatRequest) (string, bool, error)) (string, error) {
+	sb := llm.ServedByFrom(ctx)
+	// The chain has no single model; each member uses its own configured model.
+	req.Model = ""
+
+	var errs []string
+	var lastErr error
+	for _, m := range c.members {
+		if !m.allow(ctx, c.cfg) {
+			errs = append(errs, m.Provider()+": circuit open")
+			if sb != nil {
+				sb.Attempts = append(sb.Attempts, llm.Attempt{Provider: m.Provider(), Model: m.Model(), Error: "circuit open", Skipped: true})
+			}
+			continue
+		}
+
+		answer, emitted, err := call(m, req)
+		if err == nil {
+			m.record(nil, c.cfg)
+			if sb != nil {
+				sb.Provider = m.Provider()
+				sb.Model = m.Model()
+				sb.Attempts = append(sb.Attempts, llm.Attempt{Provider: m.Provider(), Model: m.Model()})
+			}
+			return answer, nil
+		}
+
+		// The caller went away: not the member's fault and no point trying others.
+		if ctx.Err() != nil {
+			m.release()
+			return answer, err
+		}
+
+		m.record(err, c.cfg)
+		if sb != nil {
+			sb.Attempts = append(sb.Attempts, llm.Attempt{Provider: m.Provider(), Model: m.Model(), Error: err.Error()})
+		}
+		if emitted {
+			return answer, err
+		}
+		errs = append(errs, m.Provider()+": "+err.Error())
+		lastErr = err
+	}
+	if len(errs) == 0 {
+		return "", errors.New("fallback: no providers configured")
+	}
+	if lastErr == nil {
+		// Every member was skipped by its breaker.
+		return "", &llm.Error{Kind: llm.KindUnavailable, Provider: c.Provider(), Err: errors.New(strings.Join(errs, "; "))}
+	}
+	// Keep the last member's typed error in the chain for classification.
+	return "", fmt.Errorf("fallback: all providers failed [%s]: last error: %w", strings.Join(errs, "; "), lastErr)
+}
+
+// Members reports each member's breaker state. Members with a closed or
+// half-open breaker are pinged concurrently; open members are reported as not ready.
+func (c *Client) Members(ctx context.Context) []llm.MemberStatus {
+	out := make([]llm.MemberStatus, len(c.members))
+	var wg sync.WaitGroup
+	for i, m := range c.members {
+		m.mu.Lock()
+		out[i] = llm.MemberStatus{
+			Provider: m.Provider(),
+			Model:    m.Model(),
+			Breaker:  m.state,
+			Failures: m.failures,
+			Error:    m.lastErr,
+		}
+		open := m.state == StateOpen
+		m.mu.Unlock()
+		if open {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, m *member) {
+			defer wg.Done()
+			if err := m.Ping(ctx); err != nil {
+				out[i].Error = err.Error()
+				return
+			}
+			out[i].OK = true
+		}(i, m)
+	}
+	wg.Wait()
+	return out
+}
+
+// allow reports whether the member may serve a request. An open breaker past its
+// cooldown is probed with Ping; a successful probe moves it to half-open, letting
+// exactly one trial request through.
+func (m *member) allow(ctx context.Context, cfg Config) bool {
+	m.mu.Lock()
+	switch m.state {
+	case StateClosed:
+		m.mu.Unlock()
+		return true
+	case StateHalfOpen:
+		if m.probing {
+			m.mu.Unlock()
+			return false
+		}
+		m.probing = true
+		m.mu.Unlock()
+		return true
+	}
+	if m.probing || time.Since(m.openedAt) < cfg.Cooldown {
+		m.mu.Unlock()
+		return false
+	}
+	m.probing = true
+	m.mu.Unlock()
+
+	pctx, cancel := context.WithTimeout(ctx, cfg.ProbeTimeout)
+	err := m.Ping(pctx)
+	cancel()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.openedAt = time.Now()
+		m.probing = false
+		m.lastErr = "probe failed: " + err.Error()
+		return false
+	}
+	// Keep probing=true: the caller's request is the half-open trial.
+	m.state = StateHalfOpen
+	return true
+}
+
+func (m *member) record(err error, cfg Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.probing = false
+	if err == nil {
+		m.state = StateClosed
+		m.failures = 0
+		m.lastErr = ""
+		return
+	}
+	m.failures++
+	m.lastErr = err.Error()
+	if m.state == StateHalfOpen || m.failures >= cfg.FailureThreshold {
+		m.state = StateOpen
+		m.openedAt = time.Now()
+	}
+}
+
+// release ends a half-open trial without counting it as success or failure.
+func (m *member) release() {
+	m.mu.Lock()
+	m.probing = false
+	m.mu.Unlock()
+}
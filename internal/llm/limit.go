@@ -0,0 +1,54 @@
+package llm
+
+import "context"
+
+// Limiter caps the number of concurrent LLM calls across every caller that
+// shares it (chat requests, history summaries, jobs).
+type Limiter struct {
+	sem chan struct{}
+}
+
+func NewLimiter(n int) *Limiter {
+	if n <= 0 {
+		n = 1
+	}
+	return &Limiter{sem: make(chan struct{}, n)}
+}
+
+// Acquire blocks until a slot is free or ctx is done; call Release after a
+// nil return.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Limiter) Release() { <-l.sem }
+
+// Wrap returns c with every Chat and ChatStream call holding a slot, for
+// callers that make several calls per unit of work.
+func (l *Limiter) Wrap(c Client) Client { return &limitedClient{Client: c, l: l} }
+
+type limitedClient struct {
+	Client
+	l *Limiter
+}
+
+func (c *limitedClient) Chat(ctx context.Context, req ChatRequest) (string, error) {
+	if err := c.l.Acquire(ctx); err != nil {
+		return "", err
+	}
+	defer c.l.Release()
+	return c.Client.Chat(ctx, req)
+}
+
+func (c *limitedClient) ChatStream(ctx context.Context, req ChatRequest, fn StreamFunc) (string, error) {
+	if err := c.l.Acquire(ctx); err != nil {
+		return "", err
+	}
+	defer c.l.Release()
+	return c.Client.ChatStream(ctx, req, fn)
+}
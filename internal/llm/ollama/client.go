@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/MalithGihan/uigp-service/internal/llm"
@@ -51,7 +52,7 @@ func (c *Client) Ping(ctx context.Context) error {
 	req, _ := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/api/tags", nil)
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return err
+		return llm.TransportError("ollama", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 500 {
@@ -61,6 +62,66 @@ func (c *Client) Ping(ctx context.Context) error {
 }
 
 func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (string, error) {
+	resp, err := c.postChat(ctx, req, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var raw chatChunk
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return "", llm.DecodeError("ollama", err)
+	}
+
+	return raw.Message.Content, nil
+}
+
+// ChatStream reads Ollama's NDJSON stream and forwards each message delta to fn.
+func (c *Client) ChatStream(ctx context.Context, req llm.ChatRequest, fn llm.StreamFunc) (string, error) {
+	resp, err := c.postChat(ctx, req, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var chunk chatChunk
+		if err := dec.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return full.String(), llm.DecodeError("ollama", err)
+		}
+		if chunk.Error != "" {
+			return full.String(), llm.StreamError("ollama", chunk.Error)
+		}
+		if d := chunk.Message.Content; d != "" {
+			full.WriteString(d)
+			if fn != nil {
+				if err := fn(d); err != nil {
+					return full.String(), err
+				}
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
+	return full.String(), nil
+}
+
+type chatChunk struct {
+	Message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"message"`
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+}
+
+func (c *Client) postChat(ctx context.Context, req llm.ChatRequest, stream bool) (*http.Response, error) {
 	model := req.Model
 	if model == "" {
 		model = c.model
@@ -69,7 +130,7 @@ func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (string, error)
 	payload := map[string]any{
 		"model":    model,
 		"messages": req.Messages,
-		"stream":   false,
+		"stream":   stream,
 	}
 	if req.Options != nil {
 		payload["options"] = req.Options
@@ -85,25 +146,13 @@ func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (string, error)
 
 	resp, err := c.http.Do(httpReq)
 	if err != nil {
-		return "", err
+		return nil, llm.TransportError("ollama", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("ollama chat error: status=%d body=%s", resp.StatusCode, string(body))
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		return nil, llm.StatusError("ollama", resp, body)
 	}
-
-	var raw struct {
-		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
-		Done bool `json:"done"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
-		return "", err
-	}
-
-	return raw.Message.Content, nil
+	return resp, nil
 }
@@ -0,0 +1,245 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MalithGihan/uigp-service/internal/llm"
+)
+
+// Config targets any server exposing the OpenAI chat completions API
+// (OpenAI, vLLM, llama.cpp server, LM Studio, hosted gateways).
+type Config struct {
+	BaseURL      string
+	APIKey       string
+	DefaultModel string
+	Timeout      time.Duration
+}
+
+type Client struct {
+	baseURL string
+	apiKey  string
+	model   string
+	http    *http.Client
+}
+
+func NewClient(cfg Config) *Client {
+	base := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if base == "" {
+		base = "https://api.openai.com"
+	}
+	// Accept both "http://host:8000" and "http://host:8000/v1".
+	base = strings.TrimSuffix(base, "/v1")
+	to := cfg.Timeout
+	if to <= 0 {
+		to = 180 * time.Second
+	}
+	model := cfg.DefaultModel
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &Client{
+		baseURL: base,
+		apiKey:  cfg.APIKey,
+		model:   model,
+		http:    &http.Client{Timeout: to},
+	}
+}
+
+func (c *Client) Provider() string { return "openai" }
+func (c *Client) Model() string    { return c.model }
+
+func (c *Client) Ping(ctx context.Context) error {
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/models", nil)
+	c.authorize(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return llm.TransportError("openai", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("openai unhealthy: status=%d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (string, error) {
+	resp, err := c.postCompletions(ctx, req, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var raw struct {
+		Choices []struct {
+			Message llm.Message `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return "", llm.DecodeError("openai", err)
+	}
+	if len(raw.Choices) == 0 {
+		return "", &llm.Error{Kind: llm.KindBadResponse, Provider: "openai", Err: errors.New("response has no choices")}
+	}
+	return raw.Choices[0].Message.Content, nil
+}
+
+// ChatStream reads the "data: {...}" server-sent events of a streamed completion
+// and forwards each content delta to fn.
+func (c *Client) ChatStream(ctx context.Context, req llm.ChatRequest, fn llm.StreamFunc) (string, error) {
+	resp, err := c.postCompletions(ctx, req, true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var full strings.Builder
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Error *struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+				Code    any    `json:"code"`
+			} `json:"error,omitempty"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return full.String(), llm.DecodeError("openai", err)
+		}
+		if chunk.Error != nil {
+			// type and code ("rate_limit_exceeded", "context_length_exceeded")
+			// classify the error when the message does not.
+			text := chunk.Error.Message
+			for _, tag := range []any{chunk.Error.Code, chunk.Error.Type} {
+				if t, ok := tag.(string); ok && t != "" && !strings.Contains(text, t) {
+					text += " (" + t + ")"
+				}
+			}
+			return full.String(), llm.StreamError("openai", text)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if d := chunk.Choices[0].Delta.Content; d != "" {
+			full.WriteString(d)
+			if fn != nil {
+				if err := fn(d); err != nil {
+					return full.String(), err
+				}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return full.String(), llm.TransportError("openai", err)
+	}
+	return full.String(), nil
+}
+
+func (c *Client) postCompletions(ctx context.Context, req llm.ChatRequest, stream bool) (*http.Response, error) {
+	model := req.Model
+	if model == "" {
+		model = c.model
+	}
+
+	payload := map[string]any{
+		"model":    model,
+		"messages": req.Messages,
+		"stream":   stream,
+	}
+	for k, v := range translateOptions(req.Options) {
+		payload[k] = v
+	}
+	if rf := responseFormat(req.Format); rf != nil {
+		payload["response_format"] = rf
+	}
+
+	b, _ := json.Marshal(payload)
+
+	httpReq, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/chat/completions", bytes.NewReader(b))
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	c.authorize(httpReq)
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, llm.TransportError("openai", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		return nil, llm.StatusError("openai", resp, body)
+	}
+	return resp, nil
+}
+
+func (c *Client) authorize(r *http.Request) {
+	if c.apiKey != "" {
+		r.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
+
+// translateOptions maps Ollama-style options onto chat completions fields.
+// num_ctx has no request-level equivalent (the server decides the context size) and is dropped.
+func translateOptions(opts map[string]any) map[string]any {
+	out := map[string]any{}
+	for k, v := range opts {
+		switch k {
+		case "temperature", "top_p", "seed", "stop", "presence_penalty", "frequency_penalty":
+			out[k] = v
+		case "num_predict":
+			out["max_tokens"] = v
+		}
+	}
+	return out
+}
+
+// responseFormat maps llm.ChatRequest.Format onto response_format:
+// "json" selects JSON mode, a JSON schema object selects structured outputs.
+func responseFormat(format any) map[string]any {
+	switch f := format.(type) {
+	case nil:
+		return nil
+	case string:
+		if strings.EqualFold(strings.TrimSpace(f), "json") {
+			return map[string]any{"type": "json_object"}
+		}
+		return nil
+	case map[string]any:
+		if len(f) == 0 {
+			return nil
+		}
+		return map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "response",
+				"schema": f,
+			},
+		}
+	default:
+		return map[string]any{"type": "json_object"}
+	}
+}
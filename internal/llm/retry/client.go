@@ -0,0 +1,122 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/MalithGihan/uigp-service/internal/llm"
+)
+
+// DefaultRetryOn lists the error kinds that are usually transient.
+// Timeouts are excluded: the attempt has already spent the request's time budget.
+var DefaultRetryOn = []llm.ErrorKind{
+	llm.KindConnectionRefused,
+	llm.KindUnavailable,
+	llm.KindRateLimited,
+	llm.KindBadResponse,
+}
+
+type Policy struct {
+	// MaxAttempts includes the first call; values <= 1 disable retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	RetryOn     []llm.ErrorKind
+}
+
+// Client retries a wrapped llm.Client with jittered exponential backoff
+// for the error kinds listed in the policy.
+type Client struct {
+	llm.Client
+	policy  Policy
+	retryOn map[llm.ErrorKind]bool
+}
+
+// Wrap returns c unchanged when the policy disables retries.
+func Wrap(c llm.Client, p Policy) llm.Client {
+	if p.MaxAttempts <= 1 {
+		return c
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = 500 * time.Millisecond
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	if len(p.RetryOn) == 0 {
+		p.RetryOn = DefaultRetryOn
+	}
+	on := make(map[llm.ErrorKind]bool, len(p.RetryOn))
+	for _, k := range p.RetryOn {
+		on[k] = true
+	}
+	return &Client{Client: c, policy: p, retryOn: on}
+}
+
+func (c *Client) Chat(ctx context.Context, req llm.ChatRequest) (string, error) {
+	return c.do(ctx, func() (string, bool, error) {
+		answer, err := c.Client.Chat(ctx, req)
+		return answer, false, err
+	})
+}
+
+// ChatStream retries only while no delta has been emitted to the caller.
+func (c *Client) ChatStream(ctx context.Context, req llm.ChatRequest, fn llm.StreamFunc) (string, error) {
+	return c.do(ctx, func() (string, bool, error) {
+		emitted := false
+		answer, err := c.Client.ChatStream(ctx, req, func(delta string) error {
+			emitted = true
+			if fn == nil {
+				return nil
+			}
+			return fn(delta)
+		})
+		return answer, emitted, err
+	})
+}
+
+func (c *Client) do(ctx context.Context, call func() (string, bool, error)) (string, error) {
+	sb := llm.ServedByFrom(ctx)
+	for attempt := 1; ; attempt++ {
+		answer, emitted, err := call()
+		if err == nil || emitted || attempt >= c.policy.MaxAttempts || ctx.Err() != nil {
+			return answer, err
+		}
+		if !c.retryOn[llm.KindOf(err)] {
+			return answer, err
+		}
+
+		delay := c.backoff(attempt, err)
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return answer, err
+		case <-t.C:
+		}
+		if sb != nil {
+			sb.Retries++
+		}
+	}
+}
+
+// backoff returns an "equal jitter" delay in [d/2, d] where d doubles per attempt,
+// honouring a larger upstream Retry-After hint up to MaxDelay.
+func (c *Client) backoff(attempt int, err error) time.Duration {
+	d := c.policy.BaseDelay << (attempt - 1)
+	if d <= 0 || d > c.policy.MaxDelay {
+		d = c.policy.MaxDelay
+	}
+	d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+
+	var le *llm.Error
+	if errors.As(err, &le) && le.RetryAfter > d {
+		d = le.RetryAfter
+		if d > c.policy.MaxDelay {
+			d = c.policy.MaxDelay
+		}
+	}
+	return d
+}
@@ -0,0 +1,50 @@
+package llm
+
+import "context"
+
+// Attempt is one provider call made while serving a request through a composite client.
+type Attempt struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Error    string `json:"error,omitempty"`
+	Skipped  bool   `json:"skipped,omitempty"`
+}
+
+// ServedBy records which provider/model actually produced an answer. Composite
+// clients (e.g. a fallback chain) fill it in; callers read it after Chat returns.
+type ServedBy struct {
+	Provider string
+	Model    string
+	Attempts []Attempt
+	// Retries counts calls repeated by a retry policy.
+	Retries int
+}
+
+type servedByKey struct{}
+
+// WithServedBy returns a context carrying an empty ServedBy recorder.
+func WithServedBy(ctx context.Context) (context.Context, *ServedBy) {
+	sb := &ServedBy{}
+	return context.WithValue(ctx, servedByKey{}, sb), sb
+}
+
+// ServedByFrom returns the recorder attached to ctx, or nil.
+func ServedByFrom(ctx context.Context) *ServedBy {
+	sb, _ := ctx.Value(servedByKey{}).(*ServedBy)
+	return sb
+}
+
+// MemberStatus describes one provider of a composite client for readiness reporting.
+type MemberStatus struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Breaker  string `json:"breaker"`
+	Failures int    `json:"consecutive_failures"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MemberReporter is implemented by composite clients that can report per-member health.
+type MemberReporter interface {
+	Members(ctx context.Context) []MemberStatus
+}
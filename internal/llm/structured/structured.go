@@ -0,0 +1,166 @@
+// Package structured asks an LLM for a JSON object, validates it and
+// re-prompts with the failing locations until it validates or the attempts
+// run out.
+package structured
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/MalithGihan/uigp-service/internal/llm"
+	"github.com/MalithGihan/uigp-service/internal/validate"
+)
+
+var ErrNotJSON = errors.New("model did not return a JSON object")
+
+// maxListedViolations caps the errors quoted back to the model per attempt.
+const maxListedViolations = 12
+
+type Request struct {
+	System string
+	Prompt string
+	// Schema is sent as the response format; nil asks for plain JSON mode.
+	Schema  map[string]any
+	Options map[string]any
+
+	// Normalize, when set, runs on every decoded answer before Validate.
+	Normalize func(map[string]any) map[string]any
+	// Validate reports schema failures; validate.Violations turns them into
+	// the locations quoted in the repair prompt.
+	Validate func(map[string]any) error
+
+	// MaxAttempts includes the first call; values < 1 mean 1.
+	MaxAttempts int
+	// OnAttempt, when set, is called before each call with its 1-based number.
+	OnAttempt func(n int)
+}
+
+// Attempt records one model call.
+type Attempt struct {
+	N        int                  `json:"attempt"`
+	Provider string               `json:"provider,omitempty"`
+	Model    string               `json:"model,omitempty"`
+	Valid    bool                 `json:"valid"`
+	Errors   []validate.Violation `json:"errors,omitempty"`
+	// Error is set when the call failed or the answer was not JSON.
+	Error string `json:"error,omitempty"`
+}
+
+type Result struct {
+	// Value is the last decoded answer, valid or not; nil when no attempt
+	// produced JSON.
+	Value    map[string]any
+	Valid    bool
+	Attempts []Attempt
+}
+
+// Generate runs the attempt loop. It returns an error only when ctx ends;
+// exhausting the attempts is reported through Result.Valid.
+func Generate(ctx context.Context, c llm.Client, req Request) (Result, error) {
+	attempts := req.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var format any = "json"
+	if req.Schema != nil {
+		format = req.Schema
+	}
+
+	var res Result
+	msgs := []llm.Message{
+		{Role: "system", Content: req.System},
+		{Role: "user", Content: req.Prompt},
+	}
+	for n := 1; n <= attempts; n++ {
+		if req.OnAttempt != nil {
+			req.OnAttempt(n)
+		}
+		callCtx, sb := llm.WithServedBy(ctx)
+		answer, err := c.Chat(callCtx, llm.ChatRequest{Messages: msgs, Format: format, Options: req.Options})
+		at := Attempt{N: n, Provider: c.Provider(), Model: c.Model()}
+		if sb.Provider != "" {
+			at.Provider, at.Model = sb.Provider, sb.Model
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			at.Error = err.Error()
+			res.Attempts = append(res.Attempts, at)
+			continue
+		}
+
+		value, err := DecodeObject(answer)
+		if err != nil {
+			at.Error = err.Error()
+			res.Attempts = append(res.Attempts, at)
+			msgs = append(msgs[:2],
+				llm.Message{Role: "assistant", Content: answer},
+				llm.Message{Role: "user", Content: "That was not a single JSON object. Return ONLY the JSON object."})
+			continue
+		}
+		if req.Normalize != nil {
+			value = req.Normalize(value)
+		}
+		res.Value = value
+
+		var verr error
+		if req.Validate != nil {
+			verr = req.Validate(value)
+		}
+		if verr == nil {
+			at.Valid = true
+			res.Valid = true
+			res.Attempts = append(res.Attempts, at)
+			return res, nil
+		}
+		at.Errors = validate.Violations(verr)
+		res.Attempts = append(res.Attempts, at)
+		// Only the latest answer and its errors go back; earlier rounds
+		// would just eat context.
+		msgs = append(msgs[:2],
+			llm.Message{Role: "assistant", Content: mustJSON(value)},
+			llm.Message{Role: "user", Content: repairPrompt(at.Errors)})
+	}
+	return res, nil
+}
+
+func repairPrompt(vs []validate.Violation) string {
+	var b strings.Builder
+	b.WriteString("The JSON above failed schema validation at these locations (JSON pointers):\n")
+	for i, v := range vs {
+		if i == maxListedViolations {
+			fmt.Fprintf(&b, "- ... and %d more\n", len(vs)-i)
+			break
+		}
+		p := v.Path
+		if p == "" {
+			p = "/"
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", p, v.Message)
+	}
+	b.WriteString("Fix only those locations, keep every other fact, and return the full corrected JSON object only.")
+	return b.String()
+}
+
+// DecodeObject accepts a bare JSON object or one wrapped in prose or a
+// markdown fence, which some providers add even in JSON mode.
+func DecodeObject(s string) (map[string]any, error) {
+	var out map[string]any
+	if err := json.Unmarshal([]byte(s), &out); err == nil && out != nil {
+		return out, nil
+	}
+	i, j := strings.Index(s, "{"), strings.LastIndex(s, "}")
+	if i < 0 || j < i {
+		return nil, ErrNotJSON
+	}
+	if err := json.Unmarshal([]byte(s[i:j+1]), &out); err != nil || out == nil {
+		return nil, ErrNotJSON
+	}
+	return out, nil
+}
+
+func mustJSON(v any) string { b, _ := json.Marshal(v); return string(b) }
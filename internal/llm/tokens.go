@@ -0,0 +1,34 @@
+package llm
+
+import "unicode/utf8"
+
+// messageOverheadTokens approximates the chat-template tokens added around each
+// message (role markers, separators).
+const messageOverheadTokens = 4
+
+// EstimateTokens approximates how many tokens s occupies for a BPE tokenizer
+// without loading one: roughly four ASCII characters per token, and one token
+// per non-ASCII rune. It deliberately errs on the high side.
+func EstimateTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+	ascii, other := 0, 0
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			ascii++
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		other++
+		i += size
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimateMessageTokens is EstimateTokens for one chat message, including the
+// per-message template overhead.
+func EstimateMessageTokens(content string) int {
+	return EstimateTokens(content) + messageOverheadTokens
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrNotFound is returned when a job directory or file does not exist.
+var ErrNotFound = errors.New("store: not found")
+
+type FS struct{ Root string }
+
+func New(root string) (*FS, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &FS{Root: root}, nil
+}
+func (s *FS) JobDir(id string) string { return filepath.Join(s.Root, id) }
+func (s *FS) MkJob(id string) (string, error) {
+	j := s.JobDir(id)
+	return j, os.MkdirAll(filepath.Join(j, "uploads"), 0o755)
+}
+
+// SaveUpload writes an uploaded file into the job's uploads directory under
+// its base name and returns the path.
+func (s *FS) SaveUpload(id, name string, data []byte) (string, error) {
+	p := filepath.Join(s.JobDir(id), "uploads", filepath.Base(name))
+	return p, os.WriteFile(p, data, 0o644)
+}
+
+// WriteFile replaces a file in the job directory through a temp file and
+// rename, so readers never see a torn write.
+func (s *FS) WriteFile(id, name string, data []byte) error {
+	dir := s.JobDir(id)
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
+
+func (s *FS) WriteJSON(id, name string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.WriteFile(id, name, b)
+}
+
+func (s *FS) ReadJSON(id, name string, v any) error {
+	b, err := os.ReadFile(filepath.Join(s.JobDir(id), name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
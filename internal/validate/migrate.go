@@ -0,0 +1,154 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A migration upgrades a spec in place from one schema version to the next.
+type migration struct {
+	to string
+	fn func(spec map[string]any)
+}
+
+// migrations is keyed by the version a step upgrades from.
+var migrations = map[string]migration{
+	"0.1.0": {to: "0.2.0", fn: migrate010To020},
+}
+
+// Migrate upgrades spec in place from its metadata.schemaVersion to Current
+// and returns the versions it passed through, starting with the original.
+// A spec without a version is taken to be 0.1.0, the only version before
+// versions were checked. Specs without a metadata object are left alone.
+func Migrate(spec map[string]any) ([]string, error) {
+	md, ok := spec["metadata"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	v, _ := md["schemaVersion"].(string)
+	if v == "" {
+		v = "0.1.0"
+	}
+	path := []string{v}
+	for v != Current {
+		step, ok := migrations[v]
+		if !ok {
+			return path, fmt.Errorf("%w: %s", ErrUnknownVersion, v)
+		}
+		step.fn(spec)
+		v = step.to
+		md["schemaVersion"] = v
+		path = append(path, v)
+	}
+	return path, nil
+}
+
+// migrate010To020: dependencies always carry sync, ownerService is a string
+// or absent (MockFromIntermediate wrote null), api protocols use the
+// dependency kinds (http, https and graphql become rest), and names and
+// dependency ends may not be empty: such entries are dropped and each drop
+// is recorded as a gap.
+func migrate010To020(spec map[string]any) {
+	for _, k := range []string{"services", "datastores", "topics"} {
+		dropEmpty(spec, k, "name")
+	}
+	dropEmpty(spec, "dependencies", "from", "to")
+	for _, d := range objects(spec["dependencies"]) {
+		if _, ok := d["sync"].(bool); !ok {
+			d["sync"] = d["kind"] != "event"
+		}
+	}
+	for _, ds := range objects(spec["datastores"]) {
+		if s, ok := ds["ownerService"].(string); !ok || s == "" {
+			delete(ds, "ownerService")
+		}
+	}
+	if _, ok := spec["apis"]; ok {
+		kept := []any{}
+		for _, m := range objects(spec["apis"]) {
+			p, _ := m["protocol"].(string)
+			if p, ok := apiProtocols[strings.ToLower(p)]; ok {
+				m["protocol"] = p
+				kept = append(kept, m)
+			}
+		}
+		spec["apis"] = kept
+	}
+}
+
+// apiProtocols maps 0.1.0 api protocols onto the 0.2.0 enum; others are
+// dropped.
+var apiProtocols = map[string]string{
+	"rest": "rest", "http": "rest", "https": "rest", "graphql": "rest",
+	"grpc":  "grpc",
+	"event": "event",
+}
+
+// dropEmpty removes the entries of spec[key] with an empty string in any of
+// fields (0.2.0 requires minLength 1) and adds a gap naming each one.
+func dropEmpty(spec map[string]any, key string, fields ...string) {
+	list, ok := spec[key].([]any)
+	if !ok {
+		if typed, ok := spec[key].([]map[string]any); ok {
+			for _, m := range typed {
+				list = append(list, m)
+			}
+		}
+	}
+	if list == nil {
+		return
+	}
+	kept := []any{}
+	for i, v := range list {
+		m, _ := v.(map[string]any)
+		empty := ""
+		for _, f := range fields {
+			if s, ok := m[f].(string); ok && strings.TrimSpace(s) == "" && empty == "" {
+				empty = f
+			}
+		}
+		if empty == "" {
+			kept = append(kept, v)
+			continue
+		}
+		addGap(spec, fmt.Sprintf("%s[%d] dropped while migrating to 0.2.0: empty %s", key, i, empty))
+	}
+	spec[key] = kept
+}
+
+// addGap appends a {"description": ...} gap, whether gaps was decoded or
+// built in Go as strings or objects.
+func addGap(spec map[string]any, desc string) {
+	var gaps []any
+	switch g := spec["gaps"].(type) {
+	case []any:
+		gaps = g
+	case []string:
+		for _, s := range g {
+			gaps = append(gaps, s)
+		}
+	case []map[string]any:
+		for _, m := range g {
+			gaps = append(gaps, m)
+		}
+	}
+	spec["gaps"] = append(gaps, map[string]any{"description": desc})
+}
+
+// objects returns the map entries of a JSON array, whether it was decoded
+// ([]any) or built in Go ([]map[string]any).
+func objects(v any) []map[string]any {
+	switch a := v.(type) {
+	case []map[string]any:
+		return a
+	case []any:
+		out := make([]map[string]any, 0, len(a))
+		for _, x := range a {
+			if m, ok := x.(map[string]any); ok {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/MalithGihan/uigp-service/schema"
+)
+
+// Current is the schema version produced by the pipeline; older specs are
+// upgraded to it with Migrate.
+const Current = "0.2.0"
+
+var ErrUnknownVersion = errors.New("unknown schema version")
+
+type version struct {
+	raw    []byte
+	doc    map[string]any
+	schema *jsonschema.Schema
+}
+
+var (
+	once     sync.Once
+	versions map[string]*version
+	loadErr  error
+)
+
+// load compiles every embedded architecture-<version>.schema.json.
+func load() {
+	names, err := fs.Glob(schema.FS, "architecture-*.schema.json")
+	if err != nil {
+		loadErr = err
+		return
+	}
+	versions = map[string]*version{}
+	for _, name := range names {
+		v := strings.TrimSuffix(strings.TrimPrefix(name, "architecture-"), ".schema.json")
+		b, err := schema.FS.ReadFile(name)
+		if err != nil {
+			loadErr = err
+			return
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			loadErr = fmt.Errorf("%s: %w", name, err)
+			return
+		}
+		url := "uigp://schema/architecture/" + v
+		c := jsonschema.NewCompiler()
+		if err := c.AddResource(url, bytes.NewReader(b)); err != nil {
+			loadErr = fmt.Errorf("%s: %w", name, err)
+			return
+		}
+		s, err := c.Compile(url)
+		if err != nil {
+			loadErr = fmt.Errorf("%s: %w", name, err)
+			return
+		}
+		versions[v] = &version{raw: b, doc: doc, schema: s}
+	}
+	if versions[Current] == nil {
+		loadErr = fmt.Errorf("schema %s is not embedded", Current)
+	}
+}
+
+// ValidateMap validates a generic map against the current schema.
+func ValidateMap(m map[string]any) error {
+	return ValidateVersion(m, Current)
+}
+
+// ValidateVersion validates m against the given schema version.
+func ValidateVersion(m map[string]any, v string) error {
+	once.Do(load)
+	if loadErr != nil {
+		return loadErr
+	}
+	sv := versions[v]
+	if sv == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownVersion, v)
+	}
+	b, _ := json.Marshal(m)
+	var doc any
+	_ = json.Unmarshal(b, &doc)
+	return sv.schema.Validate(doc)
+}
+
+// Schema returns the current schema document, e.g. to request structured
+// output from an LLM. Callers must not modify it.
+func Schema() (map[string]any, error) {
+	once.Do(load)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	return versions[Current].doc, nil
+}
+
+// SchemaJSON returns the embedded schema file of a version as stored.
+func SchemaJSON(v string) ([]byte, bool) {
+	once.Do(load)
+	sv := versions[v]
+	if sv == nil {
+		return nil, false
+	}
+	return sv.raw, true
+}
+
+// Versions lists the embedded schema versions, oldest first.
+func Versions() []string {
+	once.Do(load)
+	out := make([]string, 0, len(versions))
+	for v := range versions {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool { return lessVersion(out[i], out[j]) })
+	return out
+}
+
+// lessVersion compares dotted numeric versions ("0.10.0" > "0.9.1").
+func lessVersion(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errx := strconv.Atoi(as[i])
+		y, erry := strconv.Atoi(bs[i])
+		if errx != nil || erry != nil {
+			if as[i] != bs[i] {
+				return as[i] < bs[i]
+			}
+			continue
+		}
+		if x != y {
+			return x < y
+		}
+	}
+	return len(as) < len(bs)
+}
+
+// Violation is one failed schema check. Path is a JSON pointer into the
+// validated document ("" is the root).
+type Violation struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword,omitempty"`
+	Message string `json:"message"`
+}
+
+// Violations flattens a ValidateMap error into its leaf failures, ordered by
+// path. Errors that are not schema violations come back as one root entry.
+func Violations(err error) []Violation {
+	if err == nil {
+		return nil
+	}
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []Violation{{Message: err.Error()}}
+	}
+	var out []Violation
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			out = append(out, Violation{
+				Path:    e.InstanceLocation,
+				Keyword: path.Base(e.KeywordLocation),
+				Message: e.Message,
+			})
+			return
+		}
+		for _, c := range e.Causes {
+			walk(c)
+		}
+	}
+	walk(ve)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
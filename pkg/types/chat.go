@@ -5,3 +5,10 @@ type Attachment struct {
 	ContentType string `json:"content_type"`
 	DataBase64  string `json:"data_base64"`
 }
+
+// InfraFile is an infrastructure-as-code file sent as text: a docker-compose
+// file or Kubernetes manifests.
+type InfraFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
@@ -0,0 +1,433 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Diagram is the typed form of diagram_json. It is decoded from either the
+// nodes/edges shape produced by the diagram editor or the
+// services/dependencies shape of the architecture schema.
+type Diagram struct {
+	Shape    string          `json:"shape"`
+	Nodes    []DiagramNode   `json:"nodes"`
+	Edges    []DiagramEdge   `json:"edges"`
+	Groups   []DiagramGroup  `json:"groups,omitempty"`
+	Metadata DiagramMetadata `json:"metadata"`
+}
+
+const (
+	ShapeNodesEdges           = "nodes_edges"
+	ShapeServicesDependencies = "services_dependencies"
+)
+
+// DiagramNode is a component. Type is lower-cased (service, gateway, db, topic,
+// client, ...); Group is the id of the enclosing group or boundary, if any;
+// Ports are the exposed ports when the source declares them (compose, k8s).
+type DiagramNode struct {
+	ID    string   `json:"id"`
+	Label string   `json:"label,omitempty"`
+	Type  string   `json:"type,omitempty"`
+	Group string   `json:"group,omitempty"`
+	Ports []string `json:"ports,omitempty"`
+}
+
+// Name is the label when set, otherwise the id.
+func (n DiagramNode) Name() string {
+	if n.Label != "" {
+		return n.Label
+	}
+	return n.ID
+}
+
+// Edge interaction modes. ModeUnknown means neither the payload nor the
+// protocol said whether the call blocks.
+const (
+	ModeSync    = "sync"
+	ModeAsync   = "async"
+	ModeUnknown = ""
+)
+
+// DiagramEdge is a directed dependency between two node ids.
+type DiagramEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Protocol string `json:"protocol,omitempty"`
+	Mode     string `json:"mode,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+func (e DiagramEdge) Async() bool { return e.Mode == ModeAsync }
+func (e DiagramEdge) Sync() bool  { return e.Mode == ModeSync }
+
+// DiagramGroup is a boundary, zone or namespace drawn around nodes.
+type DiagramGroup struct {
+	ID      string   `json:"id"`
+	Label   string   `json:"label,omitempty"`
+	Kind    string   `json:"kind,omitempty"`
+	Members []string `json:"members,omitempty"`
+}
+
+type DiagramMetadata struct {
+	DiagramVersionID string         `json:"diagram_version_id,omitempty"`
+	SchemaVersion    string         `json:"schema_version,omitempty"`
+	Title            string         `json:"title,omitempty"`
+	Extra            map[string]any `json:"extra,omitempty"`
+}
+
+// DiagramWarning reports input the decoder skipped or repaired. Path is a
+// JSON-pointer-like location such as "edges[3].to".
+type DiagramWarning struct {
+	Path    string `json:"path"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Node returns the node with the given id.
+func (d Diagram) Node(id string) (DiagramNode, bool) {
+	for _, n := range d.Nodes {
+		if n.ID == id {
+			return n, true
+		}
+	}
+	return DiagramNode{}, false
+}
+
+// NodeIndex maps node ids to nodes.
+func (d Diagram) NodeIndex() map[string]DiagramNode {
+	out := make(map[string]DiagramNode, len(d.Nodes))
+	for _, n := range d.Nodes {
+		out[n.ID] = n
+	}
+	return out
+}
+
+// Label resolves a node id to its display name, falling back to the id.
+func (d Diagram) Label(id string) string {
+	if n, ok := d.Node(id); ok {
+		return n.Name()
+	}
+	return id
+}
+
+func (d Diagram) Empty() bool {
+	return len(d.Nodes) == 0 && len(d.Edges) == 0
+}
+
+// ParseDiagram decodes raw diagram JSON. Only malformed JSON is an error;
+// structural problems are returned as warnings.
+func ParseDiagram(data []byte) (Diagram, []DiagramWarning, error) {
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Diagram{}, nil, fmt.Errorf("diagram: %w", err)
+	}
+	d, warns := DecodeDiagram(m)
+	return d, warns, nil
+}
+
+// DecodeDiagram converts a generic diagram_json object. It accepts nodes/edges
+// and services/dependencies (plus datastores and topics); when both are
+// present nodes/edges win. Nodes without an id fall back to their label or
+// name, duplicates keep the first occurrence, and edges with a missing
+// endpoint are skipped.
+func DecodeDiagram(m map[string]any) (Diagram, []DiagramWarning) {
+	dec := diagramDecoder{seen: map[string]bool{}}
+	d := Diagram{}
+	if len(m) == 0 {
+		return d, nil
+	}
+
+	if _, ok := m["nodes"]; ok {
+		d.Shape = ShapeNodesEdges
+		for i, v := range dec.list(m, "nodes") {
+			dec.node(&d, fmt.Sprintf("nodes[%d]", i), v, "", false)
+		}
+	} else if _, ok := m["services"]; ok {
+		d.Shape = ShapeServicesDependencies
+		for i, v := range dec.list(m, "services") {
+			dec.node(&d, fmt.Sprintf("services[%d]", i), v, "service", true)
+		}
+		for i, v := range dec.list(m, "datastores") {
+			dec.node(&d, fmt.Sprintf("datastores[%d]", i), v, "database", true)
+		}
+		for i, v := range dec.list(m, "topics") {
+			dec.node(&d, fmt.Sprintf("topics[%d]", i), v, "topic", true)
+		}
+	}
+
+	edgeKey := "edges"
+	if _, ok := m["edges"]; !ok {
+		if _, ok := m["dependencies"]; ok {
+			edgeKey = "dependencies"
+			if d.Shape == "" {
+				d.Shape = ShapeServicesDependencies
+			}
+		}
+	}
+	for i, v := range dec.list(m, edgeKey) {
+		dec.edge(&d, fmt.Sprintf("%s[%d]", edgeKey, i), v)
+	}
+
+	groupKey := "groups"
+	if _, ok := m["groups"]; !ok {
+		groupKey = "boundaries"
+	}
+	for i, v := range dec.list(m, groupKey) {
+		dec.group(&d, fmt.Sprintf("%s[%d]", groupKey, i), v)
+	}
+	dec.linkGroups(&d)
+
+	if md, ok := m["metadata"].(map[string]any); ok {
+		d.Metadata = DiagramMetadata{
+			DiagramVersionID: str(md["diagram_version_id"]),
+			SchemaVersion:    firstStr(md, "schema_version", "schemaVersion"),
+			Title:            firstStr(md, "title", "name"),
+			Extra:            md,
+		}
+	}
+
+	if d.Shape == "" && len(d.Nodes) == 0 && len(d.Edges) == 0 {
+		dec.warn("", "unknown_shape", "expected nodes/edges or services/dependencies")
+	}
+	return d, dec.warns
+}
+
+type diagramDecoder struct {
+	seen  map[string]bool
+	warns []DiagramWarning
+}
+
+func (dec *diagramDecoder) warn(path, code, msg string) {
+	dec.warns = append(dec.warns, DiagramWarning{Path: path, Code: code, Message: msg})
+}
+
+func (dec *diagramDecoder) list(m map[string]any, key string) []any {
+	v, ok := m[key]
+	if !ok || v == nil {
+		return nil
+	}
+	l, ok := v.([]any)
+	if !ok {
+		dec.warn(key, "not_a_list", key+" must be an array")
+		return nil
+	}
+	return l
+}
+
+// node decodes one node; in the services shape the name is the id, so a
+// missing id is expected there and not reported.
+func (dec *diagramDecoder) node(d *Diagram, path string, v any, defaultType string, nameIsID bool) {
+	var n DiagramNode
+	switch t := v.(type) {
+	case string:
+		n = DiagramNode{ID: strings.TrimSpace(t)}
+	case map[string]any:
+		n = DiagramNode{
+			ID:    str(t["id"]),
+			Label: firstStr(t, "label", "name"),
+			Type:  strings.ToLower(firstStr(t, "type", "kind")),
+			Group: firstStr(t, "group", "parent", "boundary"),
+		}
+		if l, ok := t["ports"].([]any); ok {
+			for _, p := range l {
+				if s := str(p); s != "" {
+					n.Ports = append(n.Ports, s)
+				}
+			}
+		}
+	default:
+		dec.warn(path, "invalid_node", "node must be an object or a string")
+		return
+	}
+	if n.ID == "" {
+		n.ID = n.Label
+		if n.ID == "" {
+			dec.warn(path, "missing_id", "node has neither id nor label; skipped")
+			return
+		}
+		if !nameIsID {
+			dec.warn(path+".id", "missing_id", "node id missing; using label "+strconv.Quote(n.ID))
+		}
+	}
+	if n.Type == "" {
+		n.Type = defaultType
+	}
+	if dec.seen[n.ID] {
+		dec.warn(path+".id", "duplicate_id", "duplicate node id "+strconv.Quote(n.ID)+"; keeping the first")
+		return
+	}
+	dec.seen[n.ID] = true
+	d.Nodes = append(d.Nodes, n)
+}
+
+func (dec *diagramDecoder) edge(d *Diagram, path string, v any) {
+	t, ok := v.(map[string]any)
+	if !ok {
+		dec.warn(path, "invalid_edge", "edge must be an object")
+		return
+	}
+	e := DiagramEdge{
+		From:     firstStr(t, "from", "source"),
+		To:       firstStr(t, "to", "target"),
+		Protocol: firstStr(t, "protocol", "kind"),
+		Label:    str(t["label"]),
+	}
+	if e.From == "" || e.To == "" {
+		dec.warn(path, "missing_endpoint", "edge needs both from and to; skipped")
+		return
+	}
+	e.Mode = edgeMode(t, e.Protocol)
+	if len(dec.seen) > 0 {
+		if !dec.seen[e.From] {
+			dec.warn(path+".from", "unknown_node", "edge references unknown node "+strconv.Quote(e.From))
+		}
+		if !dec.seen[e.To] {
+			dec.warn(path+".to", "unknown_node", "edge references unknown node "+strconv.Quote(e.To))
+		}
+	}
+	d.Edges = append(d.Edges, e)
+}
+
+func (dec *diagramDecoder) group(d *Diagram, path string, v any) {
+	t, ok := v.(map[string]any)
+	if !ok {
+		dec.warn(path, "invalid_group", "group must be an object")
+		return
+	}
+	g := DiagramGroup{
+		ID:    str(t["id"]),
+		Label: firstStr(t, "label", "name"),
+		Kind:  strings.ToLower(firstStr(t, "kind", "type")),
+	}
+	if g.ID == "" {
+		g.ID = g.Label
+	}
+	if g.ID == "" {
+		dec.warn(path, "missing_id", "group has neither id nor label; skipped")
+		return
+	}
+	for _, key := range []string{"members", "nodes", "children"} {
+		if l, ok := t[key].([]any); ok {
+			for _, m := range l {
+				if s := str(m); s != "" {
+					g.Members = append(g.Members, s)
+				}
+			}
+		}
+	}
+	d.Groups = append(d.Groups, g)
+}
+
+// linkGroups makes node.Group and group.Members agree, adding implicit groups
+// for group ids that only appear on nodes.
+func (dec *diagramDecoder) linkGroups(d *Diagram) {
+	byID := map[string]int{}
+	for i, g := range d.Groups {
+		byID[g.ID] = i
+	}
+	for _, n := range d.Nodes {
+		if n.Group == "" {
+			continue
+		}
+		gi, ok := byID[n.Group]
+		if !ok {
+			d.Groups = append(d.Groups, DiagramGroup{ID: n.Group})
+			gi = len(d.Groups) - 1
+			byID[n.Group] = gi
+		}
+		if !containsString(d.Groups[gi].Members, n.ID) {
+			d.Groups[gi].Members = append(d.Groups[gi].Members, n.ID)
+		}
+	}
+	for _, g := range d.Groups {
+		for _, m := range g.Members {
+			for i := range d.Nodes {
+				if d.Nodes[i].ID == m && d.Nodes[i].Group == "" {
+					d.Nodes[i].Group = g.ID
+				}
+			}
+		}
+	}
+}
+
+var asyncProtocols = map[string]bool{
+	"event": true, "events": true, "async": true, "pub": true, "sub": true, "pubsub": true,
+	"publish": true, "subscribe": true, "message": true, "messaging": true, "queue": true,
+	"kafka": true, "amqp": true, "rabbitmq": true, "mqtt": true, "nats": true, "sqs": true,
+	"sns": true, "stream": true, "webhook": true,
+}
+
+var syncProtocols = map[string]bool{
+	"rest": true, "http": true, "https": true, "grpc": true, "graphql": true, "soap": true,
+	"sql": true, "jdbc": true, "tcp": true, "rpc": true, "sync": true,
+}
+
+// edgeMode reads sync/async/mode from the edge and falls back to the protocol.
+func edgeMode(t map[string]any, protocol string) string {
+	if b, ok := t["sync"].(bool); ok {
+		if b {
+			return ModeSync
+		}
+		return ModeAsync
+	}
+	if b, ok := t["async"].(bool); ok {
+		if b {
+			return ModeAsync
+		}
+		return ModeSync
+	}
+	switch strings.ToLower(firstStr(t, "mode", "interaction")) {
+	case "sync", "synchronous", "request", "request-response":
+		return ModeSync
+	case "async", "asynchronous", "event", "fire-and-forget":
+		return ModeAsync
+	}
+	return ProtocolMode(protocol)
+}
+
+// ProtocolMode classifies a protocol name as sync or async, or ModeUnknown.
+func ProtocolMode(protocol string) string {
+	p := strings.ToLower(strings.TrimSpace(protocol))
+	switch {
+	case asyncProtocols[p]:
+		return ModeAsync
+	case syncProtocols[p]:
+		return ModeSync
+	}
+	return ModeUnknown
+}
+
+func str(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool, int, int64:
+		return fmt.Sprint(t)
+	default:
+		return ""
+	}
+}
+
+func firstStr(m map[string]any, keys ...string) string {
+	for _, k := range keys {
+		if s := str(m[k]); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func containsString(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,19 +1,64 @@
 package types
 
+import "strings"
+
 type Node struct {
 	ID     string
-	Type   string // service|db|queue|gateway|ext
+	Type   string // service|db|queue|topic|gateway|actor|client|ext
 	Label  string
 	Source string
-	BBox   [4]int
+	BBox   [4]int // x, y, width, height
+	// Group is the id of the enclosing Group, if any.
+	Group string
+	// Ports are exposed ports as written in the source ("8080:80", "5432").
+	Ports []string
 }
 type Edge struct {
 	From, To, Protocol string // REST|gRPC|PUB|SUB
+	Label              string
 	BBox               [4]int
 }
+
+// Group is a container drawn around nodes (VPC, namespace, swimlane, ...).
+type Group struct {
+	ID      string
+	Label   string
+	Kind    string
+	Members []string
+	BBox    [4]int
+}
+
 type IntermediateGraph struct {
-	Nodes []Node
-	Edges []Edge
-	Notes []string
-	Trace []any
+	Nodes  []Node
+	Edges  []Edge
+	Groups []Group
+	Notes  []string
+	Trace  []any
+}
+
+// Diagram converts an ingested graph into the typed diagram model. Duplicate
+// node ids keep the first node; edges missing an endpoint are dropped.
+func (g IntermediateGraph) Diagram() Diagram {
+	d := Diagram{Shape: ShapeNodesEdges}
+	seen := map[string]bool{}
+	for _, n := range g.Nodes {
+		if n.ID == "" || seen[n.ID] {
+			continue
+		}
+		seen[n.ID] = true
+		d.Nodes = append(d.Nodes, DiagramNode{ID: n.ID, Label: n.Label, Type: strings.ToLower(n.Type), Group: n.Group, Ports: n.Ports})
+	}
+	for _, g := range g.Groups {
+		if g.ID == "" {
+			continue
+		}
+		d.Groups = append(d.Groups, DiagramGroup{ID: g.ID, Label: g.Label, Kind: g.Kind, Members: g.Members})
+	}
+	for _, e := range g.Edges {
+		if e.From == "" || e.To == "" {
+			continue
+		}
+		d.Edges = append(d.Edges, DiagramEdge{From: e.From, To: e.To, Protocol: e.Protocol, Mode: ProtocolMode(e.Protocol), Label: e.Label})
+	}
+	return d
 }
@@ -0,0 +1,10 @@
+// Package schema embeds the versioned architecture spec JSON schemas, so the
+// binary validates specs regardless of its working directory.
+package schema
+
+import "embed"
+
+// FS holds architecture-<version>.schema.json for every supported version.
+//
+//go:embed architecture-*.schema.json
+var FS embed.FS
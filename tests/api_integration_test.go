@@ -5,10 +5,16 @@ package tests_test
 
 import (
 	"bytes"
+	"compress/flate"
+	"encoding/base64"
 	"encoding/json"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -182,6 +188,289 @@ func TestChat_WithDiagramJSON(t *testing.T) {
 	}
 }
 
+func TestChat_WithDrawIOAttachment(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+
+	drawio := `<mxfile><diagram><mxGraphModel><root><mxCell id="0"/><mxCell id="1" parent="0"/>` +
+		`<mxCell id="2" value="api-gateway" vertex="1" parent="1"/><mxCell id="3" value="order-service" vertex="1" parent="1"/>` +
+		`<mxCell id="4" value="REST" edge="1" source="2" target="3" parent="1"/></root></mxGraphModel></diagram></mxfile>`
+	// A second file reusing the same cell ids must not collapse into the first.
+	billing := strings.NewReplacer("api-gateway", "billing", "order-service", "ledger").Replace(drawio)
+
+	h := map[string]string{"X-API-Key": key}
+	req := map[string]any{
+		"message": "Review the attached diagram.",
+		"history": []any{},
+		"attachments": []any{
+			map[string]any{"name": "arch.drawio", "content_type": "application/xml", "data_base64": base64.StdEncoding.EncodeToString([]byte(drawio))},
+			map[string]any{"name": "billing.drawio", "content_type": "application/xml", "data_base64": base64.StdEncoding.EncodeToString([]byte(billing))},
+			map[string]any{"name": "notes.bin", "content_type": "application/octet-stream", "data_base64": "not base64!"},
+		},
+	}
+
+	var out ChatResponse
+	status := doJSON(t, "POST", base+"/api/v1/chat", h, req, &out)
+	if status != 200 {
+		t.Fatalf("expected 200, got %d, resp=%+v", status, out)
+	}
+	if v, _ := out.Signals["attachments_parsed"].(float64); v != 2 {
+		t.Fatalf("expected attachments_parsed=2, got %v", out.Signals["attachments_parsed"])
+	}
+	if v, _ := out.Meta["context_used"].(string); !strings.Contains(v, "attachment_diagram") {
+		t.Fatalf("expected context_used to include attachment_diagram, got %v", out.Meta["context_used"])
+	}
+	if v, _ := out.Signals["services_count"].(float64); v != 4 {
+		t.Fatalf("expected the nodes of both attachments in services_count, got %v", out.Signals["services_count"])
+	}
+	if v, _ := out.Signals["dependencies_count"].(float64); v != 2 {
+		t.Fatalf("expected the attachments' edges in dependencies_count, got %v", out.Signals["dependencies_count"])
+	}
+	sts, _ := out.Signals["attachments"].([]any)
+	if len(sts) != 3 {
+		t.Fatalf("expected 3 attachment statuses, got %v", out.Signals["attachments"])
+	}
+	if st, _ := sts[2].(map[string]any); st["status"] != "decode_error" {
+		t.Fatalf("expected decode_error for the third attachment, got %v", sts[2])
+	}
+}
+
+func TestChat_WithCompressedDrawIOAttachment(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+
+	// draw.io's default save format: base64(deflate(encodeURIComponent(xml))).
+	model := `<mxGraphModel><root><mxCell id="0"/><mxCell id="1" parent="0"/>` +
+		`<mxCell id="vpc" value="Prod" style="shape=mxgraph.aws4.group;grIcon=mxgraph.aws4.group_vpc;container=1;" vertex="1" parent="1"><mxGeometry x="100" y="100" width="400" height="200" as="geometry"/></mxCell>` +
+		`<mxCell id="gw" value="edge" style="shape=mxgraph.aws4.resourceIcon;resIcon=mxgraph.aws4.api_gateway;" vertex="1" parent="vpc"><mxGeometry x="20" y="20" width="78" height="78" as="geometry"/></mxCell>` +
+		`<mxCell id="fn" value="orders" style="shape=mxgraph.aws4.resourceIcon;resIcon=mxgraph.aws4.lambda;" vertex="1" parent="vpc"><mxGeometry x="200" y="20" width="78" height="78" as="geometry"/></mxCell>` +
+		`<mxCell id="db" value="orders-table" style="shape=mxgraph.aws4.resourceIcon;resIcon=mxgraph.aws4.dynamodb;" vertex="1" parent="vpc"><mxGeometry x="300" y="20" width="78" height="78" as="geometry"/></mxCell>` +
+		`<mxCell id="e1" edge="1" source="gw" target="fn" parent="1"><mxGeometry relative="1" as="geometry"/></mxCell>` +
+		`<mxCell id="e1l" value="REST" style="edgeLabel;html=1;" vertex="1" connectable="0" parent="e1"><mxGeometry relative="1" as="geometry"/></mxCell>` +
+		`<mxCell id="e2" edge="1" source="fn" target="db" parent="1"><mxGeometry relative="1" as="geometry"/></mxCell>` +
+		`</root></mxGraphModel>`
+	var z bytes.Buffer
+	fw, _ := flate.NewWriter(&z, flate.BestCompression)
+	fw.Write([]byte(url.PathEscape(model)))
+	fw.Close()
+	drawio := `<mxfile><diagram name="Page-1">` + base64.StdEncoding.EncodeToString(z.Bytes()) + `</diagram></mxfile>`
+
+	h := map[string]string{"X-API-Key": key}
+	req := map[string]any{
+		"message": "Review the attached diagram.",
+		"history": []any{},
+		"attachments": []any{
+			map[string]any{"name": "aws.drawio", "content_type": "application/xml", "data_base64": base64.StdEncoding.EncodeToString([]byte(drawio))},
+		},
+	}
+
+	var out ChatResponse
+	status := doJSON(t, "POST", base+"/api/v1/chat", h, req, &out)
+	if status != 200 {
+		t.Fatalf("expected 200, got %d, resp=%+v", status, out)
+	}
+	sts, _ := out.Signals["attachments"].([]any)
+	if len(sts) != 1 {
+		t.Fatalf("expected 1 attachment status, got %v", out.Signals["attachments"])
+	}
+	st, _ := sts[0].(map[string]any)
+	if st["status"] != "parsed" {
+		t.Fatalf("expected the compressed page to parse, got %v", st)
+	}
+	// The VPC container is a boundary, not a component.
+	if n, _ := st["nodes"].(float64); n != 3 {
+		t.Fatalf("expected 3 nodes, got %v", st)
+	}
+	if e, _ := st["edges"].(float64); e != 2 {
+		t.Fatalf("expected 2 edges, got %v", st)
+	}
+}
+
+func TestChat_WithMermaid(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+
+	mermaid := "flowchart LR\n" +
+		"  web[Web app] -->|REST| gw{{API Gateway}}\n" +
+		"  subgraph core [Core]\n" +
+		"    gw --> orders[Order Service] & users[User Service]\n" +
+		"    orders --> db[(Orders DB)]\n" +
+		"  end\n"
+
+	h := map[string]string{"X-API-Key": key}
+	req := map[string]any{
+		"message": "Review this architecture.",
+		"history": []any{},
+		"mermaid": mermaid,
+	}
+
+	var out ChatResponse
+	status := doJSON(t, "POST", base+"/api/v1/chat", h, req, &out)
+	if status != 200 {
+		t.Fatalf("expected 200, got %d, resp=%+v", status, out)
+	}
+	if out.Signals["diagram_source"] != "mermaid" {
+		t.Fatalf("expected diagram_source=mermaid, got %v", out.Signals["diagram_source"])
+	}
+	if v, _ := out.Signals["mermaid_nodes"].(float64); v != 5 {
+		t.Fatalf("expected 5 mermaid nodes, got %v", out.Signals["mermaid_nodes"])
+	}
+	if v, _ := out.Signals["dependencies_count"].(float64); v != 4 {
+		t.Fatalf("expected 4 dependencies, got %v", out.Signals["dependencies_count"])
+	}
+}
+
+func TestChatAndAnalyze_WithInfraFiles(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+
+	compose := `services:
+  orders:
+    build: ./orders
+    ports: ["8080:8080"]
+    environment:
+      DATABASE_URL: postgres://app:secret@db:5432/orders
+      KAFKA_BROKERS: kafka:9092
+    depends_on: [db]
+  db:
+    image: postgres:16
+  kafka:
+    image: confluentinc/cp-kafka:7.6.0
+`
+	req := map[string]any{
+		"message":     "Which components does orders depend on?",
+		"history":     []any{},
+		"infra_files": []any{map[string]any{"name": "docker-compose.yml", "content": compose}},
+	}
+	var out ChatResponse
+	if status := doJSON(t, "POST", base+"/api/v1/chat", h, req, &out); status != 200 {
+		t.Fatalf("expected 200, got %d, resp=%+v", status, out)
+	}
+	if out.Signals["diagram_source"] != "infra_files" {
+		t.Fatalf("expected diagram_source=infra_files, got %v", out.Signals["diagram_source"])
+	}
+	if n, _ := out.Signals["infra_nodes"].(float64); n != 3 {
+		t.Fatalf("expected 3 compose nodes, got %v", out.Signals["infra_nodes"])
+	}
+	if e, _ := out.Signals["infra_edges"].(float64); e != 2 {
+		t.Fatalf("expected 2 compose edges (depends_on and env hosts merged), got %v", out.Signals["infra_edges"])
+	}
+
+	manifests := `apiVersion: apps/v1
+kind: Deployment
+metadata: {name: orders}
+spec:
+  template:
+    metadata: {labels: {app: orders}}
+    spec:
+      containers:
+        - image: acme/orders
+          env: [{name: PAYMENTS_URL, value: "http://payments:8080"}]
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: payments}
+spec:
+  template:
+    metadata: {labels: {app: payments}}
+    spec:
+      containers: [{image: acme/payments}]
+---
+apiVersion: v1
+kind: Service
+metadata: {name: payments}
+spec: {selector: {app: payments}}
+`
+	var analysis struct {
+		OK         bool   `json:"ok"`
+		Source     string `json:"source"`
+		NodesCount int    `json:"nodes_count"`
+		EdgesCount int    `json:"edges_count"`
+		InfraFiles []struct {
+			Name   string `json:"name"`
+			Status string `json:"status"`
+		} `json:"infra_files"`
+	}
+	areq := map[string]any{"infra_files": []any{
+		map[string]any{"name": "k8s.yaml", "content": manifests},
+		map[string]any{"name": "notes.yaml", "content": "owner: platform-team\n"},
+	}}
+	if status := doJSON(t, "POST", base+"/api/v1/analyze", h, areq, &analysis); status != 200 {
+		t.Fatalf("expected 200, got %d, resp=%+v", status, analysis)
+	}
+	if analysis.Source != "infra_files" || analysis.NodesCount != 2 || analysis.EdgesCount != 1 {
+		t.Fatalf("expected 2 nodes and 1 edge from infra_files, got %+v", analysis)
+	}
+	if len(analysis.InfraFiles) != 2 || analysis.InfraFiles[0].Status != "parsed" || analysis.InfraFiles[1].Status != "unsupported" {
+		t.Fatalf("expected k8s.yaml parsed and notes.yaml unsupported, got %+v", analysis.InfraFiles)
+	}
+
+	var diff struct {
+		CurrentInfraFiles []struct {
+			Status string `json:"status"`
+		} `json:"current_infra_files"`
+	}
+	dreq := map[string]any{"previous": areq, "current": areq}
+	if status := doJSON(t, "POST", base+"/api/v1/diff", h, dreq, &diff); status != 200 || len(diff.CurrentInfraFiles) != 2 {
+		t.Fatalf("expected 200 with 2 current_infra_files statuses, got %d, resp=%+v", status, diff)
+	}
+}
+
+func TestChat_WithStructurizrAndC4PlantUMLAttachments(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+
+	dsl := `workspace {
+    model {
+        user = person "Customer"
+        shop = softwareSystem "Shop" {
+            api = container "Orders API" "" "Go"
+            db = container "Orders DB" "" "PostgreSQL"
+        }
+        user -> api "Places orders" "JSON/HTTPS"
+        api -> db "Reads and writes" "SQL"
+    }
+    views {
+        systemContext shop { include * }
+    }
+}`
+	puml := "@startuml\n!include <C4/C4_Container>\n" +
+		"Container(billing, \"Billing\", \"Go\")\n" +
+		"ContainerQueue(bus, \"Events\", \"Kafka\")\n" +
+		"Rel(billing, bus, \"Publishes invoices\", \"Kafka\")\n" +
+		"@enduml\n"
+
+	h := map[string]string{"X-API-Key": key}
+	req := map[string]any{
+		"message": "Review the attached diagrams.",
+		"history": []any{},
+		"attachments": []any{
+			map[string]any{"name": "shop.dsl", "content_type": "text/plain", "data_base64": base64.StdEncoding.EncodeToString([]byte(dsl))},
+			map[string]any{"name": "billing.puml", "content_type": "text/plain", "data_base64": base64.StdEncoding.EncodeToString([]byte(puml))},
+		},
+	}
+
+	var out ChatResponse
+	status := doJSON(t, "POST", base+"/api/v1/chat", h, req, &out)
+	if status != 200 {
+		t.Fatalf("expected 200, got %d, resp=%+v", status, out)
+	}
+	sts, _ := out.Signals["attachments"].([]any)
+	if len(sts) != 2 {
+		t.Fatalf("expected 2 attachment statuses, got %v", out.Signals["attachments"])
+	}
+	for i, want := range []struct {
+		typ          string
+		nodes, edges float64
+	}{{"structurizr", 3, 2}, {"puml", 2, 1}} {
+		st, _ := sts[i].(map[string]any)
+		if st["status"] != "parsed" || st["type"] != want.typ || st["nodes"] != want.nodes || st["edges"] != want.edges {
+			t.Fatalf("attachment %d: expected %s with %v nodes and %v edges, got %v", i, want.typ, want.nodes, want.edges, st)
+		}
+	}
+}
+
 func TestChat_ModeInstantThinking(t *testing.T) {
 	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
 	key := getenv("UIGP_API_KEY", "dev-key")
@@ -284,3 +573,571 @@ func TestChat_DomainStrictBlocksOutOfScopeIfEnabled(t *testing.T) {
 		}
 	}
 }
+
+func TestChat_StreamEmitsDeltasAndDone(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+
+	b, _ := json.Marshal(map[string]any{
+		"message": "Give 3 quick tips for p95 latency in microservices",
+		"history": []any{},
+		"mode":    "instant",
+	})
+	req, err := http.NewRequest("POST", base+"/api/v1/chat/stream", bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", key)
+
+	c := &http.Client{Timeout: 120 * time.Second}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read stream: %v", err)
+	}
+	body := string(raw)
+	if !strings.Contains(body, "event: delta") {
+		t.Fatalf("expected at least one delta event, body=%s", body)
+	}
+	i := strings.LastIndex(body, "event: done\ndata: ")
+	if i < 0 {
+		t.Fatalf("expected done event, body=%s", body)
+	}
+	var out ChatResponse
+	data := strings.TrimSpace(body[i+len("event: done\ndata: "):])
+	if err := json.Unmarshal([]byte(data), &out); err != nil {
+		t.Fatalf("decode done event: %v", err)
+	}
+	if !out.OK || out.Answer == "" {
+		t.Fatalf("expected ok=true + answer, resp=%+v", out)
+	}
+}
+
+func TestConversations_ServerSideHistory(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+
+	var created struct {
+		OK           bool `json:"ok"`
+		Conversation struct {
+			ID string `json:"id"`
+		} `json:"conversation"`
+	}
+	status := doJSON(t, "POST", base+"/api/v1/conversations", h, map[string]any{"title": "integration"}, &created)
+	if status != 201 || created.Conversation.ID == "" {
+		t.Fatalf("expected 201 + id, got %d, resp=%+v", status, created)
+	}
+	id := created.Conversation.ID
+
+	for _, msg := range []string{"We run 5 microservices behind an API gateway.", "Which one should get a cache?"} {
+		var out ChatResponse
+		status := doJSON(t, "POST", base+"/api/v1/chat", h, map[string]any{"message": msg, "conversation_id": id}, &out)
+		if status != 200 {
+			t.Fatalf("expected 200, got %d, resp=%+v", status, out)
+		}
+		if out.Meta["conversation_id"] != id {
+			t.Fatalf("expected meta.conversation_id=%s, meta=%v", id, out.Meta)
+		}
+	}
+
+	var got struct {
+		Conversation struct {
+			TurnCount int `json:"turn_count"`
+		} `json:"conversation"`
+	}
+	status = doJSON(t, "GET", base+"/api/v1/conversations/"+id, h, nil, &got)
+	if status != 200 || got.Conversation.TurnCount != 4 {
+		t.Fatalf("expected 200 with 4 turns, got %d, resp=%+v", status, got)
+	}
+
+	if status := doJSON(t, "DELETE", base+"/api/v1/conversations/"+id, h, nil, nil); status != 200 {
+		t.Fatalf("expected 200 on delete, got %d", status)
+	}
+	var out ChatResponse
+	status = doJSON(t, "POST", base+"/api/v1/chat", h, map[string]any{"message": "hello", "conversation_id": id}, &out)
+	if status != 404 || out.Error == nil || out.Error.Code != "conversation_not_found" {
+		t.Fatalf("expected 404 conversation_not_found, got %d, resp=%+v", status, out)
+	}
+}
+
+type AnalyzeResponse struct {
+	OK         bool   `json:"ok"`
+	Source     string `json:"source"`
+	NodesCount int    `json:"nodes_count"`
+	Findings   []struct {
+		RuleID   string `json:"rule_id"`
+		Severity string `json:"severity"`
+		Nodes    []struct {
+			ID string `json:"id"`
+		} `json:"nodes"`
+	} `json:"findings"`
+	Summary  map[string]int `json:"summary"`
+	Warnings []struct {
+		Path string `json:"path"`
+		Code string `json:"code"`
+	} `json:"warnings"`
+}
+
+func TestAnalyze_ReturnsFindingsWithoutLLM(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+
+	req := map[string]any{
+		"diagram_json": map[string]any{
+			"nodes": []any{
+				map[string]any{"id": "web", "label": "web", "type": "client"},
+				map[string]any{"id": "gw", "label": "gateway", "type": "gateway"},
+				map[string]any{"id": "a", "label": "orders", "type": "service"},
+				map[string]any{"id": "b", "label": "payments", "type": "service"},
+				map[string]any{"id": "db", "label": "postgres", "type": "db"},
+			},
+			"edges": []any{
+				map[string]any{"from": "web", "to": "a", "protocol": "REST"},
+				map[string]any{"from": "a", "to": "b", "protocol": "gRPC"},
+				map[string]any{"from": "b", "to": "a", "protocol": "gRPC"},
+				map[string]any{"from": "a", "to": "db", "protocol": "SQL"},
+				map[string]any{"from": "b", "to": "db"},
+			},
+		},
+	}
+
+	var out AnalyzeResponse
+	status := doJSON(t, "POST", base+"/api/v1/analyze", h, req, &out)
+	if status != 200 || !out.OK {
+		t.Fatalf("expected 200 ok, got %d, resp=%+v", status, out)
+	}
+	if out.Source != "diagram_json" {
+		t.Fatalf("expected source=diagram_json, got %q", out.Source)
+	}
+	got := map[string]bool{}
+	for _, f := range out.Findings {
+		got[f.RuleID] = true
+	}
+	for _, rule := range []string{"orphan_component", "gateway_bypass", "shared_db_fanin", "dependency_cycle", "missing_protocol"} {
+		if !got[rule] {
+			t.Fatalf("expected a %s finding, got %+v", rule, out.Findings)
+		}
+	}
+
+	status = doJSON(t, "POST", base+"/api/v1/analyze", h, map[string]any{}, nil)
+	if status != 400 {
+		t.Fatalf("expected 400 for empty payload, got %d", status)
+	}
+}
+
+func TestAnalyze_UnparsableMermaidFallsThrough(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+	seq := "sequenceDiagram\n  web->>orders: POST /orders\n"
+
+	var out AnalyzeResponse
+	status := doJSON(t, "POST", base+"/api/v1/analyze", h, map[string]any{
+		"mermaid":      seq,
+		"spec_summary": map[string]any{"services": []any{"orders", "payments"}, "dependencies": []any{"orders -> payments (REST)"}},
+	}, &out)
+	if status != 200 || out.Source != "spec_summary" || out.NodesCount != 2 {
+		t.Fatalf("expected the spec_summary to be analyzed, got %d, resp=%+v", status, out)
+	}
+	var codes []string
+	for _, w := range out.Warnings {
+		codes = append(codes, w.Code)
+	}
+	if !slices.Contains(codes, "mermaid_empty") || !slices.Contains(codes, "mermaid_note") {
+		t.Fatalf("expected mermaid warnings, got %+v", out.Warnings)
+	}
+
+	if status := doJSON(t, "POST", base+"/api/v1/analyze", h, map[string]any{"mermaid": seq}, nil); status != 400 {
+		t.Fatalf("expected 400 when unparsable mermaid is the only input, got %d", status)
+	}
+}
+
+func TestAnalyze_MessagingRules(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+
+	req := map[string]any{
+		"diagram_json": map[string]any{
+			"nodes": []any{
+				map[string]any{"id": "orders", "type": "service"},
+				map[string]any{"id": "billing", "type": "service"},
+				map[string]any{"id": "audit", "label": "audit-events", "type": "topic"},
+				map[string]any{"id": "invoices", "type": "topic"},
+				// q has no dead-letter queue; payments-dlq belongs to payments only.
+				map[string]any{"id": "q", "label": "Shipping", "type": "queue"},
+				map[string]any{"id": "payments", "type": "queue"},
+				map[string]any{"id": "payments-dlq", "type": "queue"},
+			},
+			"edges": []any{
+				map[string]any{"from": "orders", "to": "audit", "protocol": "PUB"},
+				map[string]any{"from": "orders", "to": "invoices", "protocol": "PUB"},
+				map[string]any{"from": "invoices", "to": "billing", "protocol": "SUB"},
+				map[string]any{"from": "orders", "to": "billing", "protocol": "REST"},
+				map[string]any{"from": "orders", "to": "q", "protocol": "PUB"},
+				map[string]any{"from": "q", "to": "billing", "protocol": "SUB"},
+				map[string]any{"from": "billing", "to": "payments", "protocol": "PUB"},
+				map[string]any{"from": "payments", "to": "orders", "protocol": "SUB"},
+			},
+		},
+	}
+
+	var out AnalyzeResponse
+	status := doJSON(t, "POST", base+"/api/v1/analyze", h, req, &out)
+	if status != 200 || !out.OK {
+		t.Fatalf("expected 200 ok, got %d, resp=%+v", status, out)
+	}
+	got := map[string][]string{}
+	for _, f := range out.Findings {
+		for _, n := range f.Nodes {
+			got[f.RuleID] = append(got[f.RuleID], n.ID)
+		}
+	}
+	for rule, want := range map[string]string{
+		"topic_without_subscribers": "audit",
+		"publish_and_sync_call":     "billing",
+		"queue_without_dlq":         "q",
+		"event_cycle":               "orders",
+	} {
+		if !slices.Contains(got[rule], want) {
+			t.Fatalf("expected a %s finding on %s, got %+v", rule, want, out.Findings)
+		}
+	}
+	if slices.Contains(got["queue_without_dlq"], "payments") {
+		t.Fatalf("payments has payments-dlq, got %+v", got["queue_without_dlq"])
+	}
+}
+
+type DiffResponse struct {
+	OK         bool `json:"ok"`
+	AddedNodes []struct {
+		ID string `json:"id"`
+	} `json:"added_nodes"`
+	RemovedEdges []struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"removed_edges"`
+	ProtocolChanges []struct {
+		From             string `json:"from"`
+		To               string `json:"to"`
+		PreviousProtocol string `json:"previous_protocol"`
+		CurrentProtocol  string `json:"current_protocol"`
+	} `json:"protocol_changes"`
+	NewFindings []struct {
+		RuleID string `json:"rule_id"`
+	} `json:"new_findings"`
+}
+
+func TestDiff_ReportsChangesBetweenVersions(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+
+	nodes := []any{
+		map[string]any{"id": "web", "type": "client"},
+		map[string]any{"id": "gw", "type": "gateway"},
+		map[string]any{"id": "orders", "type": "service"},
+		map[string]any{"id": "payments", "type": "service"},
+	}
+	req := map[string]any{
+		"previous": map[string]any{"diagram_json": map[string]any{
+			"nodes": nodes,
+			"edges": []any{
+				map[string]any{"from": "web", "to": "gw", "protocol": "REST"},
+				map[string]any{"from": "gw", "to": "orders", "protocol": "REST"},
+				map[string]any{"from": "orders", "to": "payments", "protocol": "REST"},
+			},
+		}},
+		"current": map[string]any{"diagram_json": map[string]any{
+			"nodes": append(nodes, map[string]any{"id": "db", "type": "database"}),
+			"edges": []any{
+				map[string]any{"from": "web", "to": "gw", "protocol": "REST"},
+				map[string]any{"from": "web", "to": "orders", "protocol": "REST"},
+				map[string]any{"from": "orders", "to": "payments", "protocol": "Kafka"},
+				map[string]any{"from": "payments", "to": "db", "protocol": "SQL"},
+			},
+		}},
+	}
+
+	var out DiffResponse
+	status := doJSON(t, "POST", base+"/api/v1/diff", h, req, &out)
+	if status != 200 || !out.OK {
+		t.Fatalf("expected 200 ok, got %d, resp=%+v", status, out)
+	}
+	if len(out.AddedNodes) != 1 || out.AddedNodes[0].ID != "db" {
+		t.Fatalf("expected db to be added, got %+v", out.AddedNodes)
+	}
+	if len(out.RemovedEdges) != 1 || out.RemovedEdges[0].From != "gw" || out.RemovedEdges[0].To != "orders" {
+		t.Fatalf("expected gw -> orders to be removed, got %+v", out.RemovedEdges)
+	}
+	if len(out.ProtocolChanges) != 1 || out.ProtocolChanges[0].PreviousProtocol != "rest" || out.ProtocolChanges[0].CurrentProtocol != "kafka" {
+		t.Fatalf("expected orders -> payments rest => kafka, got %+v", out.ProtocolChanges)
+	}
+	bypass := false
+	for _, f := range out.NewFindings {
+		bypass = bypass || f.RuleID == "gateway_bypass"
+	}
+	if !bypass {
+		t.Fatalf("expected a new gateway_bypass finding, got %+v", out.NewFindings)
+	}
+
+	status = doJSON(t, "POST", base+"/api/v1/diff", h, map[string]any{"current": req["current"]}, nil)
+	if status != 400 {
+		t.Fatalf("expected 400 without previous, got %d", status)
+	}
+}
+
+func TestDiff_ReorderedCycleIsNotNew(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+
+	diagram := func(ids ...string) map[string]any {
+		var nodes, edges []any
+		for i, id := range ids {
+			nodes = append(nodes, map[string]any{"id": id, "type": "service"})
+			edges = append(edges, map[string]any{"from": id, "to": ids[(i+1)%len(ids)], "protocol": "REST"})
+		}
+		return map[string]any{"diagram_json": map[string]any{"nodes": nodes, "edges": edges}}
+	}
+	req := map[string]any{
+		"previous": diagram("a", "b", "c"),
+		"current":  diagram("c", "a", "b"),
+	}
+
+	var out DiffResponse
+	status := doJSON(t, "POST", base+"/api/v1/diff", h, req, &out)
+	if status != 200 || !out.OK {
+		t.Fatalf("expected 200 ok, got %d, resp=%+v", status, out)
+	}
+	if len(out.NewFindings) != 0 {
+		t.Fatalf("expected no new findings for a reordered cycle, got %+v", out.NewFindings)
+	}
+}
+
+type JobResponse struct {
+	OK  bool `json:"ok"`
+	Job struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		Files  []struct {
+			Name  string `json:"name"`
+			Type  string `json:"type"`
+			Nodes int    `json:"nodes"`
+			Edges int    `json:"edges"`
+		} `json:"files"`
+		Error *struct {
+			Code string `json:"code"`
+		} `json:"error"`
+		Attempts []struct {
+			Attempt int  `json:"attempt"`
+			Valid   bool `json:"valid"`
+		} `json:"attempts"`
+	} `json:"job"`
+}
+
+func TestJobs_UploadPollAndSpec(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("files", "orders.puml")
+	_, _ = io.WriteString(fw, "@startuml\ncomponent \"API Gateway\" as gw\ncomponent \"Orders\" as orders\ngw --> orders : REST\n@enduml\n")
+	_ = mw.WriteField("chat", "orders must handle 200 rps")
+	_ = mw.Close()
+
+	req, _ := http.NewRequest("POST", base+"/api/v1/jobs", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-API-Key", key)
+	resp, err := (&http.Client{Timeout: 15 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var created JobResponse
+	_ = json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != 202 || !created.OK || created.Job.ID == "" {
+		t.Fatalf("expected 202 with a job, got %d, resp=%+v", resp.StatusCode, created)
+	}
+	if len(created.Job.Files) != 1 || created.Job.Files[0].Type != "puml" {
+		t.Fatalf("expected one puml file, got %+v", created.Job.Files)
+	}
+
+	// The pipeline may call the LLM; wait for a terminal state.
+	var job JobResponse
+	deadline := time.Now().Add(2 * time.Minute)
+	for {
+		if status := doJSON(t, "GET", base+"/api/v1/jobs/"+created.Job.ID, h, nil, &job); status != 200 {
+			t.Fatalf("expected 200 for job status, got %d", status)
+		}
+		if job.Job.Status == "succeeded" || job.Job.Status == "failed" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(300 * time.Millisecond)
+	}
+
+	var spec struct {
+		OK   bool           `json:"ok"`
+		Spec map[string]any `json:"spec"`
+	}
+	status := doJSON(t, "GET", base+"/api/v1/jobs/"+created.Job.ID+"/spec", h, nil, &spec)
+	switch job.Job.Status {
+	case "succeeded":
+		if status != 200 || !spec.OK || spec.Spec["services"] == nil || spec.Spec["metadata"] == nil {
+			t.Fatalf("expected a validated spec, got %d, resp=%+v", status, spec)
+		}
+		var schemas struct {
+			Current string `json:"current"`
+		}
+		doJSON(t, "GET", base+"/api/v1/schema", h, nil, &schemas)
+		if md, _ := spec.Spec["metadata"].(map[string]any); md["schemaVersion"] != schemas.Current {
+			t.Fatalf("expected spec at schema %s, got metadata %v", schemas.Current, spec.Spec["metadata"])
+		}
+		if job.Job.Files[0].Nodes != 2 {
+			t.Fatalf("expected 2 ingested nodes, got %+v", job.Job.Files)
+		}
+		// Every model call is recorded in the spec's trace.
+		var traced int
+		trace, _ := spec.Spec["trace"].([]any)
+		for _, v := range trace {
+			if rec, _ := v.(map[string]any); rec["kind"] == "attempt" {
+				traced++
+			}
+		}
+		if traced != len(job.Job.Attempts) {
+			t.Fatalf("expected %d attempt records in trace, got %v", len(job.Job.Attempts), spec.Spec["trace"])
+		}
+	case "failed":
+		if status != 422 {
+			t.Fatalf("expected 422 for a failed job's spec, got %d", status)
+		}
+		t.Logf("job failed (is the fusion model reachable?): %+v", job.Job.Error)
+	default:
+		if status != 409 {
+			t.Fatalf("expected 409 while the job is %s, got %d", job.Job.Status, status)
+		}
+	}
+
+	if status := doJSON(t, "GET", base+"/api/v1/jobs/job_000000000000000000000000", h, nil, nil); status != 404 {
+		t.Fatalf("expected 404 for an unknown job, got %d", status)
+	}
+}
+
+func TestJobs_DOTUploadAndDOTExport(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("files", "orders.gv")
+	_, _ = io.WriteString(fw, `digraph orders {
+  subgraph cluster_backend {
+    label="Backend";
+    api [label="Orders API"];
+    db [label="Orders DB", shape=cylinder];
+  }
+  web [label="Web", shape=tab];
+  web -> api [label="HTTPS"];
+  api -> db [label="SQL"];
+}
+`)
+	_ = mw.Close()
+
+	req, _ := http.NewRequest("POST", base+"/api/v1/jobs", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("X-API-Key", key)
+	resp, err := (&http.Client{Timeout: 15 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var created JobResponse
+	_ = json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if resp.StatusCode != 202 || len(created.Job.Files) != 1 || created.Job.Files[0].Type != "dot" {
+		t.Fatalf("expected 202 with one dot file, got %d, resp=%+v", resp.StatusCode, created)
+	}
+
+	var job JobResponse
+	deadline := time.Now().Add(2 * time.Minute)
+	for {
+		doJSON(t, "GET", base+"/api/v1/jobs/"+created.Job.ID, h, nil, &job)
+		if job.Job.Status == "succeeded" || job.Job.Status == "failed" || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(300 * time.Millisecond)
+	}
+	if job.Job.Status != "succeeded" {
+		t.Skipf("job did not succeed (is the fusion model reachable?): %+v", job.Job)
+	}
+	if job.Job.Files[0].Nodes != 3 || job.Job.Files[0].Edges != 2 {
+		t.Fatalf("expected 3 nodes and 2 edges from DOT, got %+v", job.Job.Files)
+	}
+
+	if status := doJSON(t, "GET", base+"/api/v1/jobs/"+created.Job.ID+"/spec?format=xml", h, nil, nil); status != 400 {
+		t.Fatalf("expected 400 for an unknown format, got %d", status)
+	}
+	req, _ = http.NewRequest("GET", base+"/api/v1/jobs/"+created.Job.ID+"/spec?format=dot", nil)
+	req.Header.Set("X-API-Key", key)
+	resp, err = (&http.Client{Timeout: 15 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	dot, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 200 || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/vnd.graphviz") {
+		t.Fatalf("expected 200 text/vnd.graphviz, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if !strings.HasPrefix(string(dot), "digraph") || !strings.Contains(string(dot), "->") {
+		t.Fatalf("expected a digraph with edges, got:\n%s", dot)
+	}
+}
+
+func TestSchema_ServesEmbeddedVersions(t *testing.T) {
+	base := getenv("UIGP_BASE_URL", "http://localhost:8081")
+	key := getenv("UIGP_API_KEY", "dev-key")
+	h := map[string]string{"X-API-Key": key}
+
+	var list struct {
+		OK       bool     `json:"ok"`
+		Current  string   `json:"current"`
+		Versions []string `json:"versions"`
+	}
+	if status := doJSON(t, "GET", base+"/api/v1/schema", h, nil, &list); status != 200 || !list.OK || list.Current == "" {
+		t.Fatalf("expected 200 with a current version, got %d, resp=%+v", status, list)
+	}
+	found := false
+	for _, v := range list.Versions {
+		found = found || v == list.Current
+	}
+	if !found {
+		t.Fatalf("current version %q missing from %v", list.Current, list.Versions)
+	}
+
+	for _, v := range list.Versions {
+		var doc map[string]any
+		if status := doJSON(t, "GET", base+"/api/v1/schema/"+v, h, nil, &doc); status != 200 || doc["properties"] == nil {
+			t.Fatalf("expected schema %s, got %d, doc=%v", v, status, doc)
+		}
+	}
+	if status := doJSON(t, "GET", base+"/api/v1/schema/0.0.0-nope", h, nil, nil); status != 404 {
+		t.Fatalf("expected 404 for an unknown version, got %d", status)
+	}
+}